@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// adminTokenHeader carries the shared secret of the admin endpoints
+const adminTokenHeader = "X-Admin-Token"
+
+// requireAdmin wraps an admin handler with the shared secret check.
+// The admin endpoints are disabled when ADMIN_TOKEN isn't set.
+func requireAdmin(next handlerWithError) handlerWithError {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if config.AdminToken == "" {
+			return &statusError{http.StatusForbidden, errors.New("admin endpoints are disabled, set ADMIN_TOKEN")}
+		}
+
+		token := r.Header.Get(adminTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(config.AdminToken)) != 1 {
+			zerolog.Ctx(r.Context()).Warn().
+				Str("client_ip", maskIP(clientIP(r))).
+				Str("path", r.URL.Path).
+				Msg("rejected admin request with a missing or wrong token")
+
+			return &statusError{http.StatusUnauthorized, errors.New("missing or invalid " + adminTokenHeader + " header")}
+		}
+
+		return next(w, r)
+	}
+}
+
+type cacheFlushResponse struct {
+	SearchEntries       int `json:"search_entries"`
+	AutocompleteEntries int `json:"autocomplete_entries"`
+}
+
+// cacheFlushHandler empties the search and autocomplete caches,
+// e.g. to show articles that were just edited on Wikipedia
+func cacheFlushHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		return &statusError{http.StatusMethodNotAllowed, errors.New("method not allowed")}
+	}
+
+	resp := cacheFlushResponse{
+		SearchEntries:       responseCache.Flush(),
+		AutocompleteEntries: suggestionCache.Flush(),
+	}
+
+	zerolog.Ctx(r.Context()).Info().
+		Str("client_ip", maskIP(clientIP(r))).
+		Int("search_entries", resp.SearchEntries).
+		Int("autocomplete_entries", resp.AutocompleteEntries).
+		Msg("cache flushed")
+
+	buf := getBuffer()
+
+	err := json.NewEncoder(buf).Encode(resp)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
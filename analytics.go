@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// zeroResultsEvent is the event of the log line of a search without any hit
+const zeroResultsEvent = "zero_results"
+
+// normalizeQuery folds the case and the spacing of a query,
+// so that the events of the same query group together in the reports
+func normalizeQuery(q string) string {
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ")
+}
+
+// logZeroResults logs the zero_results event of the search described by opts, which got no hit,
+// for the reports of what's searched but not found on Wikipedia. It has an event field
+// of its own so that it can be told apart from the other search logs.
+// filtered tells whether the exclusions, refinements or safe search may have left the results out.
+func logZeroResults(l *zerolog.Logger, opts searchOptions) {
+	l.Info().
+		Str("event", zeroResultsEvent).
+		Str("normalized_query", normalizeQuery(opts.Query)).
+		Str("lang", opts.Lang).
+		Str("project", opts.Project).
+		Bool("filtered", len(opts.Exclude) > 0 || len(opts.Refine) > 0 || opts.Safe).
+		Msg("search without results")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := map[string]string{
+		"Go":                   "go",
+		"  Board   GAME\t":     "board game",
+		"Théorie des Graphes ": "théorie des graphes",
+		"":                     "",
+	}
+
+	for q, want := range tests {
+		if got := normalizeQuery(q); got != want {
+			t.Errorf("normalizeQuery(%q) = %q, want %q", q, got, want)
+		}
+	}
+}
+
+// zeroResultsEvents returns the zero_results events logged while serving target
+func zeroResultsEvents(t *testing.T, target string) []map[string]any {
+	t.Helper()
+
+	var logs bytes.Buffer
+	l := zerolog.New(&logs)
+
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r = r.WithContext(l.WithContext(r.Context()))
+
+	w := httptest.NewRecorder()
+	handlerWithError(searchHandler).ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d, want 200: %s", target, w.Code, w.Body)
+	}
+
+	var events []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+
+		if event["event"] == zeroResultsEvent {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+func TestZeroResultsEvent(t *testing.T) {
+	stubSearch(t, `{"query": {"searchinfo": {"totalhits": 0}, "search": []}}`)
+
+	events := zeroResultsEvents(t, "/search?q=+Xyzzy++Plugh&lang=fr")
+	if len(events) != 1 {
+		t.Fatalf("%d zero_results events, want 1", len(events))
+	}
+
+	event := events[0]
+	if event["level"] != "info" || event["normalized_query"] != "xyzzy plugh" || event["lang"] != "fr" {
+		t.Errorf("zero_results event %v, want the normalized query and the language at info level", event)
+	}
+
+	if event["filtered"] != false {
+		t.Errorf("a search without filters is logged as filtered: %v", event)
+	}
+}
+
+func TestZeroResultsEventOnlyOnZeroResults(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"results", searchResponseJSON},
+		{"past the results", pastResultsResponseJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubSearch(t, tt.body)
+
+			if events := zeroResultsEvents(t, "/search?q=go&page=3"); len(events) != 0 {
+				t.Errorf("zero_results logged for a search with hits: %v", events)
+			}
+		})
+	}
+}
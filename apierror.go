@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// apiError is an error reported by the MediaWiki API in the error field of a response,
+// which comes with a 200 OK, see https://www.mediawiki.org/wiki/API:Errors_and_warnings
+type apiError struct {
+	Code string `json:"code"`
+	Info string `json:"info"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("Wikipedia API error '%s': %s", e.Code, e.Info)
+}
+
+// apiErrorMessage is what users are told about an API error, with the status code of the response
+type apiErrorMessage struct {
+	Status  int
+	Message string
+}
+
+// apiErrorMessages are the messages of the API error codes worth explaining to users, by code
+var apiErrorMessages = map[string]apiErrorMessage{
+	"badvalue": {
+		http.StatusBadRequest, "Wikipedia doesn't accept one of the options of this search. Check them and try again.",
+	},
+	"missingparam": {
+		http.StatusBadRequest, "This search is missing something Wikipedia needs. Check the query and try again.",
+	},
+	"toomanyvalues": {
+		http.StatusBadRequest, "This search has too many terms for Wikipedia. Remove some and try again.",
+	},
+	"badinteger": {
+		http.StatusBadRequest, "The page of this search is invalid. Start over from the first page.",
+	},
+	"badcontinue": {
+		http.StatusBadRequest, "The link to the next page is invalid or has expired. Start over from the first page.",
+	},
+	"ratelimited": {
+		http.StatusServiceUnavailable, "Wikipedia is receiving too many requests from this site. Try again in a minute.",
+	},
+	"srsearch-text-disabled": {
+		http.StatusServiceUnavailable, "Wikipedia's full text search is turned off for now. Try again later.",
+	},
+	"srsearch-title-disabled": {
+		http.StatusServiceUnavailable, "Wikipedia's title search is turned off for now. Try again later.",
+	},
+}
+
+// unknownAPIErrorMessage is the message of the API error codes missing from apiErrorMessages
+var unknownAPIErrorMessage = apiErrorMessage{
+	http.StatusBadGateway, "Wikipedia couldn't answer this request. Try again later.",
+}
+
+// friendlyMessage returns the message of the API error code, unknownAPIErrorMessage for an unknown code
+func friendlyMessage(code string) apiErrorMessage {
+	if m, ok := apiErrorMessages[code]; ok {
+		return m
+	}
+
+	return unknownAPIErrorMessage
+}
+
+// statusError returns the error served to users for e.
+// The raw code is only logged as it means little to users.
+func (e *apiError) statusError(l *zerolog.Logger) *statusError {
+	m := friendlyMessage(e.Code)
+
+	ev := l.Warn()
+	if _, known := apiErrorMessages[e.Code]; !known {
+		ev = l.Error()
+	}
+
+	ev.Str("api_error_code", e.Code).
+		Str("api_error_info", strings.TrimSpace(e.Info)).
+		Msg("Wikipedia API rejected the request")
+
+	return &statusError{m.Status, errors.New(m.Message)}
+}
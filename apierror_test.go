@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestFriendlyMessage(t *testing.T) {
+	tests := []struct {
+		code    string
+		status  int
+		message string
+	}{
+		{"badvalue", http.StatusBadRequest, "Wikipedia doesn't accept one of the options of this search."},
+		{"toomanyvalues", http.StatusBadRequest, "This search has too many terms for Wikipedia."},
+		{"badcontinue", http.StatusBadRequest, "The link to the next page is invalid or has expired."},
+		{"ratelimited", http.StatusServiceUnavailable, "Wikipedia is receiving too many requests from this site."},
+		{"internal_api_error_DBQueryError", http.StatusBadGateway, "Wikipedia couldn't answer this request."},
+		{"", http.StatusBadGateway, "Wikipedia couldn't answer this request."},
+	}
+
+	for _, tt := range tests {
+		m := friendlyMessage(tt.code)
+		if m.Status != tt.status || !strings.HasPrefix(m.Message, tt.message) {
+			t.Errorf("friendlyMessage(%q) = %d %q, want %d %q", tt.code, m.Status, m.Message, tt.status, tt.message)
+		}
+	}
+}
+
+func TestAPIErrorResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		combined bool
+		status   int
+		level    string
+	}{
+		{"known code", "toomanyvalues", false, http.StatusBadRequest, `"level":"warn"`},
+		{"unknown code", "srsearch-mystery", false, http.StatusBadGateway, `"level":"error"`},
+		{"known code, combined search", "badvalue", true, http.StatusBadRequest, `"level":"warn"`},
+		{"unknown code, combined search", "srsearch-mystery", true, http.StatusBadGateway, `"level":"error"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.CombinedSearch = tt.combined })
+			stubSearch(t, `{"error": {"code": "`+tt.code+`", "info": "raw info of `+tt.code+`"}}`)
+
+			var logs bytes.Buffer
+			l := zerolog.New(&logs)
+
+			r := httptest.NewRequest(http.MethodGet, "/search?q=go&format=json", nil)
+			r = r.WithContext(l.WithContext(r.Context()))
+
+			w := httptest.NewRecorder()
+			handlerWithError(searchHandler).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+
+			// users get the explanation, the logs the raw code
+			body := w.Body.String()
+			if !strings.Contains(body, friendlyMessage(tt.code).Message) || strings.Contains(body, tt.code) {
+				t.Errorf("body %q, want the friendly message without the raw code", body)
+			}
+
+			var rejected string
+			for _, line := range strings.Split(logs.String(), "\n") {
+				if strings.Contains(line, "Wikipedia API rejected the request") {
+					rejected = line
+				}
+			}
+
+			if !strings.Contains(rejected, `"api_error_code":"`+tt.code+`"`) || !strings.Contains(rejected, tt.level) {
+				t.Errorf("the raw code isn't logged at %s: %s", tt.level, logs.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// basicAuthRealm is shown by browsers in the login prompt
+const basicAuthRealm = "Wikipedia Search"
+
+// basicAuth returns a middleware that requires the user and password with HTTP Basic Auth,
+// or lets every request through when user is empty
+func basicAuth(user string, pass Secret) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if user == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, p, ok := r.BasicAuth()
+
+			// both are compared every time so the response time doesn't tell which one is wrong
+			userMatch := subtle.ConstantTimeCompare([]byte(u), []byte(user))
+			passMatch := subtle.ConstantTimeCompare([]byte(p), []byte(pass))
+
+			if !ok || userMatch&passMatch != 1 {
+				zerolog.Ctx(r.Context()).Debug().
+					Bool("credentials_sent", ok).
+					Msg("rejecting request with missing or wrong basic auth credentials")
+
+				w.Header().Set("WWW-Authenticate", `Basic realm="`+basicAuthRealm+`", charset="UTF-8"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := basicAuth("admin", "s3cret")(ok)
+
+	tests := []struct {
+		name       string
+		user, pass string
+		send       bool
+		want       int
+	}{
+		{"correct credentials", "admin", "s3cret", true, http.StatusOK},
+		{"wrong password", "admin", "guess", true, http.StatusUnauthorized},
+		{"wrong user", "root", "s3cret", true, http.StatusUnauthorized},
+		{"empty credentials", "", "", true, http.StatusUnauthorized},
+		{"missing credentials", "", "", false, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+			if tt.send {
+				r.SetBasicAuth(tt.user, tt.pass)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d", w.Code, tt.want)
+			}
+
+			challenge := w.Header().Get("WWW-Authenticate")
+			if (tt.want == http.StatusUnauthorized) != (challenge != "") {
+				t.Errorf("WWW-Authenticate = %q with status %d", challenge, w.Code)
+			}
+		})
+	}
+}
+
+func TestBasicAuthDisabled(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	if w := serve(basicAuth("", "")(ok), "/search?q=go", nil); w.Code != http.StatusOK {
+		t.Errorf("status %d without a configured user, want 200", w.Code)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// autocompleteMinLength is the shortest query, in characters, worth suggesting titles for
+	autocompleteMinLength = 2
+	autocompleteLimit     = 10
+)
+
+// autocompleteCache holds the suggested titles per language and prefix.
+// Type-ahead boxes send the same prefixes over and over, so entries are kept for a long time.
+type autocompleteCache struct {
+	mu         sync.RWMutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]autocompleteEntry
+}
+
+type autocompleteEntry struct {
+	titles    []string
+	expiresAt time.Time
+}
+
+func newAutocompleteCache(ttl time.Duration, maxEntries int) *autocompleteCache {
+	return &autocompleteCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]autocompleteEntry),
+	}
+}
+
+var suggestionCache *autocompleteCache
+
+func (c *autocompleteCache) Get(key string) ([]string, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+
+	return entry.titles, true
+}
+
+func (c *autocompleteCache) Set(key string, titles []string) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		now := time.Now()
+
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+
+		// every entry is still fresh, drop an arbitrary one
+		if len(c.entries) >= c.maxEntries {
+			for k := range c.entries {
+				delete(c.entries, k)
+				break
+			}
+		}
+	}
+
+	c.entries[key] = autocompleteEntry{
+		titles:    titles,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+}
+
+// Flush empties the cache and returns the number of entries dropped
+func (c *autocompleteCache) Flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := len(c.entries)
+	c.entries = make(map[string]autocompleteEntry)
+
+	return n
+}
+
+// fetchTitleSuggestions returns the titles of the articles starting with prefix
+// through the OpenSearch API, see https://www.mediawiki.org/wiki/API:Opensearch
+func fetchTitleSuggestions(ctx context.Context, lang, prefix string) ([]string, error) {
+	params := url.Values{}
+	params.Set("action", "opensearch")
+	params.Set("search", prefix)
+	params.Set("limit", strconv.Itoa(autocompleteLimit))
+	params.Set("namespace", "0")
+	params.Set("redirects", "resolve")
+
+	// the response is [query, [titles], [descriptions], [urls]]
+	var resp []json.RawMessage
+
+	err := callWikipedia(ctx, lang, params, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	titles := []string{}
+
+	if len(resp) > 1 {
+		err = json.Unmarshal(resp[1], &titles)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return titles, nil
+}
+
+// autocompleteHandler serves /api/autocomplete, a lightweight endpoint for type-ahead boxes
+// that only returns a JSON array of article titles. Queries shorter than autocompleteMinLength
+// get an empty array without calling Wikipedia, and so does a failed or slow lookup
+// since a missing suggestion is better than an error while typing.
+func autocompleteHandler(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query().Get("q")
+
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		lang = config.DefaultLang
+	}
+
+	if !isSupportedLanguage(lang) {
+		return &statusError{http.StatusBadRequest, fmt.Errorf("unsupported language '%s'", lang)}
+	}
+
+	titles := []string{}
+	cacheControl := "public, max-age=" + strconv.Itoa(int(time.Duration(config.AutocompleteCacheTTL).Seconds()))
+
+	if utf8.RuneCountInString(query) >= autocompleteMinLength {
+		key := lang + "\x00" + query
+
+		if cached, ok := suggestionCache.Get(key); ok {
+			titles = cached
+		} else {
+			ctx, cancel := context.WithTimeout(r.Context(), time.Duration(config.AutocompleteTimeout))
+			defer cancel()
+
+			suggestions, err := fetchTitleSuggestions(ctx, lang, query)
+			if err != nil {
+				zerolog.Ctx(r.Context()).Warn().
+					Err(err).
+					Str("search_query", query).
+					Msg("unable to fetch title suggestions")
+
+				cacheControl = "no-store"
+			} else {
+				suggestionCache.Set(key, suggestions)
+				titles = suggestions
+			}
+		}
+	}
+
+	buf := getBuffer()
+
+	err := json.NewEncoder(buf).Encode(titles)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", cacheControl)
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
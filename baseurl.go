@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// baseURL returns the public URL of the app without a trailing slash.
+// It's taken from the configuration when set, since the request host is wrong behind a proxy,
+// and derived from the request otherwise, honoring the X-Forwarded-Proto and X-Forwarded-Host
+// headers of the trusted proxies.
+func baseURL(r *http.Request) string {
+	if config.PublicBaseURL != "" {
+		return strings.TrimRight(config.PublicBaseURL, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	host := r.Host
+
+	if fromTrustedProxy(r) {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+
+		if forwardedHost := r.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	return scheme + "://" + host
+}
+
+// basePath returns the path prefix under which the app is served, from config.PublicBaseURL,
+// without a trailing slash. It's empty when the app is served at the root.
+func basePath() string {
+	if config.PublicBaseURL == "" {
+		return ""
+	}
+
+	u, err := url.Parse(config.PublicBaseURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimRight(u.Path, "/")
+}
+
+// localURL returns the link to path, a path of the app like "/search?q=go", under the base path.
+// The links to the app are all built with it so that they keep working behind a proxy
+// that serves the app under a path prefix.
+func localURL(path string) string {
+	return basePath() + path
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBaseURL(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		proxies, err := parseTrustedProxies([]string{"10.0.0.0/8"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.trustedProxies = proxies
+	})
+
+	forwarded := http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"search.example.org"}}
+
+	tests := []struct {
+		name          string
+		publicBaseURL string
+		remoteAddr    string
+		tls           bool
+		header        http.Header
+		want          string
+	}{
+		{"from the request", "", "203.0.113.7:5000", false, nil, "http://example.com"},
+		{"over TLS", "", "203.0.113.7:5000", true, nil, "https://example.com"},
+		{"forwarded by a trusted proxy", "", "10.0.0.2:5000", false, forwarded, "https://search.example.org"},
+		{"forwarded by an untrusted peer", "", "203.0.113.7:5000", false, forwarded, "http://example.com"},
+		{"invalid forwarded scheme", "", "10.0.0.2:5000", false, http.Header{"X-Forwarded-Proto": {"javascript"}}, "http://example.com"},
+		{"configured", "https://example.org/wiki-search/", "10.0.0.2:5000", false, forwarded, "https://example.org/wiki-search"},
+	}
+
+	for _, tt := range tests {
+		withConfig(t, func(c *Config) {
+			c.PublicBaseURL = tt.publicBaseURL
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "/opensearch.xml", nil)
+		r.RemoteAddr = tt.remoteAddr
+		r.Header = tt.header
+		if !tt.tls {
+			r.TLS = nil
+		} else {
+			r.TLS = &tls.ConnectionState{}
+		}
+
+		if got := baseURL(r); got != tt.want {
+			t.Errorf("%s: baseURL() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLocalURL(t *testing.T) {
+	tests := []struct {
+		publicBaseURL string
+		want          string
+	}{
+		{"", "/search?q=go"},
+		{"https://example.org", "/search?q=go"},
+		{"https://example.org/", "/search?q=go"},
+		{"https://example.org/wiki-search/", "/wiki-search/search?q=go"},
+	}
+
+	for _, tt := range tests {
+		withConfig(t, func(c *Config) {
+			c.PublicBaseURL = tt.publicBaseURL
+		})
+
+		if got := localURL("/search?q=go"); got != tt.want {
+			t.Errorf("PublicBaseURL=%q: localURL() = %q, want %q", tt.publicBaseURL, got, tt.want)
+		}
+	}
+}
+
+func TestGeneratedLinks(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	tests := []struct {
+		publicBaseURL string
+		pageLink      string
+		openSearch    string
+	}{
+		{"", `href="/search?page=2&amp;q=go"`, "http://example.com/search?q={searchTerms}"},
+		{"https://example.org/wiki-search", `href="/wiki-search/search?page=2&amp;q=go"`, "https://example.org/wiki-search/search?q={searchTerms}"},
+	}
+
+	for _, tt := range tests {
+		withConfig(t, func(c *Config) {
+			c.PublicBaseURL = tt.publicBaseURL
+		})
+
+		w := serve(handlerWithError(searchHandler), "/search?q=go", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+		}
+
+		if !strings.Contains(w.Body.String(), tt.pageLink) {
+			t.Errorf("PublicBaseURL=%q: no %s link", tt.publicBaseURL, tt.pageLink)
+		}
+
+		w = serve(handlerWithError(openSearchHandler), "/opensearch.xml", nil)
+		if !strings.Contains(w.Body.String(), `template="`+tt.openSearch+`"`) {
+			t.Errorf("PublicBaseURL=%q: the OpenSearch template isn't %s: %s", tt.publicBaseURL, tt.openSearch, w.Body)
+		}
+	}
+}
+
+func TestCookiePaths(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+	withBookmarkStore(t)
+
+	for _, tt := range []struct{ publicBaseURL, path string }{
+		{"", "/"},
+		{"https://example.org/wiki-search/", "/wiki-search/"},
+	} {
+		withConfig(t, func(c *Config) {
+			c.PublicBaseURL = tt.publicBaseURL
+		})
+
+		theme := serve(handlerWithError(searchHandler), "/search?q=go&theme=dark", nil)
+
+		form := url.Values{"page_id": {"1"}, "title": {"Gopher"}, "lang": {"en"}}
+		session := postBookmark(handlerWithError(addBookmarkHandler), "/bookmarks/add", form, "")
+
+		for _, w := range []*httptest.ResponseRecorder{theme, session} {
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Path != tt.path {
+				t.Errorf("PublicBaseURL=%q: cookies %v, want one with path %s", tt.publicBaseURL, cookies, tt.path)
+			}
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookie = "session"
+
+	// maxBookmarks bounds the memory a single browser can use
+	maxBookmarks = 100
+
+	// maxSessions bounds the memory of all the browsers, the least recently used session is dropped past it
+	maxSessions = 10000
+
+	// maxTitleLength is the maximum length of a Wikipedia title, in bytes
+	maxTitleLength = 255
+)
+
+var errTooManyBookmarks = fmt.Errorf("cannot save more than %d bookmarks", maxBookmarks)
+
+// errUnknownSession is returned when saving a bookmark in a session the store didn't start or has dropped
+var errUnknownSession = errors.New("unknown bookmark session")
+
+// Bookmark is an article starred by a visitor
+type Bookmark struct {
+	PageID    int       `json:"page_id"`
+	Title     string    `json:"title"`
+	Lang      string    `json:"lang"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (b Bookmark) URL() string {
+	return articleURL(b.Lang, b.PageID)
+}
+
+// bookmarkStore saves the bookmarks of each browser session.
+// Only the sessions started by the store are kept, so a client can't pick its session ID.
+// Implementations must be safe for concurrent use.
+type bookmarkStore interface {
+	// NewSession starts a session and returns its random ID
+	NewSession() (string, error)
+	// HasSession reports whether the session was started by NewSession and is still kept
+	HasSession(session string) bool
+	// Add saves a bookmark, adding an article that is already bookmarked is a no-op.
+	// It fails with errUnknownSession when the session isn't kept.
+	Add(session string, b Bookmark) error
+	// Remove deletes a bookmark, removing an unknown one is a no-op
+	Remove(session, lang string, pageID int) error
+	// List returns the bookmarks of a session, most recent first
+	List(session string) ([]Bookmark, error)
+}
+
+// bookmarkSession is a browser session of memoryBookmarkStore
+type bookmarkSession struct {
+	bookmarks []Bookmark
+	usedAt    time.Time
+}
+
+// memoryBookmarkStore keeps the bookmarks in memory: they're lost when the process exits.
+// At most maxSessions are kept, the least recently used one is dropped to start a new one.
+type memoryBookmarkStore struct {
+	mu          sync.Mutex
+	maxSessions int
+	sessions    map[string]*bookmarkSession
+}
+
+func newMemoryBookmarkStore(maxSessions int) *memoryBookmarkStore {
+	return &memoryBookmarkStore{
+		maxSessions: maxSessions,
+		sessions:    make(map[string]*bookmarkSession),
+	}
+}
+
+var bookmarks bookmarkStore = newMemoryBookmarkStore(maxSessions)
+
+func (s *memoryBookmarkStore) NewSession() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	id := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.sessions) >= s.maxSessions {
+		s.evictLeastRecentlyUsed()
+	}
+
+	s.sessions[id] = &bookmarkSession{usedAt: time.Now()}
+
+	return id, nil
+}
+
+// evictLeastRecentlyUsed drops the session used the longest ago. Must be called with s.mu held.
+func (s *memoryBookmarkStore) evictLeastRecentlyUsed() {
+	var oldestID string
+	var oldest time.Time
+
+	for id, session := range s.sessions {
+		if oldestID == "" || session.usedAt.Before(oldest) {
+			oldestID, oldest = id, session.usedAt
+		}
+	}
+
+	delete(s.sessions, oldestID)
+}
+
+func (s *memoryBookmarkStore) HasSession(session string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.sessions[session]
+
+	return ok
+}
+
+// use returns the session and marks it as used, nil if it isn't kept. Must be called with s.mu held.
+func (s *memoryBookmarkStore) use(id string) *bookmarkSession {
+	session := s.sessions[id]
+	if session != nil {
+		session.usedAt = time.Now()
+	}
+
+	return session
+}
+
+func (s *memoryBookmarkStore) Add(id string, b Bookmark) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session := s.use(id)
+	if session == nil {
+		return errUnknownSession
+	}
+
+	for _, existing := range session.bookmarks {
+		if existing.Lang == b.Lang && existing.PageID == b.PageID {
+			return nil
+		}
+	}
+
+	if len(session.bookmarks) >= maxBookmarks {
+		return errTooManyBookmarks
+	}
+
+	session.bookmarks = append(session.bookmarks, b)
+
+	return nil
+}
+
+func (s *memoryBookmarkStore) Remove(id, lang string, pageID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session := s.use(id)
+	if session == nil {
+		return nil
+	}
+
+	saved := session.bookmarks
+	for i, b := range saved {
+		if b.Lang == lang && b.PageID == pageID {
+			session.bookmarks = append(saved[:i:i], saved[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryBookmarkStore) List(id string) ([]Bookmark, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var saved []Bookmark
+	if session := s.use(id); session != nil {
+		saved = session.bookmarks
+	}
+
+	list := make([]Bookmark, len(saved))
+	for i, b := range saved {
+		list[len(saved)-1-i] = b
+	}
+
+	return list, nil
+}
+
+// sessionID returns the ID of the browser session from its cookie, ignoring the IDs the store doesn't keep.
+// When create is set and there's none yet, a new session is started.
+func sessionID(w http.ResponseWriter, r *http.Request, create bool) (string, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err == nil && bookmarks.HasSession(cookie.Value) {
+		return cookie.Value, nil
+	}
+
+	if !create {
+		return "", nil
+	}
+
+	id, err := bookmarks.NewSession()
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     localURL("/"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   r.TLS != nil,
+	})
+
+	return id, nil
+}
+
+// bookmarkFromForm reads the article to add or remove from the submitted form
+func bookmarkFromForm(r *http.Request) (Bookmark, error) {
+	b := Bookmark{
+		Title: r.PostFormValue("title"),
+		Lang:  r.PostFormValue("lang"),
+	}
+
+	if !isSupportedLanguage(b.Lang) {
+		return b, &statusError{
+			http.StatusBadRequest,
+			fmt.Errorf("unsupported language '%s'", b.Lang),
+		}
+	}
+
+	pageID, err := strconv.Atoi(r.PostFormValue("page_id"))
+	if err != nil || pageID < 1 {
+		return b, &statusError{
+			http.StatusBadRequest,
+			fmt.Errorf("invalid page ID '%s'", r.PostFormValue("page_id")),
+		}
+	}
+
+	b.PageID = pageID
+
+	return b, nil
+}
+
+// redirectBack sends the visitor back to the page the form was submitted from.
+// Only local paths are followed so the form can't be used as an open redirect.
+func redirectBack(w http.ResponseWriter, r *http.Request) {
+	target := r.PostFormValue("return_to")
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		target = localURL("/bookmarks")
+	}
+
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// bookmarksHandler lists the bookmarks of the visitor
+func bookmarksHandler(w http.ResponseWriter, r *http.Request) error {
+	session, err := sessionID(w, r, false)
+	if err != nil {
+		return err
+	}
+
+	list := []Bookmark{}
+
+	if session != "" {
+		list, err = bookmarks.List(session)
+		if err != nil {
+			return err
+		}
+	}
+
+	buf := getBuffer()
+
+	switch negotiateFormat(r) {
+	case formatJSON:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = json.NewEncoder(buf).Encode(list)
+	default:
+		err = tpl.ExecuteTemplate(buf, "bookmarks.html", list)
+	}
+	if err != nil {
+		return err
+	}
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
+
+// addBookmarkHandler stars the article posted in the form
+func addBookmarkHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		return &statusError{http.StatusMethodNotAllowed, errors.New("method not allowed")}
+	}
+
+	b, err := bookmarkFromForm(r)
+	if err != nil {
+		return err
+	}
+
+	if b.Title == "" {
+		return &statusError{http.StatusBadRequest, errors.New("missing title")}
+	}
+
+	if len(b.Title) > maxTitleLength {
+		return &statusError{
+			http.StatusBadRequest,
+			fmt.Errorf("title must not be longer than %d bytes", maxTitleLength),
+		}
+	}
+
+	b.CreatedAt = time.Now()
+
+	session, err := sessionID(w, r, true)
+	if err != nil {
+		return err
+	}
+
+	err = bookmarks.Add(session, b)
+	if errors.Is(err, errTooManyBookmarks) {
+		return &statusError{http.StatusConflict, err}
+	}
+	// the session was dropped since it was looked up
+	if errors.Is(err, errUnknownSession) {
+		return &statusError{http.StatusConflict, errors.New("the session has expired, try again")}
+	}
+	if err != nil {
+		return err
+	}
+
+	redirectBack(w, r)
+
+	return nil
+}
+
+// removeBookmarkHandler unstars the article posted in the form
+func removeBookmarkHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		return &statusError{http.StatusMethodNotAllowed, errors.New("method not allowed")}
+	}
+
+	b, err := bookmarkFromForm(r)
+	if err != nil {
+		return err
+	}
+
+	session, err := sessionID(w, r, false)
+	if err != nil {
+		return err
+	}
+
+	if session != "" {
+		err = bookmarks.Remove(session, b.Lang, b.PageID)
+		if err != nil {
+			return err
+		}
+	}
+
+	redirectBack(w, r)
+
+	return nil
+}
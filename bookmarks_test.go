@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMemoryBookmarkStore(t *testing.T) {
+	s := newMemoryBookmarkStore(10)
+
+	session, err := s.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(session) != 32 || !s.HasSession(session) {
+		t.Fatalf("session %q isn't a kept random ID", session)
+	}
+
+	gopher := Bookmark{PageID: 1, Title: "Gopher", Lang: "en"}
+	golang := Bookmark{PageID: 2, Title: "Go (programming language)", Lang: "en"}
+	french := Bookmark{PageID: 2, Title: "Go (langage)", Lang: "fr"}
+
+	for _, b := range []Bookmark{gopher, golang, gopher, french} {
+		if err := s.Add(session, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, _ := s.List(session)
+	if len(list) != 3 || list[0] != french || list[1] != golang || list[2] != gopher {
+		t.Errorf("List() = %+v, want the 3 distinct bookmarks, most recent first", list)
+	}
+
+	_ = s.Remove(session, "en", 2)
+	_ = s.Remove(session, "en", 404)
+
+	list, _ = s.List(session)
+	if len(list) != 2 || list[0] != french || list[1] != gopher {
+		t.Errorf("List() = %+v after removing a bookmark, want the 2 others", list)
+	}
+
+	if !s.HasSession(session) {
+		t.Error("the session is dropped with its bookmarks")
+	}
+}
+
+func TestMemoryBookmarkStoreMaxBookmarks(t *testing.T) {
+	s := newMemoryBookmarkStore(10)
+	session, _ := s.NewSession()
+
+	for id := 1; id <= maxBookmarks; id++ {
+		if err := s.Add(session, Bookmark{PageID: id, Lang: "en"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Add(session, Bookmark{PageID: maxBookmarks + 1, Lang: "en"}); !errors.Is(err, errTooManyBookmarks) {
+		t.Errorf("Add() past the limit = %v, want errTooManyBookmarks", err)
+	}
+}
+
+func TestMemoryBookmarkStoreUnknownSession(t *testing.T) {
+	s := newMemoryBookmarkStore(10)
+
+	if s.HasSession("0123456789abcdef0123456789abcdef") {
+		t.Error("a session that wasn't started is kept")
+	}
+
+	if err := s.Add("chosen-by-the-client", Bookmark{PageID: 1, Lang: "en"}); !errors.Is(err, errUnknownSession) {
+		t.Errorf("Add() = %v, want errUnknownSession", err)
+	}
+
+	if list, err := s.List("chosen-by-the-client"); err != nil || len(list) != 0 {
+		t.Errorf("List() = %v, %v, want no bookmarks", list, err)
+	}
+}
+
+func TestMemoryBookmarkStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	s := newMemoryBookmarkStore(2)
+
+	first, _ := s.NewSession()
+	second, _ := s.NewSession()
+
+	// using the first session makes the second one the least recently used
+	_, _ = s.List(first)
+
+	third, _ := s.NewSession()
+
+	if !s.HasSession(first) || s.HasSession(second) || !s.HasSession(third) {
+		t.Errorf("kept sessions %t, %t, %t, want the second one dropped",
+			s.HasSession(first), s.HasSession(second), s.HasSession(third))
+	}
+
+	if len(s.sessions) != 2 {
+		t.Errorf("%d sessions kept, want 2", len(s.sessions))
+	}
+}
+
+// postBookmark posts the form to h with the session cookie, if any
+func postBookmark(h http.Handler, target string, form url.Values, session string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if session != "" {
+		r.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+// withBookmarkStore replaces the bookmark store with an empty one for the duration of the test
+func withBookmarkStore(t *testing.T) *memoryBookmarkStore {
+	saved := bookmarks
+	t.Cleanup(func() { bookmarks = saved })
+
+	s := newMemoryBookmarkStore(10)
+	bookmarks = s
+
+	return s
+}
+
+func TestAddBookmarkIgnoresForgedSession(t *testing.T) {
+	s := withBookmarkStore(t)
+
+	form := url.Values{"page_id": {"1"}, "title": {"Gopher"}, "lang": {"en"}}
+	w := postBookmark(handlerWithError(addBookmarkHandler), "/bookmarks/add", form, "victim-session")
+
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status %d, want 303: %s", w.Code, w.Body)
+	}
+
+	if s.HasSession("victim-session") {
+		t.Error("the session ID chosen by the client is kept")
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !s.HasSession(cookies[0].Value) {
+		t.Fatalf("no new session issued: %v", cookies)
+	}
+
+	list, _ := s.List(cookies[0].Value)
+	if len(list) != 1 || list[0].Title != "Gopher" {
+		t.Errorf("bookmarks of the new session = %+v, want Gopher", list)
+	}
+
+	// the issued session is used on the next request
+	w = serve(handlerWithError(bookmarksHandler), "/bookmarks?format=json", http.Header{
+		"Cookie": {sessionCookie + "=" + cookies[0].Value},
+	})
+
+	var got []Bookmark
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || len(got) != 1 {
+		t.Errorf("GET /bookmarks = %+v, %v, want the bookmark", got, err)
+	}
+}
+
+func TestAddBookmarkTitleLength(t *testing.T) {
+	withBookmarkStore(t)
+
+	tests := []struct {
+		title string
+		want  int
+	}{
+		{strings.Repeat("a", maxTitleLength), http.StatusSeeOther},
+		{strings.Repeat("a", maxTitleLength+1), http.StatusBadRequest},
+		{"", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		form := url.Values{"page_id": {"1"}, "title": {tt.title}, "lang": {"en"}}
+
+		if w := postBookmark(handlerWithError(addBookmarkHandler), "/bookmarks/add", form, ""); w.Code != tt.want {
+			t.Errorf("title of %d bytes: status %d, want %d", len(tt.title), w.Code, tt.want)
+		}
+	}
+}
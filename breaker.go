@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/freshman-tech/news-demo/logger"
+)
+
+var errCircuitOpen = errors.New("wikipedia API is unavailable: circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops calling a failing dependency after threshold consecutive failures.
+// While open, calls fail immediately until cooldown has elapsed,
+// after which a single probe is let through (half-open) to decide whether to close again.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	probing   bool
+	openedAt  time.Time
+	threshold int
+	cooldown  time.Duration
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// State returns the current state of the breaker
+func (cb *circuitBreaker) State() breakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state
+}
+
+// Execute runs fn if the breaker allows it and records the outcome.
+// errCircuitOpen is returned without calling fn while the breaker is open.
+func (cb *circuitBreaker) Execute(fn func() error) error {
+	if !cb.allow() {
+		return errCircuitOpen
+	}
+
+	err := fn()
+	cb.record(err)
+
+	return err
+}
+
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case breakerOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return false
+		}
+
+		cb.setState(breakerHalfOpen)
+		cb.probing = true
+
+		return true
+	case breakerHalfOpen:
+		// only one probe at a time
+		if cb.probing {
+			return false
+		}
+
+		cb.probing = true
+
+		return true
+	default:
+		return true
+	}
+}
+
+func (cb *circuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+
+	// the caller went away, or the request was rejected for what it asked:
+	// that says nothing about the health of the dependency
+	var ae *apiError
+	if errors.Is(err, context.Canceled) || errors.Is(err, errEmptyQuery) || errors.As(err, &ae) {
+		return
+	}
+
+	if err == nil {
+		cb.failures = 0
+		cb.setState(breakerClosed)
+
+		return
+	}
+
+	cb.failures++
+	if cb.state == breakerHalfOpen || cb.failures >= cb.threshold {
+		cb.openedAt = time.Now()
+		cb.setState(breakerOpen)
+	}
+}
+
+// setState must be called with cb.mu held
+func (cb *circuitBreaker) setState(state breakerState) {
+	if cb.state == state {
+		return
+	}
+
+	l := logger.Get()
+	l.Warn().
+		Str("from", cb.state.String()).
+		Str("to", state.String()).
+		Int("consecutive_failures", cb.failures).
+		Msg("wikipedia circuit breaker state changed")
+
+	cb.state = state
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	cb := newCircuitBreaker(2, time.Hour)
+	failure := errors.New("connection reset")
+
+	cb.Execute(func() error { return failure })
+	if cb.State() != breakerClosed {
+		t.Fatalf("state %s after one failure, want closed", cb.State())
+	}
+
+	cb.Execute(func() error { return failure })
+	if cb.State() != breakerOpen {
+		t.Fatalf("state %s after two failures, want open", cb.State())
+	}
+
+	called := false
+	err := cb.Execute(func() error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, errCircuitOpen) || called {
+		t.Errorf("error %v, called %t, want errCircuitOpen without a call", err, called)
+	}
+}
+
+func TestBreakerIgnoresRejectedRequests(t *testing.T) {
+	ignored := map[string]error{
+		"canceled":    context.Canceled,
+		"api error":   &apiError{Code: "badvalue", Info: "Unrecognized value for parameter \"srwhat\""},
+		"wrapped":     fmt.Errorf("searching: %w", &apiError{Code: "toomanyvalues"}),
+		"empty query": errEmptyQuery,
+	}
+
+	for name, err := range ignored {
+		t.Run(name, func(t *testing.T) {
+			cb := newCircuitBreaker(2, time.Hour)
+
+			for i := 0; i < 5; i++ {
+				cb.Execute(func() error { return err })
+			}
+
+			if cb.State() != breakerClosed {
+				t.Errorf("state %s, want closed", cb.State())
+			}
+
+			// nor do they reset the count of the failures in between
+			cb.Execute(func() error { return errors.New("connection reset") })
+			cb.Execute(func() error { return err })
+			cb.Execute(func() error { return errors.New("connection reset") })
+
+			if cb.State() != breakerOpen {
+				t.Errorf("state %s after two failures, want open", cb.State())
+			}
+		})
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	cb := newCircuitBreaker(1, 10*time.Millisecond)
+
+	cb.Execute(func() error { return errors.New("connection reset") })
+	time.Sleep(20 * time.Millisecond)
+
+	// a rejected probe says nothing either, the next call probes again
+	cb.Execute(func() error { return &apiError{Code: "badvalue"} })
+	if cb.State() != breakerHalfOpen {
+		t.Fatalf("state %s after a rejected probe, want half-open", cb.State())
+	}
+
+	cb.Execute(func() error { return nil })
+	if cb.State() != breakerClosed {
+		t.Errorf("state %s after a successful probe, want closed", cb.State())
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/singleflight"
+)
+
+// values of the X-Cache response header
+const (
+	cacheHit   = "HIT"   // served from the cache
+	cacheMiss  = "MISS"  // fetched from Wikipedia
+	cacheStale = "STALE" // Wikipedia failed, served an expired entry from the cache
+)
+
+type cacheEntry struct {
+	response  *WikipediaSearchResponse
+	expiresAt time.Time
+}
+
+// searchCache is an in-memory cache of Wikipedia search responses.
+// Entries are fresh for ttl, then kept for staleTTL more so they can be served
+// when Wikipedia is failing. Cached responses are shared between requests and must not be modified.
+type searchCache struct {
+	mu         sync.RWMutex
+	ttl        time.Duration
+	staleTTL   time.Duration
+	maxEntries int
+	entries    map[string]cacheEntry
+}
+
+func newSearchCache(ttl, staleTTL time.Duration, maxEntries int) *searchCache {
+	return &searchCache{
+		ttl:        ttl,
+		staleTTL:   staleTTL,
+		maxEntries: maxEntries,
+		entries:    make(map[string]cacheEntry),
+	}
+}
+
+var responseCache *searchCache
+
+// Get returns the cached response for key and the time left until it expires,
+// which is negative for a stale response
+func (c *searchCache) Get(key string) (response *WikipediaSearchResponse, expiresIn time.Duration, ok bool) {
+	if c.ttl <= 0 {
+		return nil, 0, false
+	}
+
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	now := time.Now()
+	if !ok || now.After(entry.expiresAt.Add(c.staleTTL)) {
+		return nil, 0, false
+	}
+
+	return entry.response, entry.expiresAt.Sub(now), true
+}
+
+func (c *searchCache) Set(key string, response *WikipediaSearchResponse) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+
+	c.entries[key] = cacheEntry{
+		response:  response,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+}
+
+// Flush empties the cache and returns the number of entries dropped
+func (c *searchCache) Flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := len(c.entries)
+	c.entries = make(map[string]cacheEntry)
+
+	return n
+}
+
+// evict drops the entries that are too old to be served even as stale,
+// or the one closest to expiry if there are none. Must be called with c.mu held.
+func (c *searchCache) evict() {
+	now := time.Now()
+
+	var oldestKey string
+	var oldest time.Time
+
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt.Add(c.staleTTL)) {
+			delete(c.entries, key)
+			continue
+		}
+
+		if oldestKey == "" || entry.expiresAt.Before(oldest) {
+			oldestKey, oldest = key, entry.expiresAt
+		}
+	}
+
+	if len(c.entries) >= c.maxEntries {
+		delete(c.entries, oldestKey)
+	}
+}
+
+// searchCacheKey identifies a search by everything that affects its results: the project and
+// the language edition, which are in the API host rather than the parameters, and the API parameters.
+// Deriving the key from the request sent to Wikipedia means a new search option can't be left out of it.
+func searchCacheKey(opts searchOptions, combined bool) string {
+	params := opts.apiParams()
+	if combined {
+		params = opts.combinedAPIParams()
+	}
+
+	return opts.Project + "\x00" + opts.Lang + "\x00" + params.Encode()
+}
+
+// searchGroup coalesces the identical searches that miss the cache at the same time,
+// e.g. a popular query, into a single Wikipedia request
+var searchGroup singleflight.Group
+
+// cachedSearch returns the results of a search from the cache or from Wikipedia, along with the X-Cache status.
+// When Wikipedia fails (or the circuit breaker is open), an expired entry is served rather than an error.
+func cachedSearch(ctx context.Context, opts searchOptions) (*WikipediaSearchResponse, string, error) {
+	combined := opts.combined()
+
+	key := searchCacheKey(opts, combined)
+
+	l := zerolog.Ctx(ctx)
+
+	cached, expiresIn, ok := responseCache.Get(key)
+	if ok && expiresIn > 0 {
+		if expiresIn <= time.Duration(config.CacheRefreshAhead) {
+			refreshAhead(*l, key, opts, combined)
+		}
+
+		return cached, cacheHit, nil
+	}
+
+	flight := searchFlight(l, key, opts, combined)
+
+	var (
+		searchResponse *WikipediaSearchResponse
+		err            error
+	)
+
+	select {
+	case result := <-flight:
+		err = result.Err
+		if err == nil {
+			searchResponse = result.Val.(*WikipediaSearchResponse)
+		}
+
+		if result.Shared {
+			l.Debug().Msg("search coalesced with identical concurrent searches")
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		if ok {
+			zerolog.Ctx(ctx).Warn().
+				Err(err).
+				Msg("unable to search Wikipedia, serving stale results from the cache")
+
+			return cached, cacheStale, nil
+		}
+
+		return nil, cacheMiss, err
+	}
+
+	return searchResponse, cacheMiss, nil
+}
+
+// searchFlight searches Wikipedia and caches the response, once for all the identical searches
+// in flight, see searchGroup. The result is delivered on the returned channel, which is buffered
+// so that nobody has to wait for it.
+func searchFlight(l *zerolog.Logger, key string, opts searchOptions, combined bool) <-chan singleflight.Result {
+	searchFunc := searchWikipedia
+	if combined {
+		searchFunc = searchWikipediaCombined
+	}
+
+	return searchGroup.DoChan(key, func() (any, error) {
+		// the request is shared, so it's detached from the one that started it which could go away
+		// before the others. They each stop waiting at their own deadline, which is at most MaxRequestTimeout.
+		ctx, cancel := context.WithTimeout(l.WithContext(context.Background()), time.Duration(config.MaxRequestTimeout))
+		defer cancel()
+
+		var searchResponse *WikipediaSearchResponse
+
+		err := wikipediaBreaker.Execute(func() error {
+			var err error
+			searchResponse, err = searchFunc(ctx, opts)
+
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		responseCache.Set(key, searchResponse)
+
+		return searchResponse, nil
+	})
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// frenchSearchResponseJSON is a response of the French Wikipedia to a search for "go"
+const frenchSearchResponseJSON = `{
+	"batchcomplete": "",
+	"query": {
+		"searchinfo": {"totalhits": 1},
+		"search": [
+			{"ns": 0, "title": "Go (langage)", "pageid": 4230061, "size": 300, "wordcount": 30,
+				"snippet": "Go est un langage de programmation", "timestamp": "2023-01-01T00:00:00Z"}
+		]
+	}
+}`
+
+func TestCacheSeparatesLanguages(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Features.Cache = true
+		c.CacheTTL = Duration(time.Minute)
+	})
+
+	var calls atomic.Int64
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		calls.Add(1)
+
+		if strings.HasPrefix(r.URL.Host, "fr.") {
+			return jsonResponse(r, frenchSearchResponseJSON), nil
+		}
+
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	for _, xCache := range []string{cacheMiss, cacheHit} {
+		en := serve(handlerWithError(searchHandler), "/search?q=go&lang=en", nil)
+		fr := serve(handlerWithError(searchHandler), "/search?q=go&lang=fr", nil)
+
+		if en.Header().Get("X-Cache") != xCache || fr.Header().Get("X-Cache") != xCache {
+			t.Errorf("X-Cache = %q and %q, want %s", en.Header().Get("X-Cache"), fr.Header().Get("X-Cache"), xCache)
+		}
+
+		if !strings.Contains(en.Body.String(), "Go (programming language)") || strings.Contains(en.Body.String(), "Go (langage)") {
+			t.Errorf("%s: the English search doesn't show the English results", xCache)
+		}
+
+		if !strings.Contains(fr.Body.String(), "Go (langage)") || strings.Contains(fr.Body.String(), "Go (programming language)") {
+			t.Errorf("%s: the French search doesn't show the French results", xCache)
+		}
+	}
+
+	if calls.Load() != 2 {
+		t.Errorf("%d calls to Wikipedia, want one per language", calls.Load())
+	}
+
+	if n := len(responseCache.entries); n != 2 {
+		t.Errorf("%d cache entries, want one per language", n)
+	}
+}
+
+func TestSearchCacheKey(t *testing.T) {
+	base := searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20, Profile: defaultProfile}
+
+	variants := map[string]func(o *searchOptions){
+		"lang":      func(o *searchOptions) { o.Lang = "fr" },
+		"project":   func(o *searchOptions) { o.Project = "wiktionary" },
+		"limit":     func(o *searchOptions) { o.Limit = 10 },
+		"offset":    func(o *searchOptions) { o.Offset = 20 },
+		"profile":   func(o *searchOptions) { o.Profile = "classic" },
+		"exclude":   func(o *searchOptions) { o.Exclude = []string{"game"} },
+		"redirects": func(o *searchOptions) { o.ResolveRedirects = true },
+		"safe":      func(o *searchOptions) { o.Safe = true },
+	}
+
+	key := searchCacheKey(base, false)
+
+	for name, change := range variants {
+		opts := base
+		change(&opts)
+
+		if searchCacheKey(opts, false) == key {
+			t.Errorf("searches differing by %s share a cache key", name)
+		}
+	}
+}
+
+func TestConcurrentSearchesAreCoalesced(t *testing.T) {
+	const clients = 50
+
+	release := make(chan struct{})
+
+	var calls atomic.Int64
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		<-release
+
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	var wg sync.WaitGroup
+	codes := make([]int, clients)
+
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			w := serve(handlerWithError(searchHandler), "/search?q=go&format=json", nil)
+			codes[i] = w.Code
+		}(i)
+	}
+
+	// the first search holds the flight open until every client has had the time to join it
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("%d calls to Wikipedia for %d identical searches, want 1", calls.Load(), clients)
+	}
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("client %d: status %d, want 200", i, code)
+		}
+	}
+
+	// a different search isn't coalesced with the previous one
+	serve(handlerWithError(searchHandler), "/search?q=go&page=2&format=json", nil)
+
+	if calls.Load() != 2 {
+		t.Errorf("%d calls to Wikipedia after a different search, want 2", calls.Load())
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Features.Cache = true
+		c.CacheTTL = Duration(time.Minute)
+		c.CacheRefreshAhead = Duration(10 * time.Second)
+	})
+
+	release := make(chan struct{})
+
+	var calls atomic.Int64
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		// the refresh waits until the hits close to expiry are served
+		if calls.Add(1) > 1 {
+			<-release
+		}
+
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	const target = "/search?q=go&format=json"
+
+	serve(handlerWithError(searchHandler), target, nil)
+
+	// far from expiry, no refresh
+	if w := serve(handlerWithError(searchHandler), target, nil); w.Header().Get("X-Cache") != cacheHit {
+		t.Fatalf("X-Cache = %q, want %s", w.Header().Get("X-Cache"), cacheHit)
+	}
+
+	if calls.Load() != 1 {
+		t.Fatalf("%d calls to Wikipedia for a hit far from expiry, want 1", calls.Load())
+	}
+
+	// the entry is now close to expiry
+	responseCache.mu.Lock()
+	for key, entry := range responseCache.entries {
+		entry.expiresAt = time.Now().Add(5 * time.Second)
+		responseCache.entries[key] = entry
+	}
+	responseCache.mu.Unlock()
+
+	// the hits don't wait for the refresh, which only starts once
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			w := serve(handlerWithError(searchHandler), target, nil)
+			if w.Header().Get("X-Cache") != cacheHit {
+				t.Errorf("X-Cache = %q close to expiry, want %s", w.Header().Get("X-Cache"), cacheHit)
+			}
+		}()
+	}
+	wg.Wait()
+
+	close(release)
+
+	// the refresh sets the entry afresh in the background
+	refreshed := func() bool {
+		responseCache.mu.RLock()
+		defer responseCache.mu.RUnlock()
+
+		for _, entry := range responseCache.entries {
+			if time.Until(entry.expiresAt) < 30*time.Second {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for deadline := time.Now().Add(2 * time.Second); !refreshed(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the entry close to expiry wasn't refreshed")
+		}
+	}
+
+	// a fresh entry isn't refreshed again
+	serve(handlerWithError(searchHandler), target, nil)
+
+	if calls.Load() != 2 {
+		t.Errorf("%d calls to Wikipedia, want exactly one refresh", calls.Load())
+	}
+}
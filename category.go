@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+const categoryPrefix = "Category:"
+
+var errInvalidCategory = errors.New("invalid category name")
+
+type WikipediaCategoryResponse struct {
+	Error    *apiError `json:"error"`
+	Continue struct {
+		CMContinue string `json:"cmcontinue"`
+	} `json:"continue"`
+	Query struct {
+		// Pages holds the category page itself, so we can tell whether it exists
+		Pages map[string]struct {
+			Title   string  `json:"title"`
+			Missing *string `json:"missing"`
+		} `json:"pages"`
+		CategoryMembers []struct {
+			Ns     int    `json:"ns"`
+			Title  string `json:"title"`
+			PageID int    `json:"pageid"`
+		} `json:"categorymembers"`
+	} `json:"query"`
+}
+
+// Exists reports whether the category page was found
+func (c *WikipediaCategoryResponse) Exists() bool {
+	for _, page := range c.Query.Pages {
+		if page.Missing == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Category struct {
+	Name     string                     `json:"name"`
+	Continue string                     `json:"continue,omitempty"`
+	Results  *WikipediaCategoryResponse `json:"results"`
+}
+
+// ArticleURL returns the link to a member of the category
+func (c *Category) ArticleURL(pageID int) string {
+	return articleURL(config.DefaultLang, pageID)
+}
+
+// NextPageURL returns the URL of the next page of members, empty on the last page
+func (c *Category) NextPageURL() string {
+	if c.Results == nil || c.Results.Continue.CMContinue == "" {
+		return ""
+	}
+
+	v := url.Values{}
+	v.Set("name", c.Name)
+	v.Set("continue", c.Results.Continue.CMContinue)
+
+	return localURL("/category?" + v.Encode())
+}
+
+// normalizeCategory validates a category name, with or without the "Category:" prefix,
+// and returns it without the prefix
+func normalizeCategory(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) >= len(categoryPrefix) && strings.EqualFold(name[:len(categoryPrefix)], categoryPrefix) {
+		name = strings.TrimSpace(name[len(categoryPrefix):])
+	}
+
+	// 255 bytes is the maximum length of a page title, see https://en.wikipedia.org/wiki/Wikipedia:Page_name
+	if name == "" || len(categoryPrefix+name) > 255 || strings.ContainsAny(name, "#<>[]|{}") {
+		return "", errInvalidCategory
+	}
+
+	return name, nil
+}
+
+func categoryMembers(
+	ctx context.Context,
+	category, cmcontinue string,
+	limit int,
+) (*WikipediaCategoryResponse, error) {
+	params := url.Values{}
+	params.Set("action", "query")
+	params.Set("list", "categorymembers")
+	params.Set("cmtitle", categoryPrefix+category)
+	params.Set("cmlimit", strconv.Itoa(limit))
+	params.Set("titles", categoryPrefix+category)
+
+	if cmcontinue != "" {
+		params.Set("cmcontinue", cmcontinue)
+	}
+
+	var categoryResponse WikipediaCategoryResponse
+
+	err := wikipediaBreaker.Execute(func() error {
+		return callWikipedia(ctx, config.DefaultLang, params, &categoryResponse)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &categoryResponse, nil
+}
+
+func categoryHandler(w http.ResponseWriter, r *http.Request) error {
+	params := r.URL.Query()
+
+	name, err := normalizeCategory(params.Get("name"))
+	if err != nil {
+		return &statusError{http.StatusBadRequest, err}
+	}
+
+	cmcontinue := params.Get("continue")
+
+	categoryResponse, err := categoryMembers(r.Context(), name, cmcontinue, config.PageSize)
+	if errors.Is(err, errCircuitOpen) {
+		return &statusError{http.StatusServiceUnavailable, err}
+	}
+	if err != nil {
+		return err
+	}
+
+	// the API reports invalid parameters (e.g. a tampered continue token) in a 200 response
+	if categoryResponse.Error != nil {
+		return categoryResponse.Error.statusError(zerolog.Ctx(r.Context()))
+	}
+
+	if !categoryResponse.Exists() {
+		return &statusError{
+			http.StatusNotFound,
+			fmt.Errorf("category '%s' doesn't exist", name),
+		}
+	}
+
+	category := &Category{
+		Name:     name,
+		Continue: cmcontinue,
+		Results:  categoryResponse,
+	}
+
+	buf := getBuffer()
+
+	switch negotiateFormat(r) {
+	case formatJSON:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = json.NewEncoder(buf).Encode(category)
+	default:
+		err = tpl.ExecuteTemplate(buf, "category.html", category)
+	}
+	if err != nil {
+		return err
+	}
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		proxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.trustedProxies = proxies
+	})
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		want         string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "", "203.0.113.7"},
+		{"spoofed X-Forwarded-For from an untrusted peer", "203.0.113.7:5000", []string{"1.2.3.4"}, "", "203.0.113.7"},
+		{"spoofed X-Real-IP from an untrusted peer", "203.0.113.7:5000", nil, "1.2.3.4", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:5000", []string{"198.51.100.9"}, "", "198.51.100.9"},
+		{"trusted proxy by single address", "192.168.1.1:5000", []string{"198.51.100.9"}, "", "198.51.100.9"},
+		{"address prepended by the client", "10.0.0.2:5000", []string{"1.2.3.4, 198.51.100.9"}, "", "198.51.100.9"},
+		{"chain of trusted proxies", "10.0.0.2:5000", []string{"198.51.100.9, 10.0.0.3", "10.0.0.4"}, "", "198.51.100.9"},
+		{"garbage in X-Forwarded-For", "10.0.0.2:5000", []string{"not-an-ip"}, "", "10.0.0.2"},
+		{"X-Real-IP from a trusted proxy", "10.0.0.2:5000", nil, "198.51.100.9", "198.51.100.9"},
+		{"IPv4-mapped trusted proxy", "[::ffff:10.0.0.2]:5000", []string{"198.51.100.9"}, "", "198.51.100.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+
+			for _, v := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	if _, err := parseTrustedProxies([]string{"10.0.0.0/8", " 127.0.0.1 ", "::1"}); err != nil {
+		t.Errorf("valid proxies rejected: %v", err)
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "localhost", ""} {
+		if _, err := parseTrustedProxies([]string{invalid}); err == nil {
+			t.Errorf("invalid proxy %q accepted", invalid)
+		}
+	}
+}
+
+func TestLoadConfigRejectsInvalidTrustedProxies(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8,not-a-proxy")
+
+	cfg, err := loadConfig()
+	if err == nil {
+		t.Fatal("invalid trusted proxy accepted")
+	}
+
+	if cfg.trustedProxies != nil || cfg.Port != "" {
+		t.Errorf("loadConfig() returned a partly validated config: %+v", cfg.trustedProxies)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// combinedSearchMaxLimit is the maximum number of intro extracts the API returns in one request,
+// config.validate keeps the page size within it
+const combinedSearchMaxLimit = 20
+
+// WikipediaCombinedResponse is the response of a generator=search query,
+// where the matching pages come with the properties requested in prop
+type WikipediaCombinedResponse struct {
+	// Error is set when the API rejected the search, see searchWikipediaCombined
+	Error    *apiError `json:"error"`
+	Continue struct {
+		Gsroffset int    `json:"gsroffset"`
+		Continue  string `json:"continue"`
+	} `json:"continue"`
+	Query struct {
+		SearchInfo struct {
+			TotalHits int `json:"totalhits"`
+		} `json:"searchinfo"`
+		// Redirects are the redirects resolved to the pages, with redirects=1
+		Redirects []struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		} `json:"redirects"`
+		Pages []struct {
+			PageID    int        `json:"pageid"`
+			Ns        int        `json:"ns"`
+			Title     string     `json:"title"`
+			Index     int        `json:"index"`
+			Length    int        `json:"length"`
+			FullURL   string     `json:"fullurl"`
+			Extract   string     `json:"extract"`
+			Thumbnail *Thumbnail `json:"thumbnail"`
+
+			// Missing and Invalid are set on the pages requested by ID that don't exist
+			Missing bool `json:"missing"`
+			Invalid bool `json:"invalid"`
+		} `json:"pages"`
+	} `json:"query"`
+}
+
+// searchWikipediaCombined searches Wikipedia and fetches the URL, intro extract and thumbnail
+// of every result in a single round trip, using the search as a generator for prop=info|extracts|pageimages.
+// The response is mapped to the WikipediaSearchResponse shape, without snippets
+// which aren't available to generators.
+func searchWikipediaCombined(ctx context.Context, opts searchOptions) (*WikipediaSearchResponse, error) {
+	if strings.TrimSpace(opts.Query) == "" {
+		return nil, errEmptyQuery
+	}
+
+	params := opts.combinedAPIParams()
+
+	zerolog.Ctx(ctx).Debug().
+		EmbedObject(opts).
+		Bool("combined", true).
+		Msg("searching Wikipedia")
+
+	var combinedResponse WikipediaCombinedResponse
+
+	err := callMediaWiki(ctx, opts.Project, opts.Lang, params, &combinedResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	// the API reports the searches it rejects in a 200 response
+	if combinedResponse.Error != nil {
+		return nil, combinedResponse.Error
+	}
+
+	searchResponse := combinedResponse.toSearchResponse()
+
+	checkSearchResponse(zerolog.Ctx(ctx), searchResponse)
+
+	config.resultPipeline.Process(opts, searchResponse.Query.Search)
+
+	return searchResponse, nil
+}
+
+// combined reports whether the search runs as a combined search, see config.CombinedSearch,
+// which the extracts parameter turns on for a search. The generator doesn't return the matches
+// on sister wikis, interwiki searches use list=search.
+func (o searchOptions) combined() bool {
+	return (config.CombinedSearch || o.Extracts) && !o.Interwiki
+}
+
+// combinedAPIParams returns the parameters of the generator=search API request for the search
+func (o searchOptions) combinedAPIParams() url.Values {
+	params := url.Values{}
+	params.Set("action", "query")
+	params.Set("formatversion", "2")
+	params.Set("generator", "search")
+	params.Set("gsrsearch", o.searchTerms())
+	params.Set("gsrlimit", strconv.Itoa(o.Limit))
+	params.Set("gsroffset", strconv.Itoa(o.Offset))
+	params.Set("gsrqiprofile", o.Profile)
+	params.Set("gsrinfo", "totalhits")
+	params.Set("prop", "info|extracts")
+	params.Set("inprop", "url")
+	params.Set("exintro", "1")
+	params.Set("explaintext", "1")
+	params.Set("exsentences", "2")
+	params.Set("exlimit", strconv.Itoa(o.Limit))
+
+	if config.Features.Thumbnails {
+		params.Set("prop", "info|extracts|pageimages")
+		params.Set("piprop", "thumbnail")
+		params.Set("pithumbsize", "80")
+		params.Set("pilimit", strconv.Itoa(o.Limit))
+	}
+
+	if o.ResolveRedirects {
+		params.Set("redirects", "1")
+	}
+
+	if o.Safe {
+		params.Set("gsrnamespace", "0")
+	}
+
+	return params
+}
+
+func (c *WikipediaCombinedResponse) toSearchResponse() *WikipediaSearchResponse {
+	pages := c.Query.Pages
+
+	// pages aren't returned in relevance order, index is the rank in the search
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].Index < pages[j].Index
+	})
+
+	redirectedFrom := make(map[string]string, len(c.Query.Redirects))
+	for _, redirect := range c.Query.Redirects {
+		redirectedFrom[redirect.To] = redirect.From
+	}
+
+	var searchResponse WikipediaSearchResponse
+	searchResponse.Continue.Sroffset = c.Continue.Gsroffset
+	searchResponse.Continue.Continue = c.Continue.Continue
+	searchResponse.Query.SearchInfo.TotalHits = c.Query.SearchInfo.TotalHits
+
+	for _, page := range pages {
+		searchResponse.Query.Search = append(searchResponse.Query.Search, SearchResult{
+			Ns:        page.Ns,
+			Title:     page.Title,
+			PageID:    page.PageID,
+			Size:      page.Length,
+			FullURL:   page.FullURL,
+			Extract:   page.Extract,
+			Thumbnail: page.Thumbnail,
+
+			RedirectTitle: redirectedFrom[page.Title],
+		})
+	}
+
+	return &searchResponse
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// combinedResponseJSON is a response of a generator=search query, with the pages out of relevance order
+const combinedResponseJSON = `{
+	"batchcomplete": true,
+	"continue": {"gsroffset": 2, "continue": "gsroffset||"},
+	"query": {
+		"searchinfo": {"totalhits": 99},
+		"redirects": [{"index": 1, "from": "Golang", "to": "Go (programming language)"}],
+		"pages": [
+			{"pageid": 12454, "ns": 0, "title": "Go (game)", "index": 2, "length": 100,
+				"fullurl": "https://en.wikipedia.org/wiki/Go_(game)", "extract": "Go is a board game."},
+			{"pageid": 25039021, "ns": 0, "title": "Go (programming language)", "index": 1, "length": 300,
+				"fullurl": "https://en.wikipedia.org/wiki/Go_(programming_language)", "extract": "Go is a programming language.",
+				"thumbnail": {"source": "https://upload.wikimedia.org/go.png", "width": 80, "height": 40}}
+		]
+	}
+}`
+
+func TestSearchWikipediaCombined(t *testing.T) {
+	var sent *http.Request
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		sent = r
+		return jsonResponse(r, combinedResponseJSON), nil
+	})
+
+	opts := searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20, ResolveRedirects: true}
+
+	resp, err := searchWikipediaCombined(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := sent.URL.Query()
+	if params.Get("generator") != "search" || params.Get("gsrsearch") != "go" || params.Get("exintro") != "1" {
+		t.Errorf("unexpected combined request parameters: %v", params)
+	}
+
+	if got := resp.Query.SearchInfo.TotalHits; got != 99 {
+		t.Errorf("TotalHits = %d, want 99", got)
+	}
+
+	if got := resp.Continue.Sroffset; got != 2 {
+		t.Errorf("Sroffset = %d, want 2", got)
+	}
+
+	results := resp.Query.Search
+	if len(results) != 2 {
+		t.Fatalf("%d results, want 2", len(results))
+	}
+
+	first, second := results[0], results[1]
+
+	if first.Title != "Go (programming language)" || second.Title != "Go (game)" {
+		t.Errorf("results %q, %q aren't in relevance order", first.Title, second.Title)
+	}
+
+	if first.Extract != "Go is a programming language." || first.Size != 300 {
+		t.Errorf("first result = %+v, want its extract and size", first)
+	}
+
+	if first.URL() != "https://en.wikipedia.org/wiki/Go_(programming_language)" {
+		t.Errorf("URL() = %q, want the fullurl of the page", first.URL())
+	}
+
+	if first.Thumbnail == nil || first.Thumbnail.Source != "https://upload.wikimedia.org/go.png" {
+		t.Errorf("Thumbnail = %+v, want the page image", first.Thumbnail)
+	}
+
+	if first.RedirectTitle != "Golang" || second.RedirectTitle != "" {
+		t.Errorf("RedirectTitle = %q, %q, want Golang for the first result only", first.RedirectTitle, second.RedirectTitle)
+	}
+}
+
+func TestSearchExtracts(t *testing.T) {
+	// the second result has no extract
+	body := strings.Replace(combinedResponseJSON, `, "extract": "Go is a board game."`, "", 1)
+
+	var sent url.Values
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		sent = r.URL.Query()
+		return jsonResponse(r, body), nil
+	})
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go&extracts=1&page=3", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	// the pages are numbered the same with or without extracts
+	if sent.Get("generator") != "search" || sent.Get("gsrlimit") != "20" || sent.Get("gsroffset") != "40" {
+		t.Errorf("generator %q, gsrlimit %q, gsroffset %q, want search, 20, 40",
+			sent.Get("generator"), sent.Get("gsrlimit"), sent.Get("gsroffset"))
+	}
+
+	if sent.Get("exsentences") != "2" || sent.Get("explaintext") != "1" || sent.Get("exlimit") != "20" {
+		t.Errorf("unexpected extract parameters: %v", sent)
+	}
+
+	page := w.Body.String()
+	if !strings.Contains(page, "Go is a programming language.") || !strings.Contains(page, "Go (game)") {
+		t.Error("the results or their extract are missing")
+	}
+}
+
+func TestCombinedSearchPageSize(t *testing.T) {
+	tests := []struct {
+		combined, extracts bool
+		pageSize           int
+		valid              bool
+	}{
+		{false, true, combinedSearchMaxLimit, true},
+		{false, true, combinedSearchMaxLimit + 1, false},
+		{true, false, combinedSearchMaxLimit + 1, false},
+		{false, false, 50, true},
+	}
+
+	for _, tt := range tests {
+		cfg := defaultConfig()
+		cfg.CombinedSearch = tt.combined
+		cfg.Features.Extracts = tt.extracts
+		cfg.PageSize = tt.pageSize
+
+		if err := cfg.validate(); (err == nil) != tt.valid {
+			t.Errorf("combined search %t, extracts %t, page size %d: err = %v", tt.combined, tt.extracts, tt.pageSize, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// maxCompareConcurrency bounds the requests to Wikipedia a comparison sends at once
+const maxCompareConcurrency = 4
+
+// LanguageHits is the number of matching articles of a search in one language edition
+type LanguageHits struct {
+	Lang  string `json:"lang"`
+	Name  string `json:"name"`
+	Hits  int    `json:"hits"`
+	Error string `json:"error,omitempty"`
+}
+
+// Comparison is the number of matching articles of a query in each of config.CompareLanguages
+type Comparison struct {
+	Query     string         `json:"query"`
+	Languages []LanguageHits `json:"languages"`
+	// Failed are the languages that couldn't be counted, they're in Languages with an Error
+	Failed []string `json:"failed,omitempty"`
+}
+
+// compareLanguages counts the articles matching searchQuery in each of langs concurrently,
+// at most maxCompareConcurrency at a time. A language that fails to be counted, or isn't
+// counted within config.WikipediaTimeout, gets an Error instead of failing the whole comparison.
+func compareLanguages(ctx context.Context, searchQuery string, langs []string) []LanguageHits {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.WikipediaTimeout))
+	defer cancel()
+
+	counts := make([]LanguageHits, len(langs))
+	sem := make(chan struct{}, maxCompareConcurrency)
+
+	var wg sync.WaitGroup
+
+	for i, lang := range langs {
+		wg.Add(1)
+
+		go func(i int, lang string) {
+			defer wg.Done()
+
+			counts[i] = LanguageHits{Lang: lang, Name: languageName(lang)}
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				counts[i].Error = "unavailable"
+				return
+			}
+
+			hits, err := countHits(ctx, defaultProject, lang, searchQuery, 0)
+			if err != nil {
+				zerolog.Ctx(ctx).Warn().
+					Err(err).
+					Str("lang", lang).
+					Msg("unable to count the hits of a language")
+
+				counts[i].Error = "unavailable"
+
+				return
+			}
+
+			counts[i].Hits = hits
+		}(i, lang)
+	}
+
+	wg.Wait()
+
+	return counts
+}
+
+// languageName returns the name of one of the supportedLanguages, the code of an unknown one
+func languageName(code string) string {
+	for _, lang := range supportedLanguages {
+		if lang.Code == code {
+			return lang.Name
+		}
+	}
+
+	return code
+}
+
+// compareHandler serves /compare?q=, the number of articles matching q in each of
+// config.CompareLanguages, as JSON or as a table
+func compareHandler(w http.ResponseWriter, r *http.Request) error {
+	searchQuery := strings.TrimSpace(r.URL.Query().Get("q"))
+	if searchQuery == "" {
+		return &statusError{http.StatusBadRequest, errEmptyQuery}
+	}
+
+	comparison := &Comparison{
+		Query:     searchQuery,
+		Languages: compareLanguages(r.Context(), searchQuery, config.CompareLanguages),
+	}
+
+	for _, lang := range comparison.Languages {
+		if lang.Error != "" {
+			comparison.Failed = append(comparison.Failed, lang.Lang)
+		}
+	}
+
+	buf := getBuffer()
+
+	var err error
+
+	switch negotiateFormat(r) {
+	case formatJSON:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = json.NewEncoder(buf).Encode(comparison)
+	default:
+		err = tpl.ExecuteTemplate(buf, "compare.html", comparison)
+	}
+	if err != nil {
+		return err
+	}
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
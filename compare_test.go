@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCompareLanguages(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.CompareLanguages = []string{"en", "fr", "de"}
+		c.WikipediaMaxRetries = 0
+	})
+
+	hits := map[string]int{"en": 1234, "de": 56}
+
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		lang := strings.SplitN(r.URL.Host, ".", 2)[0]
+
+		n, ok := hits[lang]
+		if !ok {
+			return nil, errors.New("connection refused")
+		}
+
+		return jsonResponse(r, fmt.Sprintf(`{"query": {"searchinfo": {"totalhits": %d}, "search": []}}`, n)), nil
+	})
+
+	w := serve(handlerWithError(compareHandler), "/compare?q=go&format=json", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	var comparison Comparison
+	if err := json.NewDecoder(w.Body).Decode(&comparison); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []LanguageHits{
+		{Lang: "en", Name: languageName("en"), Hits: 1234},
+		{Lang: "fr", Name: languageName("fr"), Error: "unavailable"},
+		{Lang: "de", Name: languageName("de"), Hits: 56},
+	}
+
+	if len(comparison.Languages) != len(want) {
+		t.Fatalf("languages = %+v, want %+v", comparison.Languages, want)
+	}
+
+	for i := range want {
+		if comparison.Languages[i] != want[i] {
+			t.Errorf("language %d = %+v, want %+v", i, comparison.Languages[i], want[i])
+		}
+	}
+
+	if len(comparison.Failed) != 1 || comparison.Failed[0] != "fr" {
+		t.Errorf("failed = %q, want fr", comparison.Failed)
+	}
+
+	// the table
+	w = serve(handlerWithError(compareHandler), "/compare?q=go", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if body := w.Body.String(); !strings.Contains(body, "1,234") || !strings.Contains(body, languageName("fr")) {
+		t.Errorf("the table lacks the counts or the failed language: %s", body)
+	}
+
+	w = serve(handlerWithError(compareHandler), "/compare?q=+", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("empty query: status %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,665 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration that can be read from a config file as a string like "30s"
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+// Secret is a config value that must never end up in the logs.
+// It's redacted when the config is marshaled.
+type Secret string
+
+func (s Secret) MarshalText() ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	return []byte("REDACTED"), nil
+}
+
+func (s Secret) String() string {
+	text, _ := s.MarshalText()
+
+	return string(text)
+}
+
+// Config holds the settings of the application.
+// Values are resolved from the built-in defaults, then the config file, then environment variables,
+// each one overriding the previous.
+type Config struct {
+	Port               string   `json:"port" yaml:"port"`
+	DefaultLang        string   `json:"default_lang" yaml:"default_lang"`
+	PageSize           int      `json:"page_size" yaml:"page_size"`
+	SnippetLength      int      `json:"snippet_length" yaml:"snippet_length"`
+	MaxResultOffset    int      `json:"max_result_offset" yaml:"max_result_offset"` // the API serves no results past 10000
+	MaxURILength       int      `json:"max_uri_length" yaml:"max_uri_length"`
+	MaxBodySize        int      `json:"max_body_size" yaml:"max_body_size"` // in bytes
+	CombinedSearch     bool     `json:"combined_search" yaml:"combined_search"`
+	ServerTiming       bool     `json:"server_timing" yaml:"server_timing"` // reveals the timings of the searches to clients
+	Debug              bool     `json:"debug" yaml:"debug"`                 // enables the debugging aids, never in production
+	TrustedProxies     []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+	CacheTTL           Duration `json:"cache_ttl" yaml:"cache_ttl"`
+	CacheStaleTTL      Duration `json:"cache_stale_ttl" yaml:"cache_stale_ttl"`
+	CacheRefreshAhead  Duration `json:"cache_refresh_ahead" yaml:"cache_refresh_ahead"` // see refreshAhead, 0 disables it
+	CacheMaxEntries    int      `json:"cache_max_entries" yaml:"cache_max_entries"`
+	WarmupQueries      []string `json:"warmup_queries" yaml:"warmup_queries"` // searched at startup to fill the cache
+	PrefetchNextPage   bool     `json:"prefetch_next_page" yaml:"prefetch_next_page"`
+	BreakerThreshold   int      `json:"breaker_threshold" yaml:"breaker_threshold"`
+	BreakerCooldown    Duration `json:"breaker_cooldown" yaml:"breaker_cooldown"`
+	CORSAllowedOrigins []string `json:"cors_allowed_origins" yaml:"cors_allowed_origins"`
+	RequestTimeout     Duration `json:"request_timeout" yaml:"request_timeout"`
+	MaxRequestTimeout  Duration `json:"max_request_timeout" yaml:"max_request_timeout"`
+
+	// timeouts of the HTTP server, WriteTimeout must leave room for MaxRequestTimeout
+	ReadHeaderTimeout Duration `json:"read_header_timeout" yaml:"read_header_timeout"`
+	ReadTimeout       Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout      Duration `json:"write_timeout" yaml:"write_timeout"`
+	IdleTimeout       Duration `json:"idle_timeout" yaml:"idle_timeout"`
+
+	// ExportMaxRows is the maximum number of results of an export, within MaxResultOffset
+	ExportMaxRows int `json:"export_max_rows" yaml:"export_max_rows"`
+
+	// WikipediaTimeout bounds every attempt of a request to the Wikipedia API so that a slow upstream
+	// doesn't hold connections open. The request deadline wins when it's sooner.
+	WikipediaTimeout Duration `json:"wikipedia_timeout" yaml:"wikipedia_timeout"`
+
+	// AutocompleteTimeout is kept short as suggestions are useless once the user has typed on
+	AutocompleteTimeout  Duration `json:"autocomplete_timeout" yaml:"autocomplete_timeout"`
+	AutocompleteCacheTTL Duration `json:"autocomplete_cache_ttl" yaml:"autocomplete_cache_ttl"`
+
+	PublicBaseURL string `json:"public_base_url" yaml:"public_base_url"`
+
+	// TemplateDir and AssetsDir read the templates and assets from disk instead of
+	// the copies embedded in the binary, which are used when they're empty
+	TemplateDir string `json:"template_dir" yaml:"template_dir"`
+	AssetsDir   string `json:"assets_dir" yaml:"assets_dir"`
+
+	// AssetExtensions are the extensions of the files served from the assets, others get a 404
+	AssetExtensions []string `json:"asset_extensions" yaml:"asset_extensions"`
+
+	// WikipediaMaxRetries is the number of retries of a failed Wikipedia request. Retries across
+	// all the requests are limited by a budget of RetryBudget, refilled by one every RetryBudgetRefill.
+	WikipediaMaxRetries int      `json:"wikipedia_max_retries" yaml:"wikipedia_max_retries"`
+	RetryBudget         int      `json:"retry_budget" yaml:"retry_budget"`
+	RetryBudgetRefill   Duration `json:"retry_budget_refill" yaml:"retry_budget_refill"`
+
+	// MaxConcurrentRequests caps the requests handled at once, the others wait up to
+	// ConcurrencyQueueTimeout before being rejected with a 503. 0 disables the limit.
+	MaxConcurrentRequests   int      `json:"max_concurrent_requests" yaml:"max_concurrent_requests"`
+	ConcurrencyQueueTimeout Duration `json:"concurrency_queue_timeout" yaml:"concurrency_queue_timeout"`
+
+	// RateLimitBurst is the number of requests a client IP can send at once, refilled by one every
+	// RateLimitRefill. 0 disables the rate limit.
+	RateLimitBurst  int      `json:"rate_limit_burst" yaml:"rate_limit_burst"`
+	RateLimitRefill Duration `json:"rate_limit_refill" yaml:"rate_limit_refill"`
+
+	// WikipediaMaxLag is the maxlag parameter of the requests, in seconds: Wikipedia asks to retry later
+	// when its database replication lag is higher. 0 leaves it out.
+	WikipediaMaxLag int `json:"wikipedia_max_lag" yaml:"wikipedia_max_lag"`
+
+	// WikipediaMaxResponseSize caps the bytes read from a response of the Wikipedia API,
+	// so that an oversized response can't exhaust the memory of the server
+	WikipediaMaxResponseSize int `json:"wikipedia_max_response_size" yaml:"wikipedia_max_response_size"`
+
+	// HighlightClass is the class of the elements wrapping the matches in snippets and titles,
+	// Wikipedia's searchmatch class is renamed to it so the styles don't depend on Wikipedia's markup
+	HighlightClass string `json:"highlight_class" yaml:"highlight_class"`
+
+	// Pageviews shows the views of each result over the last 30 days, from the Wikimedia pageviews API.
+	// It costs a request per result.
+	Pageviews bool `json:"pageviews" yaml:"pageviews"`
+
+	// SafeSearchExcludedCategories are the categories whose pages are left out of the safe searches,
+	// which are also restricted to the articles
+	SafeSearchExcludedCategories []string `json:"safe_search_excluded_categories" yaml:"safe_search_excluded_categories"`
+
+	// Features switch the optional features on and off
+	Features Features `json:"features" yaml:"features"`
+
+	// LinksNewTab opens the links to articles and other sites in a new tab
+	LinksNewTab bool `json:"links_new_tab" yaml:"links_new_tab"`
+
+	// MaintenanceMode starts the app in maintenance mode, see maintenance
+	MaintenanceMode bool `json:"maintenance_mode" yaml:"maintenance_mode"`
+
+	// JSONP enables the callback parameter of /api/search for clients that can't use CORS.
+	// Any site can then read the API responses, like with a "*" CORS origin.
+	JSONP bool `json:"jsonp" yaml:"jsonp"`
+
+	// WikipediaAccessToken is an optional Wikimedia OAuth 2 access token for higher rate limits,
+	// requests are sent to WikipediaAuthAPIURL when it's set, where {lang} is replaced by the language edition
+	// and {project} by the Wikimedia project
+	WikipediaAccessToken Secret `json:"wikipedia_access_token" yaml:"wikipedia_access_token"`
+	WikipediaAuthAPIURL  string `json:"wikipedia_auth_api_url" yaml:"wikipedia_auth_api_url"`
+
+	// AdminToken is the shared secret of the admin endpoints, sent in the X-Admin-Token header.
+	// They're disabled when it's not set.
+	AdminToken Secret `json:"admin_token" yaml:"admin_token"`
+
+	// BasicAuthUser and BasicAuthPass gate the whole site behind HTTP Basic Auth when they're set
+	BasicAuthUser string `json:"basic_auth_user" yaml:"basic_auth_user"`
+	BasicAuthPass Secret `json:"basic_auth_pass" yaml:"basic_auth_pass"`
+
+	// CompareLanguages are the language editions compared by /compare
+	CompareLanguages []string `json:"compare_languages" yaml:"compare_languages"`
+
+	// ResultProcessors are the names of the processors run over every result, in order, see resultProcessors
+	ResultProcessors []string `json:"result_processors" yaml:"result_processors"`
+
+	// ServerInfo adds the time a search was served and the ID of the instance that served it
+	// to the responses, to tell which replica answered behind a load balancer or a cache.
+	// InstanceID defaults to the host name.
+	ServerInfo bool   `json:"server_info" yaml:"server_info"`
+	InstanceID string `json:"instance_id" yaml:"instance_id"`
+
+	// trustedProxies is TrustedProxies parsed by validate
+	trustedProxies []netip.Prefix
+
+	// resultPipeline is ResultProcessors resolved by validate
+	resultPipeline resultPipeline
+
+	// instanceID is InstanceID or its default, set by validate
+	instanceID string
+}
+
+// MarshalZerologObject logs every setting as a field named after its config file key.
+// Secrets are redacted.
+func (c Config) MarshalZerologObject(e *zerolog.Event) {
+	v := reflect.ValueOf(c)
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+		switch value := v.Field(i).Interface().(type) {
+		case Secret:
+			e.Str(key, value.String())
+		case Duration:
+			e.Str(key, time.Duration(value).String())
+		case string:
+			e.Str(key, value)
+		case int:
+			e.Int(key, value)
+		case bool:
+			e.Bool(key, value)
+		case []string:
+			e.Strs(key, value)
+		default:
+			e.Interface(key, value)
+		}
+	}
+}
+
+func defaultConfig() Config {
+	return Config{
+		Port:              "3001",
+		DefaultLang:       defaultLang,
+		PageSize:          20,
+		SnippetLength:     200,
+		MaxResultOffset:   10000,
+		MaxURILength:      2048,
+		MaxBodySize:       1 << 20,
+		CacheTTL:          Duration(5 * time.Minute),
+		CacheStaleTTL:     Duration(time.Hour),
+		CacheMaxEntries:   1000,
+		BreakerThreshold:  5,
+		BreakerCooldown:   Duration(30 * time.Second),
+		RequestTimeout:    Duration(30 * time.Second),
+		MaxRequestTimeout: Duration(60 * time.Second),
+
+		ReadHeaderTimeout: Duration(5 * time.Second),
+		ReadTimeout:       Duration(15 * time.Second),
+		WriteTimeout:      Duration(75 * time.Second),
+		IdleTimeout:       Duration(120 * time.Second),
+
+		ExportMaxRows: 500,
+
+		HighlightClass: wikipediaHighlightClass,
+		LinksNewTab:    true,
+
+		Features: defaultFeatures(),
+
+		WikipediaTimeout: Duration(10 * time.Second),
+
+		AutocompleteTimeout:  Duration(2 * time.Second),
+		AutocompleteCacheTTL: Duration(time.Hour),
+
+		WikipediaAuthAPIURL: wikipediaAPIURL,
+
+		AssetExtensions: []string{
+			".css", ".js", ".map", ".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg", ".ico", ".woff", ".woff2",
+		},
+
+		WikipediaMaxRetries: 2,
+		RetryBudget:         10,
+		RetryBudgetRefill:   Duration(time.Second),
+		WikipediaMaxLag:     5,
+		RateLimitRefill:     Duration(time.Second),
+
+		ConcurrencyQueueTimeout: Duration(100 * time.Millisecond),
+
+		WikipediaMaxResponseSize: 8 << 20,
+
+		ResultProcessors: defaultResultProcessors,
+		CompareLanguages: []string{"en", "de", "fr", "es", "it", "ja"},
+	}
+}
+
+// cssClassPattern matches a class name that can be used as is in HTML and in a CSS selector
+var cssClassPattern = regexp.MustCompile(`^-?[_a-zA-Z][_a-zA-Z0-9-]*$`)
+
+// configFiles are the files looked up in the working directory when CONFIG_FILE isn't set
+var configFiles = []string{"config.yaml", "config.yml", "config.json"}
+
+// loadConfig merges the defaults, the config file (if any) and the environment variables
+// into a validated Config
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		for _, f := range configFiles {
+			if _, err := os.Stat(f); err == nil {
+				path = f
+				break
+			}
+		}
+	}
+
+	if path != "" {
+		err := cfg.readFile(path)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	err := cfg.readEnv()
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".json":
+		err = json.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("unsupported config file format '%s'", path)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to parse config file '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) readEnv() error {
+	envString("PORT", &c.Port)
+	envString("PUBLIC_BASE_URL", &c.PublicBaseURL)
+	envString("TEMPLATE_DIR", &c.TemplateDir)
+	envString("ASSETS_DIR", &c.AssetsDir)
+	envString("DEFAULT_LANG", &c.DefaultLang)
+	envString("WIKIPEDIA_AUTH_API_URL", &c.WikipediaAuthAPIURL)
+	envString("HIGHLIGHT_CLASS", &c.HighlightClass)
+	envString("INSTANCE_ID", &c.InstanceID)
+
+	if v := os.Getenv("WIKIPEDIA_ACCESS_TOKEN"); v != "" {
+		c.WikipediaAccessToken = Secret(v)
+	}
+	if v := os.Getenv("ADMIN_TOKEN"); v != "" {
+		c.AdminToken = Secret(v)
+	}
+	envString("BASIC_AUTH_USER", &c.BasicAuthUser)
+	if v := os.Getenv("BASIC_AUTH_PASS"); v != "" {
+		c.BasicAuthPass = Secret(v)
+	}
+	envList("CORS_ALLOWED_ORIGINS", &c.CORSAllowedOrigins)
+	envList("TRUSTED_PROXIES", &c.TrustedProxies)
+	envList("WARMUP_QUERIES", &c.WarmupQueries)
+	envList("ASSET_EXTENSIONS", &c.AssetExtensions)
+	envList("SAFE_SEARCH_EXCLUDED_CATEGORIES", &c.SafeSearchExcludedCategories)
+	envList("RESULT_PROCESSORS", &c.ResultProcessors)
+	envList("COMPARE_LANGUAGES", &c.CompareLanguages)
+
+	for _, err := range []error{
+		envInt("PAGE_SIZE", &c.PageSize),
+		envInt("SNIPPET_LENGTH", &c.SnippetLength),
+		envInt("MAX_RESULT_OFFSET", &c.MaxResultOffset),
+		envInt("MAX_URI_LENGTH", &c.MaxURILength),
+		envInt("MAX_BODY_SIZE", &c.MaxBodySize),
+		envInt("EXPORT_MAX_ROWS", &c.ExportMaxRows),
+		envBool("COMBINED_SEARCH", &c.CombinedSearch),
+		envBool("SERVER_TIMING", &c.ServerTiming),
+		envBool("DEBUG", &c.Debug),
+		envBool("SERVER_INFO", &c.ServerInfo),
+		envBool("JSONP", &c.JSONP),
+		envBool("MAINTENANCE_MODE", &c.MaintenanceMode),
+		envBool("LINKS_NEW_TAB", &c.LinksNewTab),
+		envBool("PAGEVIEWS", &c.Pageviews),
+		envBool("PREFETCH_NEXT_PAGE", &c.PrefetchNextPage),
+		envDuration("CACHE_TTL", &c.CacheTTL),
+		envDuration("CACHE_STALE_TTL", &c.CacheStaleTTL),
+		envDuration("CACHE_REFRESH_AHEAD", &c.CacheRefreshAhead),
+		envInt("CACHE_MAX_ENTRIES", &c.CacheMaxEntries),
+		envInt("WIKIPEDIA_MAX_RETRIES", &c.WikipediaMaxRetries),
+		envInt("RETRY_BUDGET", &c.RetryBudget),
+		envDuration("RETRY_BUDGET_REFILL", &c.RetryBudgetRefill),
+		envInt("RATE_LIMIT_BURST", &c.RateLimitBurst),
+		envDuration("RATE_LIMIT_REFILL", &c.RateLimitRefill),
+		envInt("MAX_CONCURRENT_REQUESTS", &c.MaxConcurrentRequests),
+		envDuration("CONCURRENCY_QUEUE_TIMEOUT", &c.ConcurrencyQueueTimeout),
+		envInt("WIKIPEDIA_MAX_LAG", &c.WikipediaMaxLag),
+		envInt("WIKIPEDIA_MAX_RESPONSE_SIZE", &c.WikipediaMaxResponseSize),
+		envInt("BREAKER_THRESHOLD", &c.BreakerThreshold),
+		envDuration("BREAKER_COOLDOWN", &c.BreakerCooldown),
+		envDuration("REQUEST_TIMEOUT", &c.RequestTimeout),
+		envDuration("MAX_REQUEST_TIMEOUT", &c.MaxRequestTimeout),
+		envDuration("READ_HEADER_TIMEOUT", &c.ReadHeaderTimeout),
+		envDuration("READ_TIMEOUT", &c.ReadTimeout),
+		envDuration("WRITE_TIMEOUT", &c.WriteTimeout),
+		envDuration("IDLE_TIMEOUT", &c.IdleTimeout),
+		envDuration("WIKIPEDIA_TIMEOUT", &c.WikipediaTimeout),
+		envDuration("AUTOCOMPLETE_TIMEOUT", &c.AutocompleteTimeout),
+		envDuration("AUTOCOMPLETE_CACHE_TTL", &c.AutocompleteCacheTTL),
+		envBool("FEATURE_CACHE", &c.Features.Cache),
+		envBool("FEATURE_EXTRACTS", &c.Features.Extracts),
+		envBool("FEATURE_THUMBNAILS", &c.Features.Thumbnails),
+		envBool("FEATURE_INTERWIKI", &c.Features.Interwiki),
+		envBool("FEATURE_GEO", &c.Features.Geo),
+		envBool("FEATURE_EXPORT", &c.Features.Export),
+		envBool("FEATURE_AUTOCOMPLETE", &c.Features.Autocomplete),
+		envBool("FEATURE_STATS", &c.Features.Stats),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validate() error {
+	var problems []string
+
+	if _, err := strconv.Atoi(c.Port); err != nil {
+		problems = append(problems, fmt.Sprintf("invalid port '%s'", c.Port))
+	}
+
+	if !isSupportedLanguage(c.DefaultLang) {
+		problems = append(problems, fmt.Sprintf("unsupported default language '%s'", c.DefaultLang))
+	}
+
+	// 500 is the maximum srlimit accepted by the Wikipedia API
+	if c.PageSize < 1 || c.PageSize > 500 {
+		problems = append(problems, fmt.Sprintf("page size must be between 1 and 500, got %d", c.PageSize))
+	}
+
+	// the pages of a combined search are numbered like the others, so they must be the same size
+	if (c.CombinedSearch || c.Features.Extracts) && c.PageSize > combinedSearchMaxLimit {
+		problems = append(problems, fmt.Sprintf("page size can't exceed %d with the combined search or the extracts feature", combinedSearchMaxLimit))
+	}
+
+	if c.MaxResultOffset < c.PageSize {
+		problems = append(problems, fmt.Sprintf("max result offset must be at least the page size, got %d", c.MaxResultOffset))
+	}
+
+	if c.MaxURILength < 1 {
+		problems = append(problems, fmt.Sprintf("max URI length must be positive, got %d", c.MaxURILength))
+	}
+
+	if c.MaxBodySize < 1 {
+		problems = append(problems, fmt.Sprintf("max body size must be positive, got %d", c.MaxBodySize))
+	}
+
+	if c.WikipediaMaxResponseSize < 1 {
+		problems = append(problems, fmt.Sprintf("Wikipedia max response size must be positive, got %d", c.WikipediaMaxResponseSize))
+	}
+
+	if c.SnippetLength < 0 {
+		problems = append(problems, fmt.Sprintf("snippet length can't be negative, got %d", c.SnippetLength))
+	}
+
+	if c.CacheTTL < 0 || c.CacheStaleTTL < 0 {
+		problems = append(problems, "cache TTLs can't be negative")
+	}
+
+	if c.CacheRefreshAhead < 0 || (c.CacheRefreshAhead > 0 && c.CacheRefreshAhead >= c.CacheTTL) {
+		problems = append(problems, "cache refresh ahead window must be shorter than the cache TTL")
+	}
+
+	if c.CacheMaxEntries < 1 {
+		problems = append(problems, fmt.Sprintf("cache max entries must be at least 1, got %d", c.CacheMaxEntries))
+	}
+
+	if c.WikipediaMaxRetries < 0 || c.RetryBudget < 0 || c.WikipediaMaxLag < 0 {
+		problems = append(problems, "max retries, retry budget and max lag can't be negative")
+	}
+
+	if c.RetryBudgetRefill <= 0 {
+		problems = append(problems, "retry budget refill must be positive")
+	}
+
+	if c.MaxConcurrentRequests < 0 || c.ConcurrencyQueueTimeout < 0 {
+		problems = append(problems, "max concurrent requests and concurrency queue timeout can't be negative")
+	}
+
+	if c.RateLimitBurst < 0 {
+		problems = append(problems, fmt.Sprintf("rate limit burst can't be negative, got %d", c.RateLimitBurst))
+	}
+
+	if c.RateLimitBurst > 0 && c.RateLimitRefill <= 0 {
+		problems = append(problems, "rate limit refill must be positive")
+	}
+
+	if c.BreakerThreshold < 1 {
+		problems = append(problems, fmt.Sprintf("breaker threshold must be at least 1, got %d", c.BreakerThreshold))
+	}
+
+	if c.BreakerCooldown <= 0 {
+		problems = append(problems, "breaker cooldown must be positive")
+	}
+
+	if c.RequestTimeout <= 0 || c.MaxRequestTimeout < c.RequestTimeout {
+		problems = append(problems, "request timeout must be positive and not exceed the max request timeout")
+	}
+
+	if c.ReadHeaderTimeout <= 0 || c.ReadTimeout <= 0 || c.IdleTimeout <= 0 {
+		problems = append(problems, "read header, read and idle timeouts must be positive")
+	}
+
+	// a request allowed to run for MaxRequestTimeout would otherwise have its response cut off
+	if c.WriteTimeout <= c.MaxRequestTimeout {
+		problems = append(problems, "write timeout must exceed the max request timeout")
+	}
+
+	if !cssClassPattern.MatchString(c.HighlightClass) {
+		problems = append(problems, fmt.Sprintf("invalid highlight class '%s'", c.HighlightClass))
+	}
+
+	// the categories are quoted in the search string
+	for _, category := range c.SafeSearchExcludedCategories {
+		if strings.TrimSpace(category) == "" || strings.ContainsAny(category, `"\`) {
+			problems = append(problems, fmt.Sprintf("invalid safe search excluded category '%s'", category))
+		}
+	}
+
+	for _, lang := range c.CompareLanguages {
+		if !isSupportedLanguage(lang) {
+			problems = append(problems, fmt.Sprintf("unsupported compare language '%s'", lang))
+		}
+	}
+
+	if c.ExportMaxRows <= 0 {
+		problems = append(problems, "export max rows must be positive")
+	}
+
+	if c.WikipediaTimeout <= 0 || c.WikipediaTimeout >= c.WriteTimeout {
+		problems = append(problems, "wikipedia timeout must be positive and shorter than the write timeout")
+	}
+
+	if c.AutocompleteTimeout <= 0 {
+		problems = append(problems, "autocomplete timeout must be positive")
+	}
+
+	if c.PublicBaseURL != "" {
+		u, err := url.Parse(c.PublicBaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			problems = append(problems, fmt.Sprintf("invalid public base URL '%s'", c.PublicBaseURL))
+		}
+	}
+
+	if c.WikipediaAccessToken != "" {
+		u, err := url.Parse(siteURL(c.WikipediaAuthAPIURL, defaultProject, c.DefaultLang))
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("invalid authenticated Wikipedia API URL '%s'", c.WikipediaAuthAPIURL))
+		}
+	}
+
+	if (c.BasicAuthUser == "") != (c.BasicAuthPass == "") {
+		problems = append(problems, "basic auth user and password must be set together")
+	}
+
+	trustedProxies, err := parseTrustedProxies(c.TrustedProxies)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	c.trustedProxies = trustedProxies
+
+	resultPipeline, err := newResultPipeline(c.ResultProcessors)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	c.resultPipeline = resultPipeline
+
+	c.instanceID = c.InstanceID
+	if c.instanceID == "" {
+		c.instanceID = defaultInstanceID()
+	} else if !instanceIDPattern.MatchString(c.instanceID) {
+		problems = append(problems, fmt.Sprintf("invalid instance ID '%s'", c.InstanceID))
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func envString(key string, dst *string) {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		*dst = v
+	}
+}
+
+func envList(key string, dst *[]string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	*dst = list
+}
+
+func envInt(key string, dst *int) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s '%s': %w", key, v, err)
+	}
+
+	*dst = n
+
+	return nil
+}
+
+func envBool(key string, dst *bool) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s '%s': %w", key, v, err)
+	}
+
+	*dst = b
+
+	return nil
+}
+
+func envDuration(key string, dst *Duration) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+
+	err := dst.UnmarshalText([]byte(v))
+	if err != nil {
+		return fmt.Errorf("invalid %s '%s': %w", key, v, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// embedded holds the templates and static assets compiled into the binary, so that it runs from any directory.
+// They're read from disk instead when TEMPLATE_DIR or ASSETS_DIR is set, to edit them without rebuilding.
+//
+//go:embed index.html results.html category.html bookmarks.html notfound.html compare.html maintenance.html assets
+var embedded embed.FS
+
+// assets is the filesystem of the static assets served under /assets/
+var assets fs.FS
+
+// templateFS returns the filesystem the templates are parsed from: dir if set, the embedded files otherwise
+func templateFS(dir string) (fs.FS, error) {
+	if dir == "" {
+		return embedded, nil
+	}
+
+	err := checkDir(dir, "TEMPLATE_DIR")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range templateFiles {
+		err = checkFile(filepath.Join(dir, name), "TEMPLATE_DIR")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return os.DirFS(dir), nil
+}
+
+// assetsFS returns the filesystem of the static assets: dir if set, the embedded files otherwise
+func assetsFS(dir string) (fs.FS, error) {
+	if dir == "" {
+		return fs.Sub(embedded, "assets")
+	}
+
+	err := checkDir(dir, "ASSETS_DIR")
+	if err != nil {
+		return nil, err
+	}
+
+	return os.DirFS(dir), nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"net/http"
+	"testing"
+)
+
+func TestEmbeddedTemplatesParse(t *testing.T) {
+	tmpl, err := loadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range templateFiles {
+		if tmpl.Lookup(name) == nil {
+			t.Errorf("the embedded templates miss %s", name)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "index.html", sampleSearch(1234)); err != nil {
+		t.Errorf("the embedded index fails to render: %v", err)
+	}
+}
+
+func TestEmbeddedAssets(t *testing.T) {
+	fsys, err := assetsFS("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Stat(fsys, "style.css"); err != nil {
+		t.Errorf("the embedded assets miss style.css: %v", err)
+	}
+
+	w := serve(newMux(), "/assets/style.css", nil)
+	if w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Errorf("GET /assets/style.css: status %d with %d bytes, want the stylesheet", w.Code, w.Body.Len())
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// enrichmentReserve is the part of the request deadline the enrichments leave
+// to render the results, so that they're served in time when the enrichments are cut short
+const enrichmentReserve = 100 * time.Millisecond
+
+// enrichmentBudget is the time budget shared by the enrichments of a search (coordinates, views,
+// namespace counts), which each make their own calls to Wikimedia. They run on a single context that
+// ends enrichmentReserve before the request deadline: the ones that don't fit are skipped
+// and the results are served without them.
+type enrichmentBudget struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	skipped []string
+}
+
+func newEnrichmentBudget(ctx context.Context) *enrichmentBudget {
+	b := &enrichmentBudget{}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		b.ctx, b.cancel = context.WithDeadline(ctx, deadline.Add(-enrichmentReserve))
+	} else {
+		b.ctx, b.cancel = context.WithCancel(ctx)
+	}
+
+	return b
+}
+
+// Run runs the enrichment fn on the budget context unless the budget is spent.
+// fn returns an error when some of its calls were cut short by the budget, see cutShort,
+// and reports its other failures itself. The enrichment is recorded as skipped when it didn't
+// run or didn't complete, and Run reports false: one that completed just before the deadline is done.
+func (b *enrichmentBudget) Run(name string, fn func(ctx context.Context) error) bool {
+	if b.ctx.Err() != nil {
+		b.skipped = append(b.skipped, name)
+		return false
+	}
+
+	if err := fn(b.ctx); err != nil {
+		b.skipped = append(b.skipped, name)
+		return false
+	}
+
+	return true
+}
+
+// cutShort reports whether err, returned by a call made on ctx, is due to ctx ending
+// rather than to a failure of the API
+func cutShort(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() != nil
+}
+
+// Close releases the budget context and logs the enrichments that were skipped
+func (b *enrichmentBudget) Close(l *zerolog.Logger) {
+	b.cancel()
+
+	if len(b.skipped) > 0 {
+		l.Warn().
+			Strs("skipped_enrichments", b.skipped).
+			Msg("out of time for some enrichments, serving the results without them")
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestEnrichmentBudgetRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), enrichmentReserve+20*time.Millisecond)
+	defer cancel()
+
+	budget := newEnrichmentBudget(ctx)
+	defer budget.cancel()
+
+	// completes, though the budget runs out right after
+	done := budget.Run("complete", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	if !done {
+		t.Error("an enrichment that completed is reported as skipped")
+	}
+
+	// the budget is spent, it doesn't run
+	ran := false
+	done = budget.Run("late", func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	if done || ran {
+		t.Errorf("an enrichment ran (%t) or is done (%t) past the budget", ran, done)
+	}
+
+	if len(budget.skipped) != 1 || budget.skipped[0] != "late" {
+		t.Errorf("skipped = %q, want late", budget.skipped)
+	}
+}
+
+func TestEnrichmentBudgetCutShort(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), enrichmentReserve+20*time.Millisecond)
+	defer cancel()
+
+	budget := newEnrichmentBudget(ctx)
+	defer budget.cancel()
+
+	// cut short by the budget
+	done := budget.Run("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if done || len(budget.skipped) != 1 {
+		t.Errorf("an enrichment cut short is done (%t), skipped = %q", done, budget.skipped)
+	}
+
+	// a failure of the API isn't running out of time
+	err := errors.New("unavailable")
+	if cutShort(context.Background(), err) || !cutShort(budget.ctx, err) || cutShort(budget.ctx, nil) {
+		t.Error("cutShort doesn't tell the failures due to the budget")
+	}
+}
+
+func TestSlowEnrichmentIsDropped(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Pageviews = true
+	})
+
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		// the pageviews never come
+		if r.URL.Host == "wikimedia.org" {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		}
+
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	var logs bytes.Buffer
+	l := zerolog.New(&logs)
+
+	ctx, cancel := context.WithTimeout(l.WithContext(context.Background()), enrichmentReserve+200*time.Millisecond)
+	defer cancel()
+
+	r := httptest.NewRequest(http.MethodGet, "/search?q=go&format=json", nil).WithContext(ctx)
+
+	start := time.Now()
+
+	w := httptest.NewRecorder()
+	handlerWithError(searchHandler).ServeHTTP(w, r)
+
+	if elapsed := time.Since(start); elapsed > enrichmentReserve+200*time.Millisecond {
+		t.Errorf("answered in %s, past the request deadline", elapsed)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	var s Search
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Results.Query.Search) != 3 {
+		t.Errorf("%d results, want them all without their views", len(s.Results.Query.Search))
+	}
+
+	if len(s.Warnings) != 1 || s.Warnings[0] != "The views were left out to answer in time." {
+		t.Errorf("warnings = %q", s.Warnings)
+	}
+
+	if !strings.Contains(logs.String(), `"skipped_enrichments":["views"]`) {
+		t.Errorf("the skipped enrichment isn't logged: %s", logs.String())
+	}
+
+	if strings.Contains(logs.String(), "unable to fetch the pageviews") {
+		t.Error("running out of time is logged as a failure of the API")
+	}
+}
+
+func TestFailedEnrichmentWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		geo      bool
+		views    bool
+		warnings []string
+	}{
+		{"coordinates", true, false, []string{"The map links are unavailable."}},
+		{"pageviews", false, true, []string{"The views of 3 results are unavailable."}},
+		{"both", true, true, []string{"The map links are unavailable.", "The views of 3 results are unavailable."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.Pageviews = true
+				c.WikipediaMaxRetries = 0
+			})
+
+			stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+				failing := tt.views && r.URL.Host == "wikimedia.org" ||
+					tt.geo && r.URL.Query().Get("prop") == "coordinates"
+				if failing {
+					resp := jsonResponse(r, `{}`)
+					resp.StatusCode = http.StatusInternalServerError
+
+					return resp, nil
+				}
+				if r.URL.Host == "wikimedia.org" {
+					return jsonResponse(r, `{"items": [{"views": 10}]}`), nil
+				}
+				if r.URL.Query().Get("prop") == "coordinates" {
+					return jsonResponse(r, `{"query": {"pages": []}}`), nil
+				}
+
+				return jsonResponse(r, searchResponseJSON), nil
+			})
+
+			w := serve(handlerWithError(searchHandler), "/search?q=go&geo=1&format=json", nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d, want the results despite the failure: %s", w.Code, w.Body)
+			}
+
+			var s Search
+			if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(s.Results.Query.Search) != 3 {
+				t.Errorf("%d results, want them all", len(s.Results.Query.Search))
+			}
+
+			if strings.Join(s.Warnings, "\n") != strings.Join(tt.warnings, "\n") {
+				t.Errorf("warnings = %q, want %q", s.Warnings, tt.warnings)
+			}
+
+			body := serve(handlerWithError(searchHandler), "/search?q=go&geo=1", nil).Body.String()
+			for _, warning := range tt.warnings {
+				if !strings.Contains(body, "<li>"+warning+"</li>") {
+					t.Errorf("the page doesn't show the warning %q", warning)
+				}
+			}
+		})
+	}
+}
+
+func TestNoWarningsWithoutFailures(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Pageviews = true
+	})
+
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == "wikimedia.org" {
+			return jsonResponse(r, `{"items": [{"views": 10}]}`), nil
+		}
+
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go", nil)
+	if strings.Contains(w.Body.String(), "results-warnings") {
+		t.Error("warnings shown without any failure")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// errorLogInterval is how often an error that keeps happening is logged again
+const errorLogInterval = time.Minute
+
+// maxErrorKeyLen bounds the size of the keys kept by the errorSampler
+const maxErrorKeyLen = 256
+
+// maxSampledErrors caps the distinct errors an errorSampler keeps track of at once.
+// Past it, the entry closest to the end of its interval makes room for the new one.
+const maxSampledErrors = 1000
+
+type sampledError struct {
+	loggedAt   time.Time
+	suppressed int
+}
+
+// errorSampler logs the first occurrence of an error, then at most one summary per interval
+// with the number of identical errors suppressed in between,
+// so that an upstream outage doesn't flood the logs with one line per request.
+type errorSampler struct {
+	mu       sync.Mutex
+	interval time.Duration
+	seen     map[string]*sampledError
+}
+
+func newErrorSampler(interval time.Duration) *errorSampler {
+	return &errorSampler{
+		interval: interval,
+		seen:     make(map[string]*sampledError),
+	}
+}
+
+var errorLogs = newErrorSampler(errorLogInterval)
+
+// Log logs err at error level on l unless an identical error was already logged in the current interval.
+// Only server-side errors are to be sampled: the messages of client errors carry user input,
+// which would make each of them a key of its own.
+func (s *errorSampler) Log(l *zerolog.Logger, err error) {
+	ok, suppressed := s.allow(l, errorKey(err))
+	if !ok {
+		return
+	}
+
+	e := l.Error().Err(err)
+	if suppressed > 0 {
+		e = e.Int("suppressed_count", suppressed).
+			Dur("suppressed_window_ms", s.interval)
+	}
+
+	e.Msg("request failed")
+}
+
+// allow reports whether an occurrence of key is to be logged, i.e. it wasn't in the current interval,
+// along with the number of occurrences suppressed since it last was.
+// The counts of the entries dropped by prune are logged on l.
+func (s *errorSampler) allow(l *zerolog.Logger, key string) (ok bool, suppressed int) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, seen := s.seen[key]
+	if seen && now.Sub(entry.loggedAt) < s.interval {
+		entry.suppressed++
+
+		return false, 0
+	}
+
+	if seen {
+		suppressed = entry.suppressed
+		delete(s.seen, key)
+	}
+
+	s.prune(l, now)
+
+	if len(s.seen) >= maxSampledErrors {
+		s.evictOldest()
+	}
+
+	s.seen[key] = &sampledError{loggedAt: now}
+
+	return true, suppressed
+}
+
+// prune drops the entries whose interval is over, logging the occurrences they suppressed
+// as no summary would log them otherwise. Must be called with s.mu held.
+func (s *errorSampler) prune(l *zerolog.Logger, now time.Time) {
+	for key, entry := range s.seen {
+		if now.Sub(entry.loggedAt) < s.interval {
+			continue
+		}
+
+		if entry.suppressed > 0 {
+			l.Warn().
+				Str("sampled_key", key).
+				Int("suppressed_count", entry.suppressed).
+				Dur("suppressed_window_ms", s.interval).
+				Msg("repeats of a sampled log entry were suppressed")
+		}
+
+		delete(s.seen, key)
+	}
+}
+
+// evictOldest drops the entry logged the longest ago. Must be called with s.mu held.
+func (s *errorSampler) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+
+	for key, entry := range s.seen {
+		if oldestKey == "" || entry.loggedAt.Before(oldest) {
+			oldestKey, oldest = key, entry.loggedAt
+		}
+	}
+
+	delete(s.seen, oldestKey)
+}
+
+// errorKey identifies "identical" errors. The URL is left out of HTTP client errors
+// since it contains the search query and would make every error unique, and so is the dump
+// of the error responses of the Wikipedia API, whose Date header and body differ every time.
+func errorKey(err error) string {
+	key := err.Error()
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		key = fmt.Sprintf("%s: %v", urlErr.Op, urlErr.Err)
+	}
+
+	var statusErr *apiStatusError
+	if errors.As(err, &statusErr) {
+		key = fmt.Sprintf("wikipedia api status %d", statusErr.StatusCode)
+	}
+
+	if len(key) > maxErrorKeyLen {
+		key = key[:maxErrorKeyLen]
+	}
+
+	return key
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestErrorSamplerSuppressesRepeats(t *testing.T) {
+	var buf bytes.Buffer
+	l := zerolog.New(&buf)
+
+	s := newErrorSampler(50 * time.Millisecond)
+	err := errors.New("Wikipedia is down")
+
+	for i := 0; i < 5; i++ {
+		s.Log(&l, err)
+	}
+
+	if n := strings.Count(buf.String(), "request failed"); n != 1 {
+		t.Fatalf("logged %d times within the interval, want once:\n%s", n, buf.String())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	buf.Reset()
+	s.Log(&l, err)
+
+	if !strings.Contains(buf.String(), `"suppressed_count":4`) {
+		t.Errorf("summary after the interval doesn't count the 4 suppressed errors:\n%s", buf.String())
+	}
+}
+
+func TestErrorSamplerFlushesExpiredEntries(t *testing.T) {
+	var buf bytes.Buffer
+	l := zerolog.New(&buf)
+
+	s := newErrorSampler(50 * time.Millisecond)
+	s.Log(&l, errors.New("first"))
+	s.Log(&l, errors.New("first"))
+
+	time.Sleep(60 * time.Millisecond)
+	buf.Reset()
+	s.Log(&l, errors.New("second"))
+
+	if _, ok := s.seen["first"]; ok {
+		t.Error("the entry with suppressed repeats is kept past its interval")
+	}
+
+	if !strings.Contains(buf.String(), `"sampled_key":"first","suppressed_count":1`) {
+		t.Errorf("the suppressed repeat isn't logged when its entry is dropped:\n%s", buf.String())
+	}
+}
+
+func TestErrorSamplerIsCapped(t *testing.T) {
+	l := zerolog.Nop()
+	s := newErrorSampler(time.Hour)
+
+	for i := 0; i < maxSampledErrors+100; i++ {
+		s.Log(&l, fmt.Errorf("error %d", i))
+	}
+
+	if len(s.seen) > maxSampledErrors {
+		t.Errorf("%d errors tracked, want at most %d", len(s.seen), maxSampledErrors)
+	}
+}
+
+func TestErrorKeyLeavesOutURL(t *testing.T) {
+	a := &url.Error{Op: "Get", URL: "https://en.wikipedia.org/w/api.php?srsearch=go", Err: errors.New("timeout")}
+	b := &url.Error{Op: "Get", URL: "https://en.wikipedia.org/w/api.php?srsearch=rust", Err: errors.New("timeout")}
+
+	if errorKey(a) != errorKey(b) {
+		t.Errorf("errorKey() differs by URL: %q, %q", errorKey(a), errorKey(b))
+	}
+}
+
+func TestErrorKeyLeavesOutResponseDump(t *testing.T) {
+	var errs []error
+
+	for i, date := range []string{"Mon, 02 Jan 2023 15:04:05 GMT", "Mon, 02 Jan 2023 15:04:06 GMT"} {
+		stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+			resp := jsonResponse(r, fmt.Sprintf(`{"error": "overloaded", "request": %d}`, i))
+			resp.StatusCode = http.StatusServiceUnavailable
+			resp.Header.Set("Date", date)
+
+			return resp, nil
+		})
+
+		_, err := searchWikipedia(context.Background(), searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20})
+		if err == nil {
+			t.Fatal("no error for a 503")
+		}
+
+		errs = append(errs, err)
+	}
+
+	if errs[0].Error() == errs[1].Error() {
+		t.Fatal("the errors don't differ, the test doesn't exercise the key")
+	}
+
+	if a, b := errorKey(errs[0]), errorKey(errs[1]); a != b || a != "wikipedia api status 503" {
+		t.Errorf("errorKey() = %q, %q, want wikipedia api status 503 for both", a, b)
+	}
+}
+
+func TestClientErrorsAreNotSampled(t *testing.T) {
+	var buf bytes.Buffer
+	l := zerolog.New(&buf)
+
+	saved := errorLogs
+	errorLogs = newErrorSampler(time.Minute)
+	t.Cleanup(func() { errorLogs = saved })
+
+	h := handlerWithError(func(w http.ResponseWriter, r *http.Request) error {
+		return &statusError{http.StatusBadRequest, fmt.Errorf("invalid page '%s'", r.URL.Query().Get("page"))}
+	})
+
+	for _, page := range []string{"a", "b", "c"} {
+		r := httptest.NewRequest(http.MethodGet, "/search?page="+page, nil)
+		h.ServeHTTP(httptest.NewRecorder(), r.WithContext(l.WithContext(r.Context())))
+	}
+
+	if len(errorLogs.seen) != 0 {
+		t.Errorf("client errors are tracked by the sampler: %v", errorLogs.seen)
+	}
+
+	if strings.Contains(buf.String(), `"level":"error"`) {
+		t.Errorf("client errors logged at error level:\n%s", buf.String())
+	}
+
+	if n := strings.Count(buf.String(), `"level":"info"`); n != 3 {
+		t.Errorf("%d client errors logged at info level, want 3:\n%s", n, buf.String())
+	}
+}
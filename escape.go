@@ -0,0 +1,34 @@
+package main
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+)
+
+// attrNamePattern matches the attribute names attr renders, so that the name can't inject markup either
+var attrNamePattern = regexp.MustCompile(`^[a-z][a-z-]*$`)
+
+// attr renders the attribute name="value" with value escaped, for the attributes that echo user input
+// such as the query. html/template escapes a value it finds in a quoted attribute, but not one that ends up
+// outside of the quotes after an edit of the template: attr escapes the value itself and adds the quotes.
+// A name that isn't a plain attribute name renders nothing.
+func attr(name, value string) template.HTMLAttr {
+	if !attrNamePattern.MatchString(name) {
+		return ""
+	}
+
+	return template.HTMLAttr(name + `="` + html.EscapeString(value) + `"`)
+}
+
+// SnippetHTML returns the snippet truncated to n characters, see truncate, as HTML rendered as is.
+// The snippets are the only markup of Wikipedia rendered without escaping, for their highlights:
+// they never contain user input, whereas the query and the other fields are always escaped.
+func (r SearchResult) SnippetHTML(n int) template.HTML {
+	snippet := r.CleanSnippet
+	if snippet == "" {
+		snippet = r.Snippet
+	}
+
+	return template.HTML(truncate(snippet, n))
+}
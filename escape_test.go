@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAttr(t *testing.T) {
+	tests := []struct {
+		name, value string
+		want        string
+	}{
+		{"value", "go", `value="go"`},
+		{"value", `"><script>alert(1)</script>`, `value="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;"`},
+		{"value", `' onfocus='alert(1)`, `value="&#39; onfocus=&#39;alert(1)"`},
+		{"data-query", "a & b", `data-query="a &amp; b"`},
+		{`value="x" onclick`, "go", ""},
+		{"Value", "go", ""},
+		{"", "go", ""},
+	}
+
+	for _, tt := range tests {
+		if got := string(attr(tt.name, tt.value)); got != tt.want {
+			t.Errorf("attr(%q, %q) = %s, want %s", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+// maliciousQueries try to break out of the elements and attributes the query is echoed in
+var maliciousQueries = []string{
+	maliciousQuery,
+	`"><script>alert(1)</script>`,
+	`'><img src=x onerror=alert(1)>`,
+	`</strong><script>alert(1)</script>`,
+	`" autofocus onfocus="alert(1)`,
+	`{{ .Query }}<script>alert(1)</script>`,
+}
+
+// injected reports whether body contains the markup of one of the maliciousQueries
+func injected(body string) bool {
+	return strings.Contains(body, "<script>alert(1)") ||
+		strings.Contains(body, "<img src=x") ||
+		strings.Contains(body, `" autofocus onfocus="`)
+}
+
+func TestMaliciousQueries(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.CompareLanguages = []string{"en"}
+	})
+
+	for _, body := range []string{searchResponseJSON, `{"query": {"searchinfo": {"totalhits": 0}, "search": []}}`} {
+		stubSearch(t, body)
+
+		for _, query := range maliciousQueries {
+			q := url.QueryEscape(query)
+
+			targets := []string{
+				"/search?q=" + q,
+				"/search?q=go&exclude=" + q + "&add=" + q,
+				"/search/results?q=" + q,
+				"/compare?q=" + q,
+			}
+
+			for _, target := range targets {
+				w := serve(newMux(), target, nil)
+				if w.Code != http.StatusOK {
+					t.Fatalf("GET %s: status %d, want 200: %s", target, w.Code, w.Body)
+				}
+
+				if injected(w.Body.String()) {
+					t.Errorf("GET %s: the query is rendered as markup", target)
+				}
+			}
+		}
+	}
+}
+
+func TestMaliciousQueryInSearchBox(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	w := serve(handlerWithError(searchHandler), "/search?q="+url.QueryEscape(maliciousQuery), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	// the search box keeps the query as typed, escaped
+	if want := string(attr("value", maliciousQuery)); !strings.Contains(w.Body.String(), want) {
+		t.Errorf("the search box doesn't hold the escaped query %s", want)
+	}
+}
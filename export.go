@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog"
+)
+
+// exportPageSize is the number of results fetched from Wikipedia per request during an export
+const exportPageSize = 50
+
+// exportWriter writes the rows of an export in one of the export formats
+type exportWriter interface {
+	WriteResult(rank int, result SearchResult) error
+	// Flush sends the rows written so far, so the client gets them while the next page is fetched
+	Flush() error
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer) (*csvExportWriter, error) {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write([]string{"rank", "title", "url", "snippet"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &csvExportWriter{cw}, nil
+}
+
+func (e *csvExportWriter) WriteResult(rank int, result SearchResult) error {
+	return e.w.Write([]string{
+		strconv.Itoa(rank),
+		result.Title,
+		result.URL(),
+		result.plainSnippet(),
+	})
+}
+
+func (e *csvExportWriter) Flush() error {
+	e.w.Flush()
+
+	return e.w.Error()
+}
+
+// textExportWriter writes the layout of writeText without aligning the columns,
+// which would require the whole export in memory
+type textExportWriter struct {
+	w io.Writer
+}
+
+func (e *textExportWriter) WriteResult(rank int, result SearchResult) error {
+	_, err := fmt.Fprintf(e.w, "%d. %s\n   %s\n", rank, result.Title, result.URL())
+	if err != nil {
+		return err
+	}
+
+	if snippet := result.plainSnippet(); snippet != "" {
+		_, err = fmt.Fprintf(e.w, "   %s\n", snippet)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintln(e.w)
+
+	return err
+}
+
+func (e *textExportWriter) Flush() error {
+	return nil
+}
+
+// exportHandler serves /search/export, which takes the parameters of /search and streams
+// up to config.ExportMaxRows results as CSV (the default) or plain text with format=text.
+// The results are fetched page by page and sent as they arrive, so exports of any size
+// use the memory of a single page, and a client that disconnects stops the export.
+func exportHandler(w http.ResponseWriter, r *http.Request) error {
+	p, err := parseSearchParams(r.URL.Query(), r.Header.Get("Accept-Language"), config)
+	if err != nil {
+		return err
+	}
+
+	if p.Query == "" || p.PageID > 0 {
+		return &statusError{http.StatusBadRequest, errors.New("an export requires a search query")}
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "csv" && format != formatText {
+		return &statusError{
+			http.StatusBadRequest,
+			fmt.Errorf("unsupported export format '%s', expected csv or text", format),
+		}
+	}
+
+	maxRows := config.ExportMaxRows
+	if maxRows > config.MaxResultOffset {
+		maxRows = config.MaxResultOffset
+	}
+
+	ctx := r.Context()
+	l := zerolog.Ctx(ctx)
+
+	opts := p.options(exportPageSize)
+
+	var (
+		ew    exportWriter
+		flush = func() {}
+		rows  int
+	)
+
+	if f, ok := w.(http.Flusher); ok {
+		flush = f.Flush
+	}
+
+	for rows < maxRows {
+		opts.Offset = rows
+		if opts.Limit > maxRows-rows {
+			opts.Limit = maxRows - rows
+		}
+
+		var searchResponse *WikipediaSearchResponse
+
+		// exports bypass the cache, their pages aren't the ones of /search
+		err = wikipediaBreaker.Execute(func() error {
+			var err error
+			searchResponse, err = searchWikipedia(ctx, opts)
+
+			return err
+		})
+		if err != nil {
+			if ew == nil {
+				if errors.Is(err, errCircuitOpen) {
+					return &statusError{http.StatusServiceUnavailable, err}
+				}
+
+				return err
+			}
+
+			// the response has started, the client gets a truncated export
+			if ctx.Err() != nil {
+				l.Warn().Err(err).Int("rows", rows).Msg("export cancelled")
+			} else {
+				l.Error().Err(err).Int("rows", rows).Msg("export interrupted")
+			}
+
+			return nil
+		}
+
+		if ew == nil {
+			if format == formatText {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				ew = &textExportWriter{w}
+			} else {
+				w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+				w.Header().Set("Content-Disposition", `attachment; filename="search-results.csv"`)
+
+				ew, err = newCSVExportWriter(w)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		results := searchResponse.Query.Search
+		if len(results) > maxRows-rows {
+			results = results[:maxRows-rows]
+		}
+
+		for _, result := range results {
+			rows++
+
+			err = ew.WriteResult(rows, result)
+			if err != nil {
+				break
+			}
+		}
+
+		if err == nil {
+			err = ew.Flush()
+		}
+
+		if err != nil {
+			l.Warn().Err(err).Msg("unable to write export, the client probably disconnected")
+
+			return nil
+		}
+
+		flush()
+
+		// Wikipedia leaves out the continuation on the last page
+		if len(results) == 0 || searchResponse.Continue.Sroffset == 0 {
+			break
+		}
+	}
+
+	l.Info().
+		Str("search_query", p.Query).
+		Int("rows", rows).
+		Msg("export completed")
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// pagedSearch answers the searches with pages of totalHits generated results, following sroffset and srlimit.
+// It returns the offsets requested.
+func pagedSearch(t *testing.T, totalHits int, before func(r *http.Request) error) *[]int {
+	var mu sync.Mutex
+	offsets := new([]int)
+
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		params := r.URL.Query()
+		offset, _ := strconv.Atoi(params.Get("sroffset"))
+		limit, _ := strconv.Atoi(params.Get("srlimit"))
+
+		mu.Lock()
+		*offsets = append(*offsets, offset)
+		mu.Unlock()
+
+		if before != nil {
+			if err := before(r); err != nil {
+				return nil, err
+			}
+		}
+
+		var results []string
+		for i := offset; i < offset+limit && i < totalHits; i++ {
+			results = append(results, fmt.Sprintf(`{"ns": 0, "title": "Result %[1]d", "pageid": %[1]d, "snippet": "snippet %[1]d"}`, i+1))
+		}
+
+		continuation := ""
+		if offset+limit < totalHits {
+			continuation = fmt.Sprintf(`"continue": {"sroffset": %d, "continue": "-||"},`, offset+limit)
+		}
+
+		return jsonResponse(r, fmt.Sprintf(`{"batchcomplete": "", %s "query": {"searchinfo": {"totalhits": %d}, "search": [%s]}}`,
+			continuation, totalHits, strings.Join(results, ","))), nil
+	})
+
+	return offsets
+}
+
+func TestExportStreamsPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		totalHits int
+		maxRows   int
+		rows      int
+		offsets   []int
+	}{
+		{"capped at the max rows", 500, 120, 120, []int{0, 50, 100}},
+		{"all the results", 70, 120, 70, []int{0, 50}},
+		{"a single page", 10, 120, 10, []int{0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.ExportMaxRows = tt.maxRows
+			})
+			offsets := pagedSearch(t, tt.totalHits, nil)
+
+			w := serve(handlerWithError(exportHandler), "/search/export?q=go", nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+			}
+
+			if !w.Flushed {
+				t.Error("the rows aren't flushed as they're written")
+			}
+
+			records, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(records) != tt.rows+1 {
+				t.Fatalf("%d rows, want %d and the header", len(records)-1, tt.rows)
+			}
+
+			last := records[len(records)-1]
+			if last[0] != strconv.Itoa(tt.rows) || last[1] != "Result "+strconv.Itoa(tt.rows) {
+				t.Errorf("last row %q, want the result ranked %d", last, tt.rows)
+			}
+
+			if fmt.Sprint(*offsets) != fmt.Sprint(tt.offsets) {
+				t.Errorf("offsets requested %v, want %v", *offsets, tt.offsets)
+			}
+		})
+	}
+}
+
+func TestExportStopsWhenClientDisconnects(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.ExportMaxRows = 500
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the client goes away while the second page is fetched
+	offsets := pagedSearch(t, 1000, func(r *http.Request) error {
+		if r.URL.Query().Get("sroffset") != "0" {
+			cancel()
+			return ctx.Err()
+		}
+
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/search/export?q=go&format=text", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	handlerWithError(exportHandler).ServeHTTP(w, r)
+
+	if got := strings.Count(w.Body.String(), "\n\n"); got != exportPageSize {
+		t.Errorf("%d results exported, want the %d of the first page", got, exportPageSize)
+	}
+
+	if len(*offsets) != 2 {
+		t.Errorf("%d pages requested, want 2", len(*offsets))
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/rs/zerolog"
+)
+
+// fallbackTemplate is a bare search page rendered when index.html fails to execute,
+// so that searching keeps working, without the styling, until the templates are fixed.
+// It only relies on fields that every Search has.
+var fallbackTemplate = template.Must(template.New("fallback").Funcs(template.FuncMap{
+	"externalLinkAttrs": externalLinkAttrs,
+	"localURL":          localURL,
+	"attr":              attr,
+}).Parse(`<!DOCTYPE html>
+<html lang="{{ .Lang }}">
+  <head>
+    <meta charset="UTF-8" />
+    <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+    <title>Wikipedia Search</title>
+  </head>
+  <body>
+    <form action="{{ localURL "/search" }}" method="GET">
+      <input type="search" name="q" {{ attr "value" .Query }} placeholder="Search Wikipedia" />
+      <input type="hidden" name="lang" value="{{ .Lang }}" />
+      <input type="hidden" name="project" value="{{ .Project }}" />
+      <button type="submit">Search</button>
+    </form>
+    {{ with .Results }}
+    <ul>
+      {{ range .Query.Search }}
+      <li><a href="{{ .URL }}" {{ externalLinkAttrs }}>{{ .Title }}</a></li>
+      {{ end }}
+    </ul>
+    {{ end }}
+  </body>
+</html>
+`))
+
+// renderSearchPage executes index.html into buf, or the fallback template if it fails.
+// The templates are parsed once at startup, so a template file going missing afterwards doesn't
+// affect rendering, but an execution error (e.g. a field that's nil only for some searches) still can.
+// The error is logged rather than returned: a broken page is better served degraded than as a 500.
+func renderSearchPage(buf *bytes.Buffer, search *Search, l *zerolog.Logger) error {
+	err := tpl.Execute(buf, search)
+	if err == nil {
+		return nil
+	}
+
+	l.Error().Err(err).Msg("unable to render index.html, rendering the fallback template")
+
+	// drop what was written before the error
+	buf.Reset()
+
+	return fallbackTemplate.Execute(buf, search)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestFallbackTemplate(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	saved := tpl
+	t.Cleanup(func() { tpl = saved })
+
+	// index.html is gone: the template set no longer defines it
+	tpl = template.New("index.html")
+	template.Must(tpl.New("results").Parse(""))
+
+	var logs bytes.Buffer
+	l := zerolog.New(&logs)
+
+	r := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+	r = r.WithContext(l.WithContext(r.Context()))
+
+	w := httptest.NewRecorder()
+	handlerWithError(searchHandler).ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	body := w.Body.String()
+	if !strings.Contains(body, `name="q"`) || !strings.Contains(body, "</html>") {
+		t.Errorf("the fallback page isn't rendered: %s", body)
+	}
+
+	if !strings.Contains(body, "https://en.wikipedia.org") {
+		t.Error("the fallback page doesn't list the results")
+	}
+
+	if !strings.Contains(logs.String(), `"level":"error"`) || !strings.Contains(logs.String(), "fallback template") {
+		t.Errorf("the failure isn't logged as an error: %s", logs.String())
+	}
+}
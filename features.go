@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Features switch the optional features on and off, e.g. to turn one off while it misbehaves.
+// They're all on by default. A disabled endpoint responds with a 404 and a disabled
+// search parameter is ignored.
+type Features struct {
+	Cache        bool `json:"cache" yaml:"cache"`               // the search results cache
+	Extracts     bool `json:"extracts" yaml:"extracts"`         // the extracts parameter
+	Thumbnails   bool `json:"thumbnails" yaml:"thumbnails"`     // the thumbnails of the combined search
+	Interwiki    bool `json:"interwiki" yaml:"interwiki"`       // the interwiki parameter
+	Geo          bool `json:"geo" yaml:"geo"`                   // the geo parameter
+	Export       bool `json:"export" yaml:"export"`             // /search/export
+	Autocomplete bool `json:"autocomplete" yaml:"autocomplete"` // /api/autocomplete
+	Stats        bool `json:"stats" yaml:"stats"`               // /stats
+}
+
+func defaultFeatures() Features {
+	return Features{
+		Cache:        true,
+		Extracts:     true,
+		Thumbnails:   true,
+		Interwiki:    true,
+		Geo:          true,
+		Export:       true,
+		Autocomplete: true,
+		Stats:        true,
+	}
+}
+
+// featureFlags returns the state of every feature switch of c: the Features
+// along with the settings that turn an optional feature on
+func (c Config) featureFlags() map[string]bool {
+	return map[string]bool{
+		"cache":              c.Features.Cache,
+		"extracts":           c.Features.Extracts,
+		"thumbnails":         c.Features.Thumbnails,
+		"interwiki":          c.Features.Interwiki,
+		"geo":                c.Features.Geo,
+		"export":             c.Features.Export,
+		"autocomplete":       c.Features.Autocomplete,
+		"stats":              c.Features.Stats,
+		"combined_search":    c.CombinedSearch,
+		"prefetch_next_page": c.PrefetchNextPage,
+		"pageviews":          c.Pageviews,
+		"jsonp":              c.JSONP,
+		"server_timing":      c.ServerTiming,
+		"server_info":        c.ServerInfo,
+	}
+}
+
+// searchCacheTTL is the TTL of the search results cache, 0 when the cache is disabled
+func (c Config) searchCacheTTL() time.Duration {
+	if !c.Features.Cache {
+		return 0
+	}
+
+	return time.Duration(c.CacheTTL)
+}
+
+// featuresHandler reports which features are on
+func featuresHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		return &statusError{http.StatusMethodNotAllowed, errors.New("method not allowed")}
+	}
+
+	buf := getBuffer()
+
+	err := json.NewEncoder(buf).Encode(config.featureFlags())
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
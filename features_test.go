@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDisabledEndpoints(t *testing.T) {
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		if r.URL.Query().Get("action") == "opensearch" {
+			return jsonResponse(r, `["go", ["Go"], [""], ["https://en.wikipedia.org/wiki/Go"]]`), nil
+		}
+
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	targets := []string{"/search/export?q=go", "/api/autocomplete?q=go", "/stats"}
+
+	for _, enabled := range []bool{true, false} {
+		withConfig(t, func(c *Config) {
+			c.Features.Export = enabled
+			c.Features.Autocomplete = enabled
+			c.Features.Stats = enabled
+		})
+
+		mux := newMux()
+
+		for _, target := range targets {
+			w := serve(mux, target, nil)
+			if (w.Code == http.StatusNotFound) == enabled {
+				t.Errorf("enabled %t: GET %s: status %d", enabled, target, w.Code)
+			}
+		}
+	}
+}
+
+func TestDisabledSearchParameters(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Features.Extracts = false
+		c.Features.Geo = false
+	})
+
+	var requests []*http.Request
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, r)
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go&extracts=1&geo=1&format=json", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	// a single list=search request: no generator for the extracts, no coordinates
+	if len(requests) != 1 {
+		t.Fatalf("%d requests to Wikipedia, want 1", len(requests))
+	}
+
+	if params := requests[0].URL.Query(); params.Get("list") != "search" || params.Has("generator") {
+		t.Errorf("the disabled extracts are requested: %v", params)
+	}
+
+	var s Search
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Extracts || s.Geo {
+		t.Errorf("extracts %t, geo %t, want the disabled parameters ignored", s.Extracts, s.Geo)
+	}
+}
+
+func TestDisabledCache(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		withConfig(t, func(c *Config) {
+			c.Features.Cache = enabled
+			c.CacheTTL = Duration(time.Minute)
+		})
+
+		var calls atomic.Int64
+		stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			return jsonResponse(r, searchResponseJSON), nil
+		})
+
+		for i := 0; i < 2; i++ {
+			serve(handlerWithError(searchHandler), "/search?q=go&format=json", nil)
+		}
+
+		want := int64(1)
+		if !enabled {
+			want = 2
+		}
+
+		if calls.Load() != want {
+			t.Errorf("cache enabled %t: %d calls to Wikipedia, want %d", enabled, calls.Load(), want)
+		}
+	}
+}
+
+func TestFeaturesHandler(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.AdminToken = "secret"
+		c.Features.Geo = false
+		c.Pageviews = true
+	})
+
+	w := serve(requireAdmin(featuresHandler), "/admin/features", http.Header{adminTokenHeader: {"secret"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	var flags map[string]bool
+	if err := json.NewDecoder(w.Body).Decode(&flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(flags) != len(config.featureFlags()) || flags["geo"] || !flags["cache"] || !flags["pageviews"] {
+		t.Errorf("flags = %v", flags)
+	}
+
+	w = serve(requireAdmin(featuresHandler), "/admin/features", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("without the token: status %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxPageIDs is the maximum number of page IDs the API accepts in a single query
+const maxPageIDs = 50
+
+type Coordinates struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type WikipediaCoordinatesResponse struct {
+	Query struct {
+		Pages []struct {
+			PageID      int `json:"pageid"`
+			Coordinates []struct {
+				Lat   float64 `json:"lat"`
+				Lon   float64 `json:"lon"`
+				Globe string  `json:"globe"`
+			} `json:"coordinates"`
+		} `json:"pages"`
+	} `json:"query"`
+}
+
+// MapURL returns a link to the location of the article on a map, empty if it isn't geo-tagged
+func (r SearchResult) MapURL() string {
+	if r.Coordinates == nil {
+		return ""
+	}
+
+	lat := strconv.FormatFloat(r.Coordinates.Lat, 'f', 5, 64)
+	lon := strconv.FormatFloat(r.Coordinates.Lon, 'f', 5, 64)
+
+	return fmt.Sprintf("https://www.openstreetmap.org/?mlat=%s&mlon=%s#map=12/%s/%s", lat, lon, lat, lon)
+}
+
+// fetchCoordinates returns the primary coordinates on Earth of the given pages, by page ID.
+// Pages without coordinates are left out.
+func fetchCoordinates(ctx context.Context, project, lang string, pageIDs []int) (map[int]Coordinates, error) {
+	coordinates := make(map[int]Coordinates)
+
+	for start := 0; start < len(pageIDs); start += maxPageIDs {
+		end := start + maxPageIDs
+		if end > len(pageIDs) {
+			end = len(pageIDs)
+		}
+
+		ids := make([]string, 0, end-start)
+		for _, id := range pageIDs[start:end] {
+			ids = append(ids, strconv.Itoa(id))
+		}
+
+		params := url.Values{}
+		params.Set("action", "query")
+		params.Set("formatversion", "2")
+		params.Set("prop", "coordinates")
+		params.Set("coprimary", "primary")
+		params.Set("colimit", "max")
+		params.Set("pageids", strings.Join(ids, "|"))
+
+		var resp WikipediaCoordinatesResponse
+
+		err := wikipediaBreaker.Execute(func() error {
+			return callMediaWiki(ctx, project, lang, params, &resp)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, page := range resp.Query.Pages {
+			for _, c := range page.Coordinates {
+				if c.Globe == "" || strings.EqualFold(c.Globe, "earth") {
+					coordinates[page.PageID] = Coordinates{Lat: c.Lat, Lon: c.Lon}
+					break
+				}
+			}
+		}
+	}
+
+	return coordinates, nil
+}
+
+// addCoordinates sets the coordinates of the geo-tagged results.
+// The response must not be shared, see WikipediaSearchResponse.clone.
+func addCoordinates(ctx context.Context, project, lang string, searchResponse *WikipediaSearchResponse) error {
+	results := searchResponse.Query.Search
+	if len(results) == 0 {
+		return nil
+	}
+
+	pageIDs := make([]int, len(results))
+	for i, result := range results {
+		pageIDs[i] = result.PageID
+	}
+
+	coordinates, err := fetchCoordinates(ctx, project, lang, pageIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range results {
+		if c, ok := coordinates[results[i].PageID]; ok {
+			results[i].Coordinates = &c
+		}
+	}
+
+	return nil
+}
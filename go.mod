@@ -3,14 +3,17 @@ module github.com/freshman-tech/news-demo
 go 1.19
 
 require (
+	github.com/rs/xid v1.4.0
 	github.com/rs/zerolog v1.29.0
+	golang.org/x/sync v0.5.0
+	golang.org/x/text v0.14.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.18 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/rs/xid v1.4.0 // indirect
 	golang.org/x/sys v0.7.0 // indirect
 )
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"html"
+	"html/template"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldRune returns r without its case and accents, so that "É" and "e" compare equal.
+// A rune can fold to several, e.g. a Hangul syllable decomposes to its letters.
+func foldRune(r rune) []rune {
+	var folded []rune
+	for _, d := range norm.NFD.String(string(r)) {
+		if unicode.Is(unicode.Mn, d) {
+			continue
+		}
+		folded = append(folded, unicode.ToLower(d))
+	}
+
+	return folded
+}
+
+// highlightTerms returns the words of the query worth highlighting, folded.
+// Operators such as -word (exclusion) and prefix:value (e.g. intitle:) are left out.
+func highlightTerms(query string) [][]rune {
+	var terms [][]rune
+
+	for _, word := range strings.Fields(query) {
+		if strings.HasPrefix(word, "-") || strings.Contains(word, ":") {
+			continue
+		}
+
+		word = strings.TrimFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+
+		var term []rune
+		for _, r := range word {
+			term = append(term, foldRune(r)...)
+		}
+
+		if len(term) > 0 {
+			terms = append(terms, term)
+		}
+	}
+
+	return terms
+}
+
+// highlight escapes the plain text s and wraps the parts matching the words of query
+// in a <span> of config.HighlightClass, like the snippets. Matching ignores case and accents,
+// only starts at the beginning of a word, so "lang" highlights the start of "language" but not "slang",
+// and overlapping or adjacent matches of several words are merged into a single highlight.
+func highlight(s, query string) template.HTML {
+	terms := highlightTerms(query)
+	if len(terms) == 0 {
+		return template.HTML(html.EscapeString(s))
+	}
+
+	original := []rune(s)
+
+	// folded is s folded rune by rune, origin maps each folded rune to the index of its rune in original
+	var (
+		folded []rune
+		origin []int
+	)
+
+	for i, r := range original {
+		for _, f := range foldRune(r) {
+			folded = append(folded, f)
+			origin = append(origin, i)
+		}
+	}
+
+	matched := make([]bool, len(original))
+
+	for _, term := range terms {
+		for i := 0; i+len(term) <= len(folded); i++ {
+			if !wordStart(original, origin, i) || !runesEqual(folded[i:i+len(term)], term) {
+				continue
+			}
+
+			for j := origin[i]; j <= origin[i+len(term)-1]; j++ {
+				matched[j] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+
+	for i, r := range original {
+		if matched[i] && (i == 0 || !matched[i-1]) {
+			b.WriteString(`<span class="` + config.HighlightClass + `">`)
+		}
+
+		b.WriteString(html.EscapeString(string(r)))
+
+		if matched[i] && (i == len(original)-1 || !matched[i+1]) {
+			b.WriteString("</span>")
+		}
+	}
+
+	return template.HTML(b.String())
+}
+
+// wordStart reports whether the folded rune at i starts a word of original
+func wordStart(original []rune, origin []int, i int) bool {
+	if i > 0 && origin[i-1] == origin[i] {
+		return false
+	}
+
+	o := origin[i]
+
+	return o == 0 || !(unicode.IsLetter(original[o-1]) || unicode.IsNumber(original[o-1]))
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.HighlightClass = "searchmatch"
+	})
+
+	tests := []struct {
+		name     string
+		s, query string
+		want     template.HTML
+	}{
+		{"no query", "Go (game)", "", "Go (game)"},
+		{"case insensitive", "Go (game)", "go", `<span class="searchmatch">Go</span> (game)`},
+		{"word start only", "Slang language", "lang",
+			`Slang <span class="searchmatch">lang</span>uage`},
+		{"several terms", "Go (programming language)", "go language",
+			`<span class="searchmatch">Go</span> (programming <span class="searchmatch">language</span>)`},
+		{"overlapping terms", "Programming", "prog program",
+			`<span class="searchmatch">Program</span>ming`},
+		{"adjacent terms merged", "New York", "new york",
+			`<span class="searchmatch">New</span> <span class="searchmatch">York</span>`},
+		{"accented title", "Émile Zola", "emile",
+			`<span class="searchmatch">Émile</span> Zola`},
+		{"accented query", "Emile Zola", "émile",
+			`<span class="searchmatch">Emile</span> Zola`},
+		{"decomposed accent", "E\u0301mile Zola", "emile",
+			"<span class=\"searchmatch\">E\u0301mile</span> Zola"},
+		{"non-Latin script", "Москва (река)", "москва",
+			`<span class="searchmatch">Москва</span> (река)`},
+		{"escaped title", "AT&T <Inc>", "t",
+			`AT&amp;<span class="searchmatch">T</span> &lt;Inc&gt;`},
+		{"no match inside entities", "Tom & Jerry", "amp",
+			`Tom &amp; Jerry`},
+		{"operators ignored", "Go (game)", "-game intitle:go",
+			`Go (game)`},
+		{"punctuation around terms", "Go (game)", `"game"`,
+			`Go (<span class="searchmatch">game</span>)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlight(tt.s, tt.query); got != tt.want {
+				t.Errorf("highlight(%q, %q) = %q, want %q", tt.s, tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// instanceIDPattern keeps the instance IDs to the characters of host names,
+// which are safe in the HTML comment of ServerComment
+var instanceIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// defaultInstanceID identifies the instance by its host name, which is the pod name in Kubernetes,
+// or by a generated ID when there's none to use
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err == nil && instanceIDPattern.MatchString(hostname) {
+		return hostname
+	}
+
+	return xid.New().String()
+}
+
+// ServerComment returns the HTML comment telling which instance rendered the page and when,
+// empty unless config.ServerInfo is on
+func (s *Search) ServerComment() template.HTML {
+	if s.ServedAt == nil {
+		return ""
+	}
+
+	// the instance ID can't end the comment, see instanceIDPattern
+	return template.HTML(fmt.Sprintf(
+		"<!-- served by %s at %s -->", s.ServedBy, s.ServedAt.Format(time.RFC3339Nano),
+	))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serverCommentPattern matches the comment ServerComment ends the page with
+var serverCommentPattern = regexp.MustCompile(`<!-- served by ([A-Za-z0-9._-]+) at (\S+) -->`)
+
+func TestServerInfo(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.ServerInfo = true
+		c.instanceID = "web-1"
+	})
+	stubSearch(t, searchResponseJSON)
+
+	before := time.Now()
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go&format=json", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	var s Search
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ServedBy != "web-1" {
+		t.Errorf("served_by = %q, want web-1", s.ServedBy)
+	}
+
+	if s.ServedAt == nil || s.ServedAt.Before(before.Add(-time.Second)) || s.ServedAt.After(time.Now()) {
+		t.Errorf("served_at = %v, want the time of the search", s.ServedAt)
+	}
+
+	body := serve(handlerWithError(searchHandler), "/search?q=go", nil).Body.String()
+
+	m := serverCommentPattern.FindStringSubmatch(body)
+	if m == nil || m[1] != "web-1" {
+		t.Fatalf("no server comment in the page")
+	}
+
+	if _, err := time.Parse(time.RFC3339Nano, m[2]); err != nil {
+		t.Errorf("the served time of the comment isn't RFC 3339: %v", err)
+	}
+}
+
+func TestServerInfoOff(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.ServerInfo = false
+		c.instanceID = "web-1"
+	})
+	stubSearch(t, searchResponseJSON)
+
+	body := serve(handlerWithError(searchHandler), "/search?q=go&format=json", nil).Body.String()
+	if strings.Contains(body, "served_at") || strings.Contains(body, "served_by") {
+		t.Errorf("server fields in the JSON with the flag off: %s", body)
+	}
+
+	body = serve(handlerWithError(searchHandler), "/search?q=go", nil).Body.String()
+	if strings.Contains(body, "served by") || strings.Contains(body, "web-1") {
+		t.Error("server comment in the page with the flag off")
+	}
+}
+
+func TestInstanceID(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"web-1", true},
+		{"pod_7.eu-west", true},
+		{"web 1", false},
+		{"--><script>", false},
+	}
+
+	for _, tt := range tests {
+		cfg := defaultConfig()
+		cfg.InstanceID = tt.id
+
+		err := cfg.validate()
+		if (err == nil) != tt.valid {
+			t.Errorf("instance ID %q: error %v, want valid %t", tt.id, err, tt.valid)
+		}
+
+		if tt.valid && cfg.instanceID != tt.id {
+			t.Errorf("instance ID %q: got %q", tt.id, cfg.instanceID)
+		}
+	}
+
+	// by default, the host name or a generated ID
+	cfg := defaultConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !instanceIDPattern.MatchString(cfg.instanceID) {
+		t.Errorf("default instance ID %q", cfg.instanceID)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import "sort"
+
+// InterwikiResult is a match on a sister wiki, returned with srinterwiki=1.
+// Unlike SearchResult, the API only gives its title and a link to it.
+type InterwikiResult struct {
+	Namespace string `json:"namespace"`
+	Title     string `json:"title"`
+	URL       string `json:"url"`
+}
+
+// interwikiNames are the names of the wikis behind the interwiki prefixes the search returns results for
+var interwikiNames = map[string]string{
+	"wikt":    "Wiktionary",
+	"b":       "Wikibooks",
+	"q":       "Wikiquote",
+	"s":       "Wikisource",
+	"voy":     "Wikivoyage",
+	"n":       "Wikinews",
+	"v":       "Wikiversity",
+	"species": "Wikispecies",
+	"c":       "Wikimedia Commons",
+	"d":       "Wikidata",
+	"w":       "Wikipedia",
+}
+
+// interwikiName returns the name of the wiki behind an interwiki prefix, the prefix itself if it's unknown
+func interwikiName(prefix string) string {
+	if name, ok := interwikiNames[prefix]; ok {
+		return name
+	}
+
+	return prefix
+}
+
+// interwikiGroup are the results from one sister wiki
+type interwikiGroup struct {
+	Prefix    string
+	Name      string
+	TotalHits int
+	Results   []InterwikiResult
+}
+
+// InterwikiResults returns the results from the sister wikis grouped by wiki, for display after the results
+// of the searched wiki. The groups are in the order of their names, then of their prefixes for the unknown
+// wikis named after their prefix, and the results of a group in relevance order: the order doesn't depend
+// on the iteration order of InterwikiSearch.
+func (s *Search) InterwikiResults() []interwikiGroup {
+	if s.Results == nil {
+		return nil
+	}
+
+	groups := make([]interwikiGroup, 0, len(s.Results.Query.InterwikiSearch))
+	for prefix, results := range s.Results.Query.InterwikiSearch {
+		if len(results) == 0 {
+			continue
+		}
+
+		groups = append(groups, interwikiGroup{
+			Prefix:    prefix,
+			Name:      interwikiName(prefix),
+			TotalHits: s.Results.Query.InterwikiSearchInfo[prefix].TotalHits,
+			Results:   results,
+		})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Name != groups[j].Name {
+			return groups[i].Name < groups[j].Name
+		}
+
+		return groups[i].Prefix < groups[j].Prefix
+	})
+
+	return groups
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// interwikiResponseJSON is a response of the Wikipedia search API with srinterwiki=1
+const interwikiResponseJSON = `{
+	"batchcomplete": "",
+	"query": {
+		"searchinfo": {"totalhits": 1},
+		"search": [
+			{"ns": 0, "title": "Go (programming language)", "pageid": 25039021, "size": 300, "wordcount": 30,
+				"snippet": "<span class=\"searchmatch\">Go</span> is a programming language", "timestamp": "2023-01-01T00:00:00Z"}
+		],
+		"interwikisearchinfo": {
+			"wikt": {"totalhits": 12},
+			"b": {"totalhits": 3},
+			"xyz": {"totalhits": 1}
+		},
+		"interwikisearch": {
+			"wikt": [
+				{"namespace": "", "title": "go", "url": "https://en.wiktionary.org/wiki/go"},
+				{"namespace": "", "title": "Go", "url": "https://en.wiktionary.org/wiki/Go"}
+			],
+			"b": [
+				{"namespace": "", "title": "Go Programming", "url": "https://en.wikibooks.org/wiki/Go_Programming"}
+			],
+			"xyz": [
+				{"namespace": "", "title": "Go", "url": "https://xyz.example.org/wiki/Go"}
+			],
+			"q": []
+		}
+	}
+}`
+
+func TestSearchInterwiki(t *testing.T) {
+	var srinterwiki []string
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		srinterwiki = append(srinterwiki, r.URL.Query().Get("srinterwiki"))
+		return jsonResponse(r, interwikiResponseJSON), nil
+	})
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go&interwiki=1", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if len(srinterwiki) != 1 || srinterwiki[0] != "1" {
+		t.Errorf("srinterwiki = %q, want 1", srinterwiki)
+	}
+
+	body := w.Body.String()
+
+	// the results of the searched wiki are still there
+	if !strings.Contains(body, "Go (programming language)") {
+		t.Error("the results are missing")
+	}
+
+	// then the sister wikis in the order of their names, the unknown one named after its prefix
+	headings := []string{"Wikibooks", "Wiktionary", "xyz"}
+	last := strings.Index(body, `class="interwiki-results"`)
+	if last < 0 {
+		t.Fatal("no interwiki results")
+	}
+
+	for _, heading := range headings {
+		i := strings.Index(body[last:], heading)
+		if i < 0 {
+			t.Fatalf("no %s results after the previous ones", heading)
+		}
+		last += i
+	}
+
+	if !strings.Contains(body, "(12 results)") || !strings.Contains(body, `href="https://en.wiktionary.org/wiki/go"`) {
+		t.Error("the Wiktionary results are missing")
+	}
+
+	if strings.Contains(body[strings.Index(body, `class="interwiki-results"`):], "Wikiquote") {
+		t.Error("a sister wiki without results is shown")
+	}
+
+	// without the flag
+	w = serve(handlerWithError(searchHandler), "/search?q=go&page=2", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if srinterwiki[1] != "" {
+		t.Errorf("srinterwiki = %q without the interwiki parameter", srinterwiki[1])
+	}
+}
+
+func TestInterwikiDisabled(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Features.Interwiki = false
+	})
+
+	var srinterwiki string
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		srinterwiki = r.URL.Query().Get("srinterwiki")
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go&interwiki=1", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if srinterwiki != "" {
+		t.Errorf("srinterwiki = %q with the feature off", srinterwiki)
+	}
+}
+
+func TestInterwikiResultsOrder(t *testing.T) {
+	var response WikipediaSearchResponse
+	if err := json.Unmarshal([]byte(interwikiResponseJSON), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	// unknown wikis named after their prefix, and one named like a known wiki
+	response.Query.InterwikiSearch["zz"] = []InterwikiResult{{Title: "Go", URL: "https://zz.example.org/wiki/Go"}}
+	response.Query.InterwikiSearch["aa"] = []InterwikiResult{{Title: "Go", URL: "https://aa.example.org/wiki/Go"}}
+	response.Query.InterwikiSearch["Wiktionary"] = []InterwikiResult{{Title: "Go", URL: "https://example.org/wiki/Go"}}
+
+	s := &Search{Results: &response}
+
+	order := func(groups []interwikiGroup) string {
+		var b strings.Builder
+		for _, g := range groups {
+			b.WriteString(g.Prefix + ":")
+			for _, result := range g.Results {
+				b.WriteString(" " + result.URL)
+			}
+			b.WriteString("\n")
+		}
+
+		return b.String()
+	}
+
+	// the names, then the prefixes, compare byte-wise
+	want := "b: https://en.wikibooks.org/wiki/Go_Programming\n" +
+		"Wiktionary: https://example.org/wiki/Go\n" +
+		"wikt: https://en.wiktionary.org/wiki/go https://en.wiktionary.org/wiki/Go\n" +
+		"aa: https://aa.example.org/wiki/Go\n" +
+		"xyz: https://xyz.example.org/wiki/Go\n" +
+		"zz: https://zz.example.org/wiki/Go\n"
+
+	// the iteration order of the map changes from one run to the other
+	for i := 0; i < 100; i++ {
+		if got := order(s.InterwikiResults()); got != want {
+			t.Fatalf("run %d: order\n%s\nwant\n%s", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// formatJSONP is the format of /api/search responses with a callback parameter
+const formatJSONP = "jsonp"
+
+// maxCallbackLength bounds the callback name, which is echoed in the response
+const maxCallbackLength = 64
+
+// callbackPattern accepts JavaScript identifiers, optionally namespaced like "widget.onResults".
+// Anything else could inject script into the response.
+var callbackPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// jsonpCallback returns the JSONP callback name of an /api/ request, empty when there's none.
+// JSONP lets any site read the responses, so it's rejected unless config.JSONP is set,
+// and so are names that aren't plain identifiers.
+func jsonpCallback(r *http.Request) (string, error) {
+	callback := r.URL.Query().Get("callback")
+	if callback == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+		return "", nil
+	}
+
+	if !config.JSONP {
+		return "", &statusError{http.StatusBadRequest, errors.New("JSONP is disabled")}
+	}
+
+	if len(callback) > maxCallbackLength || !callbackPattern.MatchString(callback) {
+		return "", &statusError{http.StatusBadRequest, fmt.Errorf("invalid callback name '%s'", callback)}
+	}
+
+	return callback, nil
+}
+
+// writeJSONP writes v as JSON wrapped in a call to callback. The leading comment keeps the response
+// from being interpreted as anything else than JavaScript, e.g. as a Flash file (Rosetta Flash).
+func writeJSONP(w io.Writer, callback string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "/**/ typeof %[1]s === 'function' && %[1]s(%s);\n", callback, data)
+
+	return err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestJSONPCallback(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.JSONP = true
+	})
+	stubSearch(t, searchResponseJSON)
+
+	for _, callback := range []string{"onResults", "widget.onResults", "$_cb1", "_"} {
+		w := serve(handlerWithError(apiSearchHandler), "/api/search?q=go&callback="+url.QueryEscape(callback), nil)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("callback %q: status %d, want 200: %s", callback, w.Code, w.Body)
+			continue
+		}
+
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/javascript") {
+			t.Errorf("callback %q: Content-Type = %q, want application/javascript", callback, got)
+		}
+
+		want := "/**/ typeof " + callback + " === 'function' && " + callback + "({"
+		if !strings.HasPrefix(w.Body.String(), want) || !strings.HasSuffix(w.Body.String(), "});\n") {
+			t.Errorf("callback %q: body %.80q..., want a call of the callback", callback, w.Body)
+		}
+	}
+}
+
+func TestJSONPMaliciousCallback(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.JSONP = true
+	})
+	calls := stubSearch(t, searchResponseJSON)
+
+	for _, callback := range []string{
+		"alert(1)",
+		"cb;alert(document.cookie)//",
+		"</script><script>alert(1)",
+		"cb\nalert(1)",
+		"1cb",
+		"cb..x",
+		"cb.",
+		"a['b']",
+		"CWS\x00",
+		strings.Repeat("a", maxCallbackLength+1),
+	} {
+		w := serve(handlerWithError(apiSearchHandler), "/api/search?q=go&callback="+url.QueryEscape(callback), nil)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("callback %q: status %d, want 400", callback, w.Code)
+		}
+
+		// the error message repeats the name, but can't be run as a script
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("callback %q: the error is served as %q", callback, w.Header().Get("Content-Type"))
+		}
+	}
+
+	if calls.Load() != 0 {
+		t.Errorf("%d calls to Wikipedia for invalid callbacks, want none", calls.Load())
+	}
+}
+
+func TestJSONPDisabled(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.JSONP = false
+	})
+	stubSearch(t, searchResponseJSON)
+
+	if w := serve(handlerWithError(apiSearchHandler), "/api/search?q=go&callback=onResults", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("status %d with JSONP disabled, want 400", w.Code)
+	}
+
+	// the callback parameter means nothing outside of the API
+	if w := serve(handlerWithError(searchHandler), "/search?q=go&callback=onResults&format=json", nil); w.Code != http.StatusOK {
+		t.Errorf("status %d for /search with a callback, want 200", w.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+const defaultLang = "en"
+
+type wikiLanguage struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// supportedLanguages are the Wikipedia editions that can be searched with the lang parameter.
+// They're validated against this list, offered in the language picker and listed by /api/languages.
+var supportedLanguages = []wikiLanguage{
+	{"en", "English"},
+	{"de", "Deutsch"},
+	{"fr", "Français"},
+	{"es", "Español"},
+	{"it", "Italiano"},
+	{"pt", "Português"},
+	{"nl", "Nederlands"},
+	{"pl", "Polski"},
+	{"ru", "Русский"},
+	{"ja", "日本語"},
+	{"zh", "中文"},
+}
+
+// languageMatcher picks the supported language closest to the ones a browser accepts
+var languageMatcher = func() language.Matcher {
+	tags := make([]language.Tag, len(supportedLanguages))
+	for i, lang := range supportedLanguages {
+		tags[i] = language.Make(lang.Code)
+	}
+
+	return language.NewMatcher(tags)
+}()
+
+// preferredLanguage returns the supported language that best matches an Accept-Language header,
+// taking its quality values into account, e.g. "de" for "de-CH, fr;q=0.8". A regional variant
+// matches its language. def is returned when the header is missing, invalid or lists none of them.
+func preferredLanguage(acceptLanguage, def string) string {
+	if acceptLanguage == "" {
+		return def
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return def
+	}
+
+	_, index, confidence := languageMatcher.Match(tags...)
+	if confidence == language.No {
+		return def
+	}
+
+	return supportedLanguages[index].Code
+}
+
+func isSupportedLanguage(code string) bool {
+	for _, lang := range supportedLanguages {
+		if lang.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// languagesHandler lists the supported languages as JSON, for language pickers
+func languagesHandler(w http.ResponseWriter, r *http.Request) error {
+	buf := getBuffer()
+
+	err := json.NewEncoder(buf).Encode(supportedLanguages)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// the list only changes with a new release
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
+
+// dateLayouts are the date formats of the supported languages, English's is the default
+var dateLayouts = map[string]string{
+	"en": "Jan 2, 2006",
+	"de": "02.01.2006",
+	"fr": "02/01/2006",
+	"es": "02/01/2006",
+	"it": "02/01/2006",
+	"pt": "02/01/2006",
+	"nl": "02-01-2006",
+	"pl": "02.01.2006",
+	"ru": "02.01.2006",
+	"ja": "2006/01/02",
+	"zh": "2006-01-02",
+}
+
+// formatNumber formats n with the digit grouping of lang e.g. 1,234 in English or 1.234 in German
+func formatNumber(lang string, n int) string {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		tag = language.English
+	}
+
+	return message.NewPrinter(tag).Sprintf("%d", n)
+}
+
+// formatDate formats t with the date layout of lang
+func formatDate(lang string, t time.Time) string {
+	layout, ok := dateLayouts[lang]
+	if !ok {
+		layout = dateLayouts[defaultLang]
+	}
+
+	return t.Format(layout)
+}
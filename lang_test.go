@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestLanguagesEndpoint(t *testing.T) {
+	w := serve(newMux(), "/api/languages", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+
+	var languages []wikiLanguage
+	if err := json.NewDecoder(w.Body).Decode(&languages); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(languages) == 0 {
+		t.Fatal("no languages listed")
+	}
+
+	listed := make(map[string]bool)
+
+	for _, lang := range languages {
+		if lang.Code == "" || lang.Name == "" {
+			t.Errorf("language %+v without a code or a name", lang)
+		}
+
+		listed[lang.Code] = true
+
+		_, err := parseSearchParams(url.Values{"q": {"go"}, "lang": {lang.Code}}, "", config)
+		if err != nil {
+			t.Errorf("listed language %s is rejected: %v", lang.Code, err)
+		}
+	}
+
+	if !listed[config.DefaultLang] {
+		t.Errorf("the default language %s isn't listed", config.DefaultLang)
+	}
+
+	for _, code := range []string{"xx", "EN", "en-US", "klingon"} {
+		if listed[code] || isSupportedLanguage(code) {
+			t.Errorf("unlisted language %s is accepted", code)
+		}
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", "en"},
+		{"fr", "fr"},
+		{"fr-CA", "fr"},
+		{"de-CH, fr;q=0.8", "de"},
+		{"fr;q=0.5, de;q=0.9", "de"},
+		{"ja-JP,ja;q=0.9,en-US;q=0.8,en;q=0.7", "ja"},
+		{"tlh, sv;q=0.9", "en"},
+		{"tlh, it;q=0.3", "it"},
+		{"*", "en"},
+		{"zh-Hant-TW", "zh"},
+		{";;;garbage", "en"},
+	}
+
+	for _, tt := range tests {
+		if got := preferredLanguage(tt.acceptLanguage, "en"); got != tt.want {
+			t.Errorf("preferredLanguage(%q) = %q, want %q", tt.acceptLanguage, got, tt.want)
+		}
+	}
+}
+
+func TestSearchLanguageFromHeader(t *testing.T) {
+	tests := []struct {
+		target         string
+		acceptLanguage string
+		host           string
+		vary           bool
+	}{
+		{"/search?q=go", "de-DE,de;q=0.9", "de.wikipedia.org", true},
+		{"/search?q=go", "sv-SE", "en.wikipedia.org", true},
+		{"/search?q=go&lang=it", "de-DE,de;q=0.9", "it.wikipedia.org", false},
+	}
+
+	for _, tt := range tests {
+		var host string
+		stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+			host = r.URL.Host
+			return jsonResponse(r, searchResponseJSON), nil
+		})
+
+		w := serve(handlerWithError(searchHandler), tt.target, http.Header{"Accept-Language": {tt.acceptLanguage}})
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+		}
+
+		if host != tt.host {
+			t.Errorf("GET %s with Accept-Language %q: searched %s, want %s", tt.target, tt.acceptLanguage, host, tt.host)
+		}
+
+		if vary := strings.Contains(strings.Join(w.Header().Values("Vary"), ","), "Accept-Language"); vary != tt.vary {
+			t.Errorf("GET %s: Vary on Accept-Language %t, want %t", tt.target, vary, tt.vary)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// validateRequestURI returns a middleware that rejects requests whose URI is longer than maxLength
+// with a 414, or whose query string isn't properly percent-encoded with a 400,
+// before they reach the handlers
+func validateRequestURI(maxLength int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l := zerolog.Ctx(r.Context())
+
+			if len(r.RequestURI) > maxLength {
+				l.Debug().
+					Int("uri_length", len(r.RequestURI)).
+					Msg("rejecting request with an oversized URI")
+
+				http.Error(w, "request URI too long", http.StatusRequestURITooLong)
+
+				return
+			}
+
+			// the server already rejects a malformed path, but not a malformed query string
+			if _, err := url.ParseQuery(r.URL.RawQuery); err != nil {
+				l.Debug().Err(err).Msg("rejecting request with a malformed query string")
+
+				http.Error(w, "malformed query string", http.StatusBadRequest)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// concurrencyLogs samples the warnings of limitConcurrency, which come in bursts under load
+var concurrencyLogs = newErrorSampler(errorLogInterval)
+
+// limitConcurrency returns a middleware that sheds load: at most max requests are handled at once,
+// the others wait up to queueTimeout for a slot and are then rejected with a 503.
+// A max of 0 disables the limit.
+func limitConcurrency(max int, queueTimeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if max <= 0 {
+			return next
+		}
+
+		slots := make(chan struct{}, max)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case slots <- struct{}{}:
+			default:
+				timer := time.NewTimer(queueTimeout)
+
+				select {
+				case slots <- struct{}{}:
+					timer.Stop()
+				case <-timer.C:
+					l := zerolog.Ctx(r.Context())
+					if ok, suppressed := concurrencyLogs.allow(l, "concurrency limit"); ok {
+						e := l.Warn().Int("max_concurrent_requests", max)
+						if suppressed > 0 {
+							e = e.Int("suppressed_count", suppressed).
+								Dur("suppressed_window_ms", concurrencyLogs.interval)
+						}
+
+						e.Msg("rejecting request over the concurrency limit")
+					}
+
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, "server busy, try again later", http.StatusServiceUnavailable)
+
+					return
+				case <-r.Context().Done():
+					timer.Stop()
+
+					return
+				}
+			}
+
+			defer func() { <-slots }()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitRequestBody returns a middleware that caps request bodies at maxBytes.
+// Requests announcing a bigger body are rejected with a 413 right away,
+// others fail with a *http.MaxBytesError when the handler reads past the limit.
+func limitRequestBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				zerolog.Ctx(r.Context()).Debug().
+					Int64("content_length", r.ContentLength).
+					Msg("rejecting request with an oversized body")
+
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// decodeJSONBody decodes the JSON request body into v. Failures are returned as a *statusError:
+// a 413 when the body exceeds the limit set by limitRequestBody, a 400 when it isn't valid JSON.
+func decodeJSONBody(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil {
+		return nil
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &statusError{
+			http.StatusRequestEntityTooLarge,
+			fmt.Errorf("request body must not be larger than %d bytes", maxBytesErr.Limit),
+		}
+	}
+
+	return &statusError{
+		http.StatusBadRequest,
+		fmt.Errorf("malformed JSON request body: %w", err),
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestValidateRequestURI(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := validateRequestURI(100)(ok)
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{"valid URI", "/search?q=go+lang&page=2", http.StatusOK},
+		{"escaped query", "/search?q=%C3%A9t%C3%A9", http.StatusOK},
+		{"URI at the limit", "/search?q=" + strings.Repeat("a", 100-len("/search?q=")), http.StatusOK},
+		{"over-length URI", "/search?q=" + strings.Repeat("a", 100), http.StatusRequestURITooLong},
+		{"malformed escape", "/search?q=%zz", http.StatusBadRequest},
+		{"truncated escape", "/search?q=go%2", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(h, tt.target, nil); w.Code != tt.want {
+				t.Errorf("GET %s: status %d, want %d", tt.target, w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitConcurrency(t *testing.T) {
+	saved := concurrencyLogs
+	concurrencyLogs = newErrorSampler(time.Minute)
+	t.Cleanup(func() { concurrencyLogs = saved })
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+
+	// the two slots are taken
+	h := limitConcurrency(2, 20*time.Millisecond)(blocking)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(h, "/search?q=go", nil)
+		}()
+		<-started
+	}
+
+	// a third request waits for the queue timeout then gets a 503
+	var logs bytes.Buffer
+	l := zerolog.New(&logs)
+
+	r := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+	r = r.WithContext(l.WithContext(r.Context()))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("over the limit: status %d, Retry-After %q, want 503 and 1", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	if !strings.Contains(logs.String(), "rejecting request over the concurrency limit") {
+		t.Errorf("the rejection isn't logged: %s", logs.String())
+	}
+
+	// a request queued within the timeout gets the slot freed by a finished one
+	done := make(chan int)
+	queued := limitConcurrency(1, time.Minute)(blocking)
+
+	go func() {
+		serve(queued, "/search?q=go", nil)
+	}()
+	<-started
+
+	go func() {
+		done <- serve(queued, "/search?q=go", nil).Code
+	}()
+
+	close(release)
+	<-started
+
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("queued request: status %d, want 200", code)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,14 @@
+package main
+
+import "html/template"
+
+// externalLinkAttrs returns the attributes of the links to other sites, such as articles and maps.
+// rel="noopener noreferrer" keeps the linked page from reaching this one through window.opener
+// and from learning the search through the Referer header. Links open in a new tab with config.LinksNewTab.
+func externalLinkAttrs() template.HTMLAttr {
+	if config.LinksNewTab {
+		return `target="_blank" rel="noopener noreferrer"`
+	}
+
+	return `rel="noopener noreferrer"`
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestExternalLinkAttrs(t *testing.T) {
+	tests := []struct {
+		newTab bool
+		want   string
+	}{
+		{true, `target="_blank" rel="noopener noreferrer"`},
+		{false, `rel="noopener noreferrer"`},
+	}
+
+	for _, tt := range tests {
+		withConfig(t, func(c *Config) {
+			c.LinksNewTab = tt.newTab
+		})
+
+		if got := string(externalLinkAttrs()); got != tt.want {
+			t.Errorf("LinksNewTab=%t: externalLinkAttrs() = %s, want %s", tt.newTab, got, tt.want)
+		}
+	}
+}
+
+// articleLinkPattern matches the opening tags of the links to the articles
+var articleLinkPattern = regexp.MustCompile(`<a [^>]*href="https://en\.wikipedia\.org[^>]*>`)
+
+func TestArticleLinksAttrs(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	for _, newTab := range []bool{true, false} {
+		withConfig(t, func(c *Config) {
+			c.LinksNewTab = newTab
+		})
+
+		w := serve(handlerWithError(searchHandler), "/search?q=go", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+		}
+
+		links := articleLinkPattern.FindAllString(w.Body.String(), -1)
+		if len(links) == 0 {
+			t.Fatal("no links to the articles")
+		}
+
+		for _, link := range links {
+			if !strings.Contains(link, `rel="noopener noreferrer"`) {
+				t.Errorf("%s has no rel=noopener noreferrer", link)
+			}
+
+			if strings.Contains(link, `target="_blank"`) != newTab {
+				t.Errorf("LinksNewTab=%t: %s", newTab, link)
+			}
+		}
+	}
+}
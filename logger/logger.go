@@ -17,6 +17,31 @@ var once sync.Once
 
 var log zerolog.Logger
 
+// fileLogger writes the logs to a rotated file, nil when they only go to the console
+var fileLogger *lumberjack.Logger
+
+// stopRotation stops the time-based rotation of the log file, if any
+var stopRotation = func() {}
+
+// appEnv returns the environment the app runs in, set in APP_ENV, production by default
+func appEnv() string {
+	if env := os.Getenv("APP_ENV"); env != "" {
+		return env
+	}
+
+	return "production"
+}
+
+// envInt returns the integer value of the environment variable key, or def if it's unset or invalid
+func envInt(key string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+
+	return n
+}
+
 func Get() zerolog.Logger {
 	once.Do(func() {
 		zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
@@ -34,44 +59,102 @@ func Get() zerolog.Logger {
 				"user_agent",
 				"git_revision",
 				"go_version",
+				"env",
+				"service",
 			},
 		}
 
-		if os.Getenv("APP_ENV") != "development" {
-			fileLogger := &lumberjack.Logger{
-				Filename:   "wikipedia-demo.log",
-				MaxSize:    5, //
-				MaxBackups: 10,
-				MaxAge:     14,
+		if appEnv() != "development" {
+			output = os.Stdout
+		}
+
+		// the logs only go to a file when LOG_FILE is set
+		if path := os.Getenv("LOG_FILE"); path != "" {
+			fileLogger = &lumberjack.Logger{
+				Filename:   path,
+				MaxSize:    envInt("LOG_FILE_MAX_SIZE", 5), // megabytes
+				MaxBackups: envInt("LOG_FILE_MAX_BACKUPS", 10),
+				MaxAge:     envInt("LOG_FILE_MAX_AGE", 14), // days
 				Compress:   true,
 			}
 
-			output = zerolog.MultiLevelWriter(os.Stderr, fileLogger)
-		}
+			output = zerolog.MultiLevelWriter(output, fileLogger)
+
+			// lumberjack only rotates on size, LOG_FILE_ROTATE_INTERVAL (e.g. 24h) also rotates on time
+			interval, err := time.ParseDuration(os.Getenv("LOG_FILE_ROTATE_INTERVAL"))
+			if err == nil && interval > 0 {
+				ticker := time.NewTicker(interval)
+				done := make(chan struct{})
 
-		var gitRevision string
+				go func() {
+					for {
+						select {
+						case <-ticker.C:
+							_ = fileLogger.Rotate()
+						case <-done:
+							return
+						}
+					}
+				}()
 
-		buildInfo, ok := debug.ReadBuildInfo()
-		if ok {
-			for _, v := range buildInfo.Settings {
-				if v.Key == "vcs.revision" {
-					gitRevision = v.Value
-					break
+				stopRotation = func() {
+					ticker.Stop()
+					close(done)
 				}
 			}
 		}
 
-		log = zerolog.New(output).
-			Level(zerolog.Level(logLevel)).
-			With().
-			Timestamp().
-			Str("git_revision", gitRevision).
-			Str("go_version", buildInfo.GoVersion).
-			Logger()
+		log = newLogger(output, zerolog.Level(logLevel))
 
-			// fallback in case no logger is found when calling l := zerolog.Ctx(r.Context()) in  searchHandler
+		// fallback in case no logger is found when calling l := zerolog.Ctx(r.Context()) in  searchHandler
 		zerolog.DefaultContextLogger = &log
 	})
 
 	return log
 }
+
+// newLogger returns a logger writing to output which stamps every line with
+// the environment, the service and the build it comes from
+func newLogger(output io.Writer, level zerolog.Level) zerolog.Logger {
+	var gitRevision, goVersion string
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if ok {
+		goVersion = buildInfo.GoVersion
+
+		for _, v := range buildInfo.Settings {
+			if v.Key == "vcs.revision" {
+				gitRevision = v.Value
+				break
+			}
+		}
+	}
+
+	// env and service tell apart the logs of each deployment once they're aggregated
+	service := os.Getenv("SERVICE_NAME")
+	if service == "" {
+		service = "wikipedia-demo"
+	}
+
+	return zerolog.New(output).
+		Level(level).
+		With().
+		Timestamp().
+		Str("env", appEnv()).
+		Str("service", service).
+		Str("git_revision", gitRevision).
+		Str("go_version", goVersion).
+		Logger()
+}
+
+// Close stops the rotation and closes the log file, if any. Logs written afterwards reopen it.
+func Close() error {
+	if fileLogger == nil {
+		return nil
+	}
+
+	stopRotation()
+	stopRotation = func() {}
+
+	return fileLogger.Close()
+}
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestNewLoggerFields(t *testing.T) {
+	tests := []struct {
+		env, service         string
+		wantEnv, wantService string
+	}{
+		{"", "", "production", "wikipedia-demo"},
+		{"development", "", "development", "wikipedia-demo"},
+		{"production", "search-frontend", "production", "search-frontend"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("APP_ENV", tt.env)
+		t.Setenv("SERVICE_NAME", tt.service)
+
+		var buf bytes.Buffer
+		l := newLogger(&buf, zerolog.InfoLevel)
+
+		// a logger derived for a request, as the access logs and errors are
+		rl := l.With().Str("correlation_id", "abc").Logger()
+		rl.Error().Msg("request failed")
+
+		var line map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatalf("invalid log line %q: %v", buf.String(), err)
+		}
+
+		if line["env"] != tt.wantEnv {
+			t.Errorf("APP_ENV=%q: env = %v, want %q", tt.env, line["env"], tt.wantEnv)
+		}
+
+		if line["service"] != tt.wantService {
+			t.Errorf("SERVICE_NAME=%q: service = %v, want %q", tt.service, line["service"], tt.wantService)
+		}
+	}
+}
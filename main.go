@@ -2,18 +2,24 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
-	"log"
 	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/freshman-tech/news-demo/logger"
@@ -24,11 +30,19 @@ import (
 
 var tpl *template.Template
 
+var config Config
+
+// HTTPClient sends the requests to the Wikipedia API, its timeout is set from the configuration
 var HTTPClient = http.Client{
-	Timeout: 30 * time.Second,
+	Timeout: 10 * time.Second,
 }
 
+var wikipediaBreaker *circuitBreaker
+
 type WikipediaSearchResponse struct {
+	// Error is set when the API rejected the search, see searchWikipedia
+	Error *apiError `json:"error,omitempty"`
+
 	BatchComplete string `json:"batchcomplete"`
 	Continue      struct {
 		Sroffset int    `json:"sroffset"`
@@ -38,27 +52,149 @@ type WikipediaSearchResponse struct {
 		SearchInfo struct {
 			TotalHits int `json:"totalhits"`
 		} `json:"searchinfo"`
-		Search []struct {
-			Ns        int       `json:"ns"`
-			Title     string    `json:"title"`
-			PageID    int       `json:"pageid"`
-			Size      int       `json:"size"`
-			WordCount int       `json:"wordcount"`
-			Snippet   string    `json:"snippet"`
-			Timestamp time.Time `json:"timestamp"`
-		} `json:"search"`
+		Search []SearchResult `json:"search"`
+
+		// the matches on sister wikis by interwiki prefix, only with srinterwiki=1
+		InterwikiSearch     map[string][]InterwikiResult `json:"interwikisearch,omitempty"`
+		InterwikiSearchInfo map[string]struct {
+			TotalHits int `json:"totalhits"`
+		} `json:"interwikisearchinfo,omitempty"`
 	} `json:"query"`
 }
 
+// clone returns a copy of the response whose results can be modified
+// without affecting the cached response
+func (w *WikipediaSearchResponse) clone() *WikipediaSearchResponse {
+	c := *w
+	c.Query.Search = append([]SearchResult(nil), w.Query.Search...)
+
+	return &c
+}
+
+type SearchResult struct {
+	Ns        int       `json:"ns"`
+	Title     string    `json:"title"`
+	PageID    int       `json:"pageid"`
+	Size      int       `json:"size"`
+	WordCount int       `json:"wordcount"`
+	Snippet   string    `json:"snippet"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// CleanSnippet is Snippet without the wikitext remnants and irregular spacing, see cleanSnippet
+	CleanSnippet string `json:"clean_snippet,omitempty"`
+
+	// TitleHTML is Title with the words of the query highlighted, filled by the highlight result processor
+	TitleHTML template.HTML `json:"-"`
+
+	// RedirectTitle is the title of the redirect through which the article matched,
+	// only filled when redirects are resolved
+	RedirectTitle string `json:"redirecttitle,omitempty"`
+
+	// only filled by the combined search, see searchWikipediaCombined
+	FullURL   string     `json:"fullurl,omitempty"`
+	Extract   string     `json:"extract,omitempty"`
+	Thumbnail *Thumbnail `json:"thumbnail,omitempty"`
+
+	// only filled when the geo enrichment was requested, see addCoordinates
+	Coordinates *Coordinates `json:"coordinates,omitempty"`
+
+	// Views is the number of views of the article over the last 30 days,
+	// only filled when config.Pageviews is on, see addPageviews
+	Views int `json:"views,omitempty"`
+}
+
+type Thumbnail struct {
+	Source string `json:"source"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// URL returns the link to the article, set by the "url" result processor.
+// A result that didn't go through it is assumed to be from the default edition of Wikipedia.
+func (r SearchResult) URL() string {
+	if r.FullURL != "" {
+		return r.FullURL
+	}
+
+	return articleURL(defaultLang, r.PageID)
+}
+
+// articleURL returns the link to an article of the given language edition of Wikipedia by page ID
+func articleURL(lang string, pageID int) string {
+	return pageURL(defaultProject, lang, pageID)
+}
+
 type Search struct {
-	Query      string
-	TotalPages int
-	NextPage   int
-	Results    *WikipediaSearchResponse
+	Query      string                   `json:"query"`
+	Lang       string                   `json:"lang"`
+	TotalPages int                      `json:"total_pages"`
+	NextPage   int                      `json:"next_page"`
+	PageSize   int                      `json:"page_size"`
+	Results    *WikipediaSearchResponse `json:"results"`
+
+	// ResolveRedirects is set when the redirects results matched through were requested
+	ResolveRedirects bool `json:"resolve_redirects"`
+
+	// Profile is the relevance profile the results were ranked with
+	Profile string `json:"profile"`
+
+	// Project is the Wikimedia project searched, one of projects
+	Project string `json:"project"`
+
+	// Exclude are the terms the results don't contain
+	Exclude []string `json:"exclude,omitempty"`
+
+	// Refine are the terms added to the query to narrow down its results
+	Refine []string `json:"refine,omitempty"`
+
+	// Interwiki is set when the matches on sister wikis were requested, see InterwikiResults
+	Interwiki bool `json:"interwiki"`
+
+	// Safe is set when the results are restricted to the articles outside of the excluded categories
+	Safe bool `json:"safe"`
+
+	// Extracts is set when the intro of each result was requested
+	Extracts bool `json:"extracts"`
+
+	// Order is the order the results of the page were sorted in, empty for the order of the Wikipedia API
+	Order string `json:"order,omitempty"`
+
+	// PageID is set when a single page was looked up by ID instead of searching
+	PageID int `json:"pageid,omitempty"`
+
+	// PastResults is set when Wikipedia returned no results for the page although totalhits promised more.
+	// totalhits is an estimate and results stop before it, the page is past the last one that can be served.
+	PastResults bool `json:"past_results,omitempty"`
+
+	// Geo is set when the coordinates of the results were requested
+	Geo bool `json:"geo"`
+
+	// AllNamespaces is set when the per-namespace breakdown of the matches was requested
+	AllNamespaces bool            `json:"all_namespaces"`
+	NamespaceHits []NamespaceHits `json:"namespace_hits,omitempty"`
+
+	// ResultStats are the aggregates of the results on the page, nil without results
+	ResultStats *ResultStats `json:"result_stats,omitempty"`
+
+	// Warnings tell about the extras of the results that failed or were skipped,
+	// the results are served without them
+	Warnings []string `json:"warnings,omitempty"`
+
+	// ServedAt and ServedBy are the time the search was served and the ID of the instance
+	// that served it, only set when config.ServerInfo is on
+	ServedAt *time.Time `json:"served_at,omitempty"`
+	ServedBy string     `json:"served_by,omitempty"`
+
+	// SnippetLength is the number of characters snippets are truncated to in the template
+	SnippetLength int `json:"-"`
+
+	// Theme is the color theme of the page, one of themes
+	Theme string `json:"-"`
 }
 
+// IsLastPage reports whether the current page is the last one, NextPage being one past it
 func (s *Search) IsLastPage() bool {
-	return s.NextPage >= s.TotalPages
+	return s.PastResults || s.NextPage > s.TotalPages
 }
 
 func (s *Search) CurrentPage() int {
@@ -73,13 +209,221 @@ func (s *Search) PreviousPage() int {
 	return s.CurrentPage() - 1
 }
 
+// IsWikipedia reports whether the search is on Wikipedia rather than one of its sister projects
+func (s *Search) IsWikipedia() bool {
+	return s.Project == "" || s.Project == defaultProject
+}
+
+// HasPreviousPage reports whether there's a page before the current one, i.e. it's not the first page
+func (s *Search) HasPreviousPage() bool {
+	return s.CurrentPage() > 1
+}
+
+// HasNextPage reports whether there's a page after the current one, i.e. it's not the last page
+func (s *Search) HasNextPage() bool {
+	return !s.IsLastPage()
+}
+
+// PageURL returns the URL of the given page of the search, preserving its options
+func (s *Search) PageURL(page int) string {
+	return localURL("/search?" + s.pageValues(page).Encode())
+}
+
+// pageValues returns the query parameters of the given page of the search
+func (s *Search) pageValues(page int) url.Values {
+	v := url.Values{}
+	v.Set("q", s.Query)
+	v.Set("page", strconv.Itoa(page))
+
+	if s.Lang != "" && s.Lang != config.DefaultLang {
+		v.Set("lang", s.Lang)
+	}
+
+	if s.Project != "" && s.Project != defaultProject {
+		v.Set("project", s.Project)
+	}
+
+	if s.AllNamespaces {
+		v.Set("all_namespaces", "1")
+	}
+
+	if s.Geo {
+		v.Set("geo", "1")
+	}
+
+	if s.ResolveRedirects {
+		v.Set("resolve_redirects", "1")
+	}
+
+	if s.Interwiki {
+		v.Set("interwiki", "1")
+	}
+
+	if s.Safe {
+		v.Set("safe", "1")
+	}
+
+	if s.Extracts {
+		v.Set("extracts", "1")
+	}
+
+	if s.Profile != "" && s.Profile != defaultProfile {
+		v.Set("profile", s.Profile)
+	}
+
+	if s.Order != "" {
+		v.Set("order", s.Order)
+	}
+
+	if len(s.Exclude) > 0 {
+		v.Set("exclude", strings.Join(s.Exclude, ","))
+	}
+
+	for _, term := range s.Refine {
+		v.Add("add", term)
+	}
+
+	return v
+}
+
+// RefineFields returns the parameters of the search, for the hidden fields of the form
+// that adds a term to it. The refined search starts over from the first page.
+func (s *Search) RefineFields() url.Values {
+	v := s.pageValues(1)
+	v.Del("page")
+
+	return v
+}
+
+// WithoutRefinementURL returns the URL of the first page of the search without the added term
+func (s *Search) WithoutRefinementURL(term string) string {
+	v := s.pageValues(1)
+	v.Del("add")
+
+	for _, t := range s.Refine {
+		if t != term {
+			v.Add("add", t)
+		}
+	}
+
+	return localURL("/search?" + v.Encode())
+}
+
+// resultItem is the data of the "result" template: a result along with the search it belongs to
+type resultItem struct {
+	Search *Search
+	Result SearchResult
+}
+
+// Item pairs a result with the search for the "result" template
+func (s *Search) Item(result SearchResult) resultItem {
+	return resultItem{Search: s, Result: result}
+}
+
+// FirstResult returns the 1-based index of the first result on the current page, 0 if there are no results
+func (s *Search) FirstResult() int {
+	if s.Results == nil || s.Results.Query.SearchInfo.TotalHits == 0 || s.PastResults {
+		return 0
+	}
+
+	first := (s.CurrentPage()-1)*s.PageSize + 1
+	if first > s.Results.Query.SearchInfo.TotalHits {
+		return 0
+	}
+
+	return first
+}
+
+// LastResult returns the 1-based index of the last result on the current page,
+// which is smaller than a full page on the last page
+func (s *Search) LastResult() int {
+	first := s.FirstResult()
+	if first == 0 {
+		return 0
+	}
+
+	last := first + s.PageSize - 1
+	if total := s.Results.Query.SearchInfo.TotalHits; last > total {
+		last = total
+	}
+
+	return last
+}
+
+// RangeSummary returns a "Showing 21–40 of 1,234 results" line for the current page
+func (s *Search) RangeSummary() string {
+	first, last := s.FirstResult(), s.LastResult()
+	if first == 0 {
+		return ""
+	}
+
+	total := s.Results.Query.SearchInfo.TotalHits
+	noun := "results"
+	if total == 1 {
+		noun = "result"
+	}
+
+	if first == last {
+		return fmt.Sprintf("Showing %s of %s %s", formatNumber(s.Lang, first), formatNumber(s.Lang, total), noun)
+	}
+
+	return fmt.Sprintf(
+		"Showing %s–%s of %s %s",
+		formatNumber(s.Lang, first),
+		formatNumber(s.Lang, last),
+		formatNumber(s.Lang, total),
+		noun,
+	)
+}
+
+// statusError is an error that should be reported to the client with a specific HTTP status code
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
 type handlerWithError func(w http.ResponseWriter, r *http.Request) error
 
 func (fn handlerWithError) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	err := fn(w, r)
 	if err != nil {
-		log.Println(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		code := http.StatusInternalServerError
+
+		var se *statusError
+		if errors.As(err, &se) {
+			code = se.code
+		} else if errors.Is(err, errEmptyQuery) {
+			code = http.StatusBadRequest
+		}
+
+		l := zerolog.Ctx(r.Context())
+
+		if code < http.StatusInternalServerError {
+			// client errors are expected, and their messages carry user input that can't be sampled by
+			l.Info().Err(err).Int("status_code", code).Msg("request rejected")
+		} else {
+			// every failure is logged at debug level, but only a sample of the repeated ones at error level
+			l.Debug().Err(err).Int("status_code", code).Msg("request failed")
+			errorLogs.Log(l, err)
+		}
+
+		// the headers of http.Error without its body, which a HEAD response doesn't have
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(code)
+			return
+		}
+
+		http.Error(w, err.Error(), code)
 		return
 	}
 }
@@ -88,12 +432,13 @@ func (fn handlerWithError) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	size       int
 }
 
 // The loggingResponseWriter struct embeds the http.ResponseWriter type
 // and adds a statusCode property which defaults to http.StatusOK (200) when newLoggingResponseWriter() is called.
 func newLoggingResponseWriter(w http.ResponseWriter) *loggingResponseWriter {
-	return &loggingResponseWriter{w, http.StatusOK}
+	return &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 }
 
 // updates the value of the statusCode property and calls the WriteHeader() method of the embedded http.ResponseWriter instance.
@@ -102,78 +447,615 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
+// Write counts the bytes of the body in the size property
+func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
+	n, err := lrw.ResponseWriter.Write(b)
+	lrw.size += n
+
+	return n, err
+}
+
+// Flush sends the buffered data to the client, for the handlers that stream their response
+func (lrw *loggingResponseWriter) Flush() {
+	if f, ok := lrw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func indexHandler(w http.ResponseWriter, r *http.Request) error {
+	// the index is mounted at "/" which also matches every path without a handler of its own
 	if r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return nil
+		return notFoundHandler(w, r)
+	}
+
+	return landingPage(w, r)
+}
+
+// landingPage renders the search template without results
+func landingPage(w http.ResponseWriter, r *http.Request) error {
+	landing := &Search{
+		Lang:    config.DefaultLang,
+		Project: defaultProject,
+		Theme:   requestTheme(w, r),
 	}
 
-	buf := &bytes.Buffer{}
-	err := tpl.Execute(buf, nil)
+	buf := getBuffer()
+	err := renderSearchPage(buf, landing, zerolog.Ctx(r.Context()))
 	if err != nil {
 		return err
 	}
 
-	_, err = buf.WriteTo(w)
+	writeResponse(w, r, buf)
 
-	return err
+	return nil
 }
 
-func searchWikipedia(
-	searchQuery string,
-	pageSize, resultsOffset int,
-) (*WikipediaSearchResponse, error) {
-	resp, err := HTTPClient.Get(
-		fmt.Sprintf(
-			"https://en.wikipedia.org/w/api.php?action=query&list=search&prop=info&inprop=url&utf8=&format=json&origin=*&srlimit=%d&srsearch=%s&sroffset=%d",
-			pageSize,
-			searchQuery,
-			resultsOffset,
-		),
-	)
+// notFoundHandler renders the 404 page for the paths that match no route
+func notFoundHandler(w http.ResponseWriter, r *http.Request) error {
+	zerolog.Ctx(r.Context()).Info().
+		Str("path", r.URL.Path).
+		Msg("no route for the requested path")
+
+	buf := getBuffer()
+	err := tpl.ExecuteTemplate(buf, "notfound.html", r.URL.Path)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	writeResponse(w, r, buf)
+
+	return nil
+}
+
+// maxPooledBufferSize keeps the occasional huge response from pinning its buffer in the pool
+const maxPooledBufferSize = 1 << 20
+
+// bufferPool recycles the buffers responses are rendered into
+var bufferPool = sync.Pool{
+	New: func() any {
+		return &bytes.Buffer{}
+	},
+}
+
+// getBuffer returns an empty buffer to render a response into, writeResponse gives it back to the pool
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+
+	return buf
+}
+
+// writeResponse sends the fully rendered response in buf, which must not be used afterwards.
+// Rendering errors are caught before anything is written, so a failed write here means
+// the client went away: it's logged as a warning instead of being reported as a server error.
+func writeResponse(w http.ResponseWriter, r *http.Request, buf *bytes.Buffer) {
+	_, err := buf.WriteTo(w)
+	if err != nil {
+		zerolog.Ctx(r.Context()).Warn().
+			Err(err).
+			Msg("unable to write response, the client probably disconnected")
+	}
+
+	if buf.Cap() <= maxPooledBufferSize {
+		bufferPool.Put(buf)
+	}
+}
+
+// wikipediaAPIURL is the endpoint of the MediaWiki action API, {project} is replaced by
+// the Wikimedia project (see projects) and {lang} by the language edition
+const wikipediaAPIURL = "https://{lang}.{project}.org/w/api.php"
+
+// callWikipedia sends a GET request to the API of the lang edition of Wikipedia
+// with the given parameters and decodes the JSON response into v.
+func callWikipedia(ctx context.Context, lang string, params url.Values, v any) error {
+	return callMediaWiki(ctx, defaultProject, lang, params, v)
+}
+
+// callMediaWiki sends a GET request to the API of the lang edition of a Wikimedia project
+// with the given parameters and decodes the JSON response into v.
+// Transient failures are retried up to config.WikipediaMaxRetries times, within the retry budget.
+func callMediaWiki(ctx context.Context, project, lang string, params url.Values, v any) error {
+	ctx = startSpan(ctx, "mediawiki")
+
+	params.Set("format", "json")
+	params.Set("utf8", "")
+
+	if config.WikipediaMaxLag > 0 {
+		params.Set("maxlag", strconv.Itoa(config.WikipediaMaxLag))
+	}
+
+	apiURL := siteURL(wikipediaAPIURL, project, lang)
+	if config.WikipediaAccessToken != "" {
+		apiURL = siteURL(config.WikipediaAuthAPIURL, project, lang)
+	} else {
+		// origin=* is Wikipedia's own CORS parameter for anonymous requests,
+		// it has nothing to do with the CORS policy of this server (see cors.go).
+		// It's left out of authenticated requests as it makes the API ignore the credentials.
+		params.Set("origin", "*")
+	}
+
+	l := zerolog.Ctx(ctx)
+
+	for retry := 0; ; retry++ {
+		err := fetchWikipedia(ctx, apiURL+"?"+params.Encode(), v)
+		if err == nil || !isRetryable(ctx, err) || retry >= config.WikipediaMaxRetries {
+			return err
+		}
+
+		if !retryBudget.Allow() {
+			l.Warn().Err(err).Msg("retry budget exhausted, not retrying the Wikipedia request")
+			return err
+		}
+
+		delay := retryDelay(retry)
+		if d, ok := retryAfter(err); ok {
+			delay = d
+		}
+
+		var le *maxLagError
+		if errors.As(err, &le) {
+			l.Warn().
+				Str("database_lag", le.Header.Get("X-Database-Lag")).
+				Dur("retry_after", delay).
+				Msg("throttled by maxlag, Wikipedia databases are lagging")
+		}
+
+		// don't wait for a retry that can't complete in time
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		l.Debug().
+			Err(err).
+			Int("retry", retry+1).
+			Dur("delay", delay).
+			Msg("retrying the Wikipedia request")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// fetchWikipedia sends a single request to the Wikipedia API and decodes the response into v
+func fetchWikipedia(ctx context.Context, apiURL string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return err
+	}
+
+	if config.WikipediaAccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+string(config.WikipediaAccessToken))
+	}
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return err
 	}
 
 	defer resp.Body.Close()
 
+	// the maxlag error comes with a 200 OK or a 503 depending on the API version
+	if resp.Header.Get("MediaWiki-API-Error") == "maxlag" {
+		return &maxLagError{Header: resp.Header}
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		respData, _ := httputil.DumpResponse(resp, true)
 
-		return nil, fmt.Errorf(
-			"non 200 OK response from Wikipedia API: %s",
-			string(respData),
-		)
+		return &apiStatusError{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			dump:       string(respData),
+		}
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	decoded, err := responseBody(resp)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
+	defer decoded.Close()
+
+	// the response is decoded as it streams in rather than buffered whole,
+	// reading one byte past the limit tells a response that reaches it from one that exceeds it.
+	// The limit applies to the uncompressed body, so that a small compressed one can't bypass it.
+	limit := int64(config.WikipediaMaxResponseSize)
+	body := &countingReader{r: io.LimitReader(decoded, limit+1)}
+
+	err = json.NewDecoder(body).Decode(v)
+	if err == nil {
+		// drain what the decoder didn't need so the connection can be reused
+		_, err = io.Copy(io.Discard, body)
+	}
+	if body.n > limit {
+		return fmt.Errorf("%w: more than %d bytes", errResponseTooLarge, limit)
+	}
+	if err != nil {
+		return err
+	}
+
+	stats.wikipediaRequests.Add(1)
+	stats.wikipediaBytesReceived.Add(body.n)
+
+	zerolog.Ctx(ctx).Debug().
+		Str("search_query", req.URL.Query().Get("srsearch")).
+		Int64("response_size_bytes", body.n).
+		Msg("received Wikipedia API response")
+
+	return nil
+}
+
+// responseBody returns the body of resp, decompressed if it's gzip-encoded.
+// The transport only decompresses the responses to the requests it added Accept-Encoding to:
+// this covers a request or a custom transport that sets the header itself.
+func responseBody(resp *http.Response) (io.ReadCloser, error) {
+	if resp.Uncompressed || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return io.NopCloser(resp.Body), nil
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip-encoded response: %w", err)
+	}
+
+	return gz, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
+
+// searchOptions are the parameters of a search on Wikipedia
+type searchOptions struct {
+	Query string
+	// Project is the Wikimedia project searched, one of projects
+	Project string
+	Lang    string
+	Limit   int
+	Offset  int
+
+	// ResolveRedirects reports the redirects through which results matched
+	ResolveRedirects bool
+
+	// Profile is the relevance profile used to rank the results, one of searchProfiles
+	Profile string
+
+	// Exclude are the terms results must not contain
+	Exclude []string
+
+	// Refine are the terms results must contain on top of the query
+	Refine []string
+
+	// Interwiki adds the matches on sister wikis to the results
+	Interwiki bool
+
+	// Safe restricts the results to the articles outside of config.SafeSearchExcludedCategories
+	Safe bool
+
+	// Extracts runs a combined search to get the intro of each result, see combined
+	Extracts bool
+}
+
+// searchTerms returns the search string sent to Wikipedia: the query followed by the refinements,
+// which the search ANDs with it, and the excluded terms in the negation syntax, all quoted so they're matched literally.
+// Safe searches also exclude the pages of config.SafeSearchExcludedCategories.
+func (o searchOptions) searchTerms() string {
+	terms := o.Query
+
+	for _, term := range o.Refine {
+		terms += ` "` + term + `"`
+	}
+
+	for _, term := range o.Exclude {
+		terms += ` -"` + term + `"`
+	}
+
+	if o.Safe {
+		for _, category := range config.SafeSearchExcludedCategories {
+			terms += ` -incategory:"` + category + `"`
+		}
+	}
+
+	return terms
+}
+
+func (o searchOptions) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("query", o.Query).
+		Str("project", o.Project).
+		Str("lang", o.Lang).
+		Int("limit", o.Limit).
+		Int("offset", o.Offset).
+		Str("profile", o.Profile).
+		Strs("exclude", o.Exclude).
+		Strs("refine", o.Refine).
+		Bool("interwiki", o.Interwiki).
+		Bool("safe", o.Safe).
+		Bool("extracts", o.Extracts).
+		Bool("resolve_redirects", o.ResolveRedirects)
+}
+
+// apiParams returns the parameters of the list=search API request for the search
+func (o searchOptions) apiParams() url.Values {
+	params := url.Values{}
+	params.Set("action", "query")
+	params.Set("list", "search")
+	params.Set("prop", "info")
+	params.Set("inprop", "url")
+	params.Set("srlimit", strconv.Itoa(o.Limit))
+	params.Set("srsearch", o.searchTerms())
+	params.Set("sroffset", strconv.Itoa(o.Offset))
+	params.Set("srqiprofile", o.Profile)
+
+	if o.ResolveRedirects {
+		params.Set("redirects", "1")
+		// the default properties plus the redirect the result matched through
+		params.Set("srprop", "size|wordcount|timestamp|snippet|redirecttitle")
+	}
+
+	if o.Interwiki {
+		params.Set("srinterwiki", "1")
+	}
+
+	if o.Safe {
+		params.Set("srnamespace", "0")
+	}
+
+	return params
+}
+
+func searchWikipedia(ctx context.Context, opts searchOptions) (*WikipediaSearchResponse, error) {
+	// the API rejects an empty srsearch, not worth a request
+	if strings.TrimSpace(opts.Query) == "" {
+		return nil, errEmptyQuery
+	}
+
+	params := opts.apiParams()
+
+	zerolog.Ctx(ctx).Debug().
+		EmbedObject(opts).
+		Msg("searching Wikipedia")
+
 	var searchResponse WikipediaSearchResponse
 
-	err = json.Unmarshal(body, &searchResponse)
+	err := callMediaWiki(ctx, opts.Project, opts.Lang, params, &searchResponse)
 	if err != nil {
 		return nil, err
 	}
 
+	// the API reports the searches it rejects in a 200 response
+	if searchResponse.Error != nil {
+		return nil, searchResponse.Error
+	}
+
+	checkSearchResponse(zerolog.Ctx(ctx), &searchResponse)
+
+	config.resultPipeline.Process(opts, searchResponse.Query.Search)
+
 	return &searchResponse, nil
 }
 
+// countPages returns the number of pages of pageSize results needed for totalHits.
+// Wikipedia doesn't serve results past maxOffset whatever totalhits says,
+// so the count is capped to the pages within that limit and capped is set.
+func countPages(totalHits, pageSize, maxOffset int) (pages int, capped bool) {
+	pages = int(math.Ceil(float64(totalHits) / float64(pageSize)))
+
+	maxPages := maxOffset / pageSize
+	if maxPages < 1 {
+		maxPages = 1
+	}
+
+	if pages > maxPages {
+		return maxPages, true
+	}
+
+	return pages, false
+}
+
+// apiSearchRequest is the JSON body of a POST to /api/search
+type apiSearchRequest struct {
+	Query string `json:"q"`
+	Page  int    `json:"page"`
+	Lang  string `json:"lang"`
+}
+
+// apiSearchHandler serves /api/search, where the search parameters can be sent
+// either in the query string of a GET or as a JSON body in a POST
+func apiSearchHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return searchHandler(w, r)
+	}
+
+	var req apiSearchRequest
+
+	err := decodeJSONBody(r, &req)
+	if err != nil {
+		return err
+	}
+
+	v := url.Values{}
+	v.Set("q", req.Query)
+
+	if req.Page > 0 {
+		v.Set("page", strconv.Itoa(req.Page))
+	}
+
+	if req.Lang != "" {
+		v.Set("lang", req.Lang)
+	}
+
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = v.Encode()
+
+	return searchHandler(w, r)
+}
+
 func searchHandler(w http.ResponseWriter, r *http.Request) error {
-	u, err := url.Parse(r.URL.String())
+	if config.Debug && r.URL.Query().Get("raw") == "1" {
+		return rawSearchHandler(w, r)
+	}
+
+	callback, err := jsonpCallback(r)
 	if err != nil {
 		return err
 	}
 
+	if r.Method == http.MethodHead {
+		return headSearch(w, r, callback)
+	}
+
+	timing := newServerTiming()
+
+	search, err := runSearch(w, r, timing)
+	// without a query the search page is the landing page, the other formats get the 400
+	if errors.Is(err, errEmptyQuery) && callback == "" && negotiateFormat(r) == formatHTML {
+		return landingPage(w, r)
+	}
+	if err != nil {
+		return err
+	}
+
+	buf := getBuffer()
+	phaseStart := time.Now()
+
+	// the "format" query parameter wins over the Accept header
+	format := negotiateFormat(r)
+	if callback != "" {
+		format = formatJSONP
+	}
+
+	switch format {
+	case formatJSONP:
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		err = writeJSONP(buf, callback, search)
+	case formatJSON:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = json.NewEncoder(buf).Encode(search)
+	case formatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		err = writeText(buf, search)
+	default:
+		err = renderSearchPage(buf, search, zerolog.Ctx(r.Context()))
+	}
+	if err != nil {
+		return err
+	}
+
+	if config.ServerTiming {
+		timing.Add("render", "", phaseStart)
+		w.Header().Set("Server-Timing", timing.Header())
+	}
+
+	writeResponse(w, r, buf)
+
+	// log success
+	zerolog.Ctx(r.Context()).Trace().Msgf("search query '%s' succeeded without errors", search.Query)
+
+	return nil
+}
+
+// headSearch answers a HEAD request to /search, usually a monitoring check, with the status and
+// content type a GET would get without searching: the parameters are validated
+// but Wikipedia isn't called and no body is written.
+func headSearch(w http.ResponseWriter, r *http.Request, callback string) error {
+	p, err := parseSearchParams(r.URL.Query(), r.Header.Get("Accept-Language"), config)
+	if err != nil {
+		return err
+	}
+
+	format := negotiateFormat(r)
+	if callback != "" {
+		format = formatJSONP
+	}
+
+	// without a query the HTML format is the landing page, as in searchHandler
+	if strings.TrimSpace(p.Query) == "" && p.PageID == 0 && format != formatHTML {
+		return &statusError{http.StatusBadRequest, errEmptyQuery}
+	}
+
+	switch format {
+	case formatJSONP:
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+	case formatJSON:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	case formatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	default:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// resultsFragmentHandler serves /search/results, which takes the parameters of /search
+// but only renders the result items, for pages that load more results without reloading
+func resultsFragmentHandler(w http.ResponseWriter, r *http.Request) error {
+	search, err := runSearch(w, r, newServerTiming())
+	if err != nil {
+		return err
+	}
+
+	buf := getBuffer()
+
+	err = tpl.ExecuteTemplate(buf, "results", search)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
+
+// runSearch runs the search described by the query parameters of r,
+// recording the time spent in each phase in timing
+func runSearch(w http.ResponseWriter, r *http.Request, timing *serverTiming) (*Search, error) {
+	u, err := url.Parse(r.URL.String())
+	if err != nil {
+		return nil, err
+	}
+
 	params := u.Query()
-	searchQuery := params.Get("q")
-	pageNum := params.Get("page")
-	if pageNum == "" {
-		pageNum = "1"
+
+	p, err := parseSearchParams(params, r.Header.Get("Accept-Language"), config)
+	if err != nil {
+		return nil, err
+	}
+
+	// the language of the results depends on the header when it's not in the URL
+	if params.Get("lang") == "" {
+		w.Header().Add("Vary", "Accept-Language")
 	}
 
+	searchQuery := p.Query
+	lang := p.Lang
+	allNamespaces := p.AllNamespaces
+	geo := p.Geo
+	resolveRedirects := p.ResolveRedirects
+	profile := p.Profile
+	nextPage := p.Page
+	pageNum := strconv.Itoa(nextPage)
+
 	// get the logger from the request context
 	l := zerolog.Ctx(r.Context())
 	// update the logger context to add the "search_query" & "page_num" fields
@@ -181,51 +1063,214 @@ func searchHandler(w http.ResponseWriter, r *http.Request) error {
 		return c.Str("search_query", searchQuery).Str("page_num", pageNum)
 	})
 
+	// empty queries are logged apart so they don't count as searches
+	if strings.TrimSpace(searchQuery) == "" && p.PageID == 0 {
+		l.Debug().Msg("empty search query, not searching")
+
+		return nil, &statusError{http.StatusBadRequest, errEmptyQuery}
+	}
+
 	// log search query
 	l.Info().
 		Msgf("incoming search query '%s' on page '%s'", searchQuery, pageNum)
 
-	nextPage, err := strconv.Atoi(pageNum)
-	if err != nil {
-		return err
+	if v := params.Get("profile"); v != "" && v != profile {
+		l.Warn().
+			Str("profile", v).
+			Msgf("ignoring unknown search profile, using '%s'", defaultProfile)
 	}
 
-	pageSize := 20
+	pageSize := config.PageSize
+
+	phaseStart := time.Now()
 
-	resultsOffset := (nextPage - 1) * pageSize
+	opts := p.options(pageSize)
 
-	searchResponse, err := searchWikipedia(searchQuery, pageSize, resultsOffset)
+	var searchResponse *WikipediaSearchResponse
+	timingDesc := "Wikipedia page lookup"
+
+	if p.PageID > 0 {
+		searchResponse, err = lookupPage(r.Context(), p.Project, lang, p.PageID)
+		if errors.Is(err, errPageNotFound) {
+			return nil, &statusError{
+				http.StatusNotFound,
+				fmt.Errorf("no page with ID %d", p.PageID),
+			}
+		}
+	} else {
+		var cacheStatus string
+
+		searchResponse, cacheStatus, err = cachedSearch(r.Context(), opts)
+		w.Header().Set("X-Cache", cacheStatus)
+		timingDesc = "Wikipedia search (" + cacheStatus + ")"
+	}
+	if errors.Is(err, errCircuitOpen) {
+		return nil, &statusError{http.StatusServiceUnavailable, err}
+	}
+	// a cache hit is still served when the deadline has passed, only a failed search times out
+	if err != nil && (errors.Is(err, context.DeadlineExceeded) || errors.Is(r.Context().Err(), context.DeadlineExceeded)) {
+		return nil, &statusError{http.StatusGatewayTimeout, err}
+	}
+	var ae *apiError
+	if errors.As(err, &ae) {
+		return nil, ae.statusError(l)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	timing.Add("search", timingDesc, phaseStart)
+
 	// log response from the Wikipedia API
 	l.Debug().Interface("wikipedia_search_response", searchResponse).Send()
 
+	// the enrichments share what's left of the request deadline
+	budget := newEnrichmentBudget(r.Context())
+	defer budget.Close(l)
+
+	// the results are still worth showing without the extras of the enrichments that fail
+	var warnings []string
+
+	if geo {
+		phaseStart = time.Now()
+		searchResponse = searchResponse.clone()
+
+		done := budget.Run("geo", func(ctx context.Context) error {
+			err := addCoordinates(ctx, p.Project, lang, searchResponse)
+			if cutShort(ctx, err) {
+				return err
+			}
+			if err != nil {
+				l.Warn().Err(err).Msg("unable to fetch the coordinates of the results")
+				warnings = append(warnings, "The map links are unavailable.")
+			}
+
+			return nil
+		})
+		if !done {
+			warnings = append(warnings, "The map links were left out to answer in time.")
+		}
+
+		timing.Add("geo", "Wikipedia coordinates", phaseStart)
+	}
+
+	if config.Pageviews && p.PageID == 0 {
+		phaseStart = time.Now()
+		searchResponse = searchResponse.clone()
+
+		done := budget.Run("views", func(ctx context.Context) error {
+			failed, err := addPageviews(ctx, p.Project, lang, searchResponse)
+			if failed > 0 {
+				warnings = append(warnings, fmt.Sprintf("The views of %d results are unavailable.", failed))
+			}
+
+			return err
+		})
+		if !done {
+			warnings = append(warnings, "The views were left out to answer in time.")
+		}
+
+		timing.Add("views", "Wikimedia pageviews", phaseStart)
+	}
+
+	// the order only applies to the page, after the enrichments that match the results by position
+	if p.Order != "" {
+		searchResponse = searchResponse.clone()
+		sortResults(searchResponse.Query.Search, p.Order)
+	}
+
 	totalHits := searchResponse.Query.SearchInfo.TotalHits
 
+	if totalHits == 0 {
+		logZeroResults(l, opts)
+	}
+
+	totalPages, capped := countPages(totalHits, pageSize, config.MaxResultOffset)
+	if capped {
+		l.Debug().
+			Int("total_hits", totalHits).
+			Int("total_pages", totalPages).
+			Msg("total pages capped to the results the Wikipedia API can serve")
+	}
+
 	search := &Search{
 		Query:      searchQuery,
+		Lang:       lang,
 		Results:    searchResponse,
-		TotalPages: int(math.Ceil(float64(totalHits) / float64(pageSize))),
+		TotalPages: totalPages,
 		NextPage:   nextPage + 1,
+		PageSize:   pageSize,
+
+		Geo:              geo,
+		ResolveRedirects: resolveRedirects,
+		Profile:          profile,
+		Project:          p.Project,
+		Exclude:          p.Exclude,
+		Refine:           p.Refine,
+		Interwiki:        p.Interwiki,
+		Safe:             p.Safe,
+		Extracts:         p.Extracts,
+		Order:            p.Order,
+		PageID:           p.PageID,
+		PastResults:      p.PageID == 0 && len(searchResponse.Query.Search) == 0 && totalHits > opts.Offset,
+		AllNamespaces:    allNamespaces,
+		ResultStats:      newResultStats(searchResponse.Query.Search),
+		SnippetLength:    config.SnippetLength,
+		Theme:            requestTheme(w, r),
+		Warnings:         warnings,
 	}
 
-	buf := &bytes.Buffer{}
-	err = tpl.Execute(buf, search)
-	if err != nil {
-		return err
+	if config.ServerInfo {
+		servedAt := time.Now().UTC()
+		search.ServedAt = &servedAt
+		search.ServedBy = config.instanceID
 	}
 
-	_, err = buf.WriteTo(w)
-	if err != nil {
-		return err
+	if search.PastResults {
+		l.Debug().
+			Int("total_hits", totalHits).
+			Int("offset", opts.Offset).
+			Msg("no results past the offset Wikipedia serves for this search")
 	}
 
-	// log success
-	l.Trace().Msgf("search query '%s' succeeded without errors", searchQuery)
+	// prefetching is pointless without a cache to keep the results in
+	if config.PrefetchNextPage && config.searchCacheTTL() > 0 && !search.IsLastPage() {
+		next := opts
+		next.Offset += pageSize
+		prefetchSearch(*l, next)
+	}
 
-	return nil
+	if allNamespaces {
+		phaseStart = time.Now()
+
+		done := budget.Run("namespaces", func(ctx context.Context) error {
+			var err error
+			search.NamespaceHits, err = countNamespaceHits(ctx, p.Project, lang, opts.searchTerms())
+
+			return err
+		})
+
+		if !done {
+			search.Warnings = append(search.Warnings, "The namespace counts were left out to answer in time.")
+		} else {
+			for _, ns := range search.NamespaceHits {
+				if ns.Error != "" {
+					search.Warnings = append(search.Warnings, "Some namespace counts are unavailable.")
+					break
+				}
+			}
+		}
+
+		timing.Add("namespaces", "Wikipedia namespace counts", phaseStart)
+	}
+
+	return search, nil
+}
+
+// newCorrelationID generates the ID used to correlate the logs of a request.
+// It's a variable so that tests can swap it for a deterministic generator.
+var newCorrelationID = func() string {
+	return xid.New().String()
 }
 
 // logger middleware returns an HTTP handler that logs several details about the HTTP request
@@ -236,7 +1281,7 @@ func requestLogger(next http.Handler) http.Handler {
 		l := logger.Get()
 
 		// generated the correlationID
-		correlationID := xid.New().String()
+		correlationID := newCorrelationID()
 		// add the correlationID to the request context
 		ctx := context.WithValue(r.Context(), "correlation_id", correlationID)
 		r = r.WithContext(ctx)
@@ -269,8 +1314,10 @@ func requestLogger(next http.Handler) http.Handler {
 				Str("method", r.Method).
 				Str("url", r.URL.RequestURI()).
 				Str("user_agent", r.UserAgent()).
+				Str("client_ip", clientIP(r)).
 				Dur("elapsed_ms", time.Since(start)).
 				Int("status_code", lrw.statusCode).
+				Int("response_size_bytes", lrw.size).
 				Msg("incoming request")
 		}()
 
@@ -300,8 +1347,97 @@ func requestLogger2(next http.Handler) http.Handler {
 	return h(accessHandler(userAgentHandler(next)))
 }
 
-func htmlSafe(str string) template.HTML {
-	return template.HTML(str)
+// stateJSON serializes v for embedding in a <script type="application/json"> element,
+// so that client-side scripts can pick up the page state without a second request.
+// json.Marshal escapes <, > and & as \u003c, \u003e and \u0026, so the data can't close the script
+// element or open a comment, and returning template.JS keeps html/template from escaping it again.
+func stateJSON(v any) (template.JS, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return template.JS(data), nil
+}
+
+// templateFiles are the templates parsed from the template directory, the first one is executed by default
+var templateFiles = []string{
+	"index.html", "results.html", "category.html", "bookmarks.html", "notfound.html", "compare.html",
+	"maintenance.html",
+}
+
+// loadTemplates parses the templates in dir, or the embedded ones if dir is empty.
+// A misspelled field of a struct fails on execution, missingkey=error does the same for a map key,
+// which would otherwise render "<no value>": checkTemplates then reports both at startup.
+func loadTemplates(dir string) (*template.Template, error) {
+	fsys, err := templateFS(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return template.New(templateFiles[0]).Option("missingkey=error").Funcs(template.FuncMap{
+		"truncate":     truncate,
+		"highlight":    highlight,
+		"formatNumber": formatNumber,
+		"formatDate":   formatDate,
+		"attr":         attr,
+		"languages": func() []wikiLanguage {
+			return supportedLanguages
+		},
+		"themes": func() []string {
+			return themes
+		},
+		"projects": func() []wikiProject {
+			return projects
+		},
+		"join":      strings.Join,
+		"stateJSON": stateJSON,
+		"round": func(f float64) int {
+			return int(math.Round(f))
+		},
+		"externalLinkAttrs": externalLinkAttrs,
+		"features": func() Features {
+			return config.Features
+		},
+		"localURL": localURL,
+		"orders": func() []resultOrder {
+			return resultOrders
+		},
+	}).ParseFS(fsys, templateFiles...)
+}
+
+// checkDir returns an actionable error if dir doesn't exist, env is the variable that sets it
+func checkDir(dir, env string) error {
+	abs, _ := filepath.Abs(dir)
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf(
+			"directory '%s' not found (resolved from '%s' in working directory): set %s to the right path: %w",
+			abs, dir, env, err,
+		)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory: set %s to the right path", abs, env)
+	}
+
+	return nil
+}
+
+// checkFile returns an actionable error if the file at path doesn't exist, env is the variable that sets its directory
+func checkFile(path, env string) error {
+	abs, _ := filepath.Abs(path)
+
+	_, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf(
+			"file '%s' not found: set %s to the directory that contains it: %w",
+			abs, env, err,
+		)
+	}
+
+	return nil
 }
 
 var err error
@@ -309,34 +1445,190 @@ var err error
 func init() {
 	l := logger.Get()
 
-	tpl, err = template.New("index.html").Funcs(template.FuncMap{
-		"htmlSafe": htmlSafe,
-	}).ParseFiles("index.html")
+	config, err = loadConfig()
+	if err != nil {
+		l.Fatal().Err(err).Msg("Invalid configuration")
+	}
+
+	l.Info().
+		Object("config", config).
+		Str("log_level", l.GetLevel().String()).
+		Msg("Configuration loaded")
+
+	HTTPClient.Timeout = time.Duration(config.WikipediaTimeout)
+
+	tpl, err = loadTemplates(config.TemplateDir)
 	if err != nil {
 		l.Fatal().Err(err).Msg("Unable to initialize HTML templates")
 	}
-}
 
-func main() {
-	l := logger.Get()
+	assets, err = assetsFS(config.AssetsDir)
+	if err != nil {
+		l.Fatal().Err(err).Msg("Unable to find the static assets")
+	}
+
+	err = checkTemplates()
+	if err != nil {
+		l.Fatal().Err(err).Msg("Unable to render HTML templates")
+	}
+
+	retryBudget = newTokenBucket(
+		config.RetryBudget,
+		time.Duration(config.RetryBudgetRefill),
+	)
+
+	wikipediaBreaker = newCircuitBreaker(
+		config.BreakerThreshold,
+		time.Duration(config.BreakerCooldown),
+	)
+
+	responseCache = newSearchCache(
+		config.searchCacheTTL(),
+		time.Duration(config.CacheStaleTTL),
+		config.CacheMaxEntries,
+	)
 
-	fs := http.FileServer(http.Dir("assets"))
+	suggestionCache = newAutocompleteCache(
+		time.Duration(config.AutocompleteCacheTTL),
+		config.CacheMaxEntries,
+	)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3001"
+	if config.RateLimitBurst > 0 {
+		rateLimiter = newIPRateLimiter(config.RateLimitBurst, time.Duration(config.RateLimitRefill))
 	}
+}
+
+// newMux routes the requests to the handlers, "/" serves the index and the paths that match no route
+func newMux() *http.ServeMux {
+	fs := serveAssets(assets, config.AssetExtensions)
 
 	mux := http.NewServeMux()
 	mux.Handle("/assets/", http.StripPrefix("/assets/", fs))
 	mux.Handle("/search", handlerWithError(searchHandler))
+	mux.Handle("/search/results", handlerWithError(resultsFragmentHandler))
+	if config.Features.Export {
+		mux.Handle("/search/export", handlerWithError(exportHandler))
+	}
+	mux.Handle("/search/more", handlerWithError(moreHandler))
+	mux.Handle("/opensearch.xml", handlerWithError(openSearchHandler))
+	mux.Handle("/s/", handlerWithError(shareHandler))
+	mux.Handle("/category", handlerWithError(categoryHandler))
+	mux.Handle("/compare", handlerWithError(compareHandler))
+	mux.Handle("/random", handlerWithError(randomHandler))
+	if config.Features.Stats {
+		mux.Handle("/stats", handlerWithError(statsHandler))
+	}
+	mux.Handle("/version", handlerWithError(versionHandler))
+	mux.Handle("/healthz", handlerWithError(healthzHandler))
+	mux.Handle("/bookmarks", handlerWithError(bookmarksHandler))
+	mux.Handle("/bookmarks/add", handlerWithError(addBookmarkHandler))
+	mux.Handle("/bookmarks/remove", handlerWithError(removeBookmarkHandler))
+	mux.Handle("/admin/cache/flush", requireAdmin(cacheFlushHandler))
+	mux.Handle("/admin/ratelimit", requireAdmin(rateLimitHandler))
+	mux.Handle("/admin/features", requireAdmin(featuresHandler))
+	mux.Handle("/admin/maintenance", requireAdmin(maintenanceHandler))
+
+	api := http.NewServeMux()
+	api.Handle("/api/search", handlerWithError(apiSearchHandler))
+	if config.Features.Autocomplete {
+		api.Handle("/api/autocomplete", handlerWithError(autocompleteHandler))
+	}
+	api.Handle("/api/languages", handlerWithError(languagesHandler))
+
+	mux.Handle("/api/", cors(config.CORSAllowedOrigins)(api))
 	mux.Handle("/", handlerWithError(indexHandler))
 
+	return mux
+}
+
+func main() {
+	l := logger.Get()
+
+	port := config.Port
+
+	var handler http.Handler = newMux()
+	handler = validateRequestURI(config.MaxURILength)(handler)
+	handler = limitRequestBody(int64(config.MaxBodySize))(handler)
+	handler = requestTimeout(
+		time.Duration(config.RequestTimeout),
+		time.Duration(config.MaxRequestTimeout),
+	)(handler)
+	handler = maintenance(handler)
+	// inside the request logger, so that rejected requests are logged too
+	handler = basicAuth(config.BasicAuthUser, config.BasicAuthPass)(handler)
+	handler = rateLimit(rateLimiter)(handler)
+	handler = limitConcurrency(
+		config.MaxConcurrentRequests,
+		time.Duration(config.ConcurrencyQueueTimeout),
+	)(handler)
+
+	maintenanceMode.Store(config.MaintenanceMode)
+	if config.MaintenanceMode {
+		l.Warn().Msg("starting in maintenance mode, requests are answered with the maintenance page")
+	}
+
+	// the server starts right away, searches are served from Wikipedia until their warm-up is done
+	if config.searchCacheTTL() > 0 {
+		go warmCache(config.WarmupQueries)
+	}
+
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           requestLogger(handler),
+		ReadHeaderTimeout: time.Duration(config.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(config.ReadTimeout),
+		WriteTimeout:      time.Duration(config.WriteTimeout),
+		IdleTimeout:       time.Duration(config.IdleTimeout),
+	}
+
 	l.Info().
 		Str("port", port).
+		Dur("read_header_timeout", server.ReadHeaderTimeout).
+		Dur("read_timeout", server.ReadTimeout).
+		Dur("write_timeout", server.WriteTimeout).
+		Dur("idle_timeout", server.IdleTimeout).
+		Dur("wikipedia_timeout", HTTPClient.Timeout).
 		Msgf("Starting Wikipedia App Server on port '%s'", port)
 
-	l.Fatal().
-		Err(http.ListenAndServe(":"+port, requestLogger(mux))).
-		Msg("Wikipedia App Server Closed")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if rateLimiter != nil {
+		go rateLimiter.pruneEvery(ctx, rateLimitPruneInterval)
+	}
+
+	shutdown := make(chan struct{})
+
+	// on SIGINT or SIGTERM, in-flight requests are given WriteTimeout to complete
+	go func() {
+		defer close(shutdown)
+
+		<-ctx.Done()
+
+		l.Info().Msg("Shutting down Wikipedia App Server")
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.WriteTimeout))
+		defer cancel()
+
+		err := server.Shutdown(ctx)
+		if err != nil {
+			l.Error().Err(err).Msg("Unable to shut down Wikipedia App Server gracefully")
+		}
+	}()
+
+	err = server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		l.Error().Err(err).Msg("Wikipedia App Server Closed")
+		_ = logger.Close()
+		os.Exit(1)
+	}
+
+	<-shutdown
+
+	l.Info().Msg("Wikipedia App Server Closed")
+
+	err = logger.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unable to close the log file:", err)
+	}
 }
@@ -0,0 +1,1009 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// searchResponseJSON is a response of the Wikipedia search API with three results
+const searchResponseJSON = `{
+	"batchcomplete": "",
+	"continue": {"sroffset": 20, "continue": "-||"},
+	"query": {
+		"searchinfo": {"totalhits": 1234},
+		"search": [
+			{"ns": 0, "title": "Go (programming language)", "pageid": 25039021, "size": 300, "wordcount": 30,
+				"snippet": "<span class=\"searchmatch\">Go</span> is a programming language", "timestamp": "2023-01-01T00:00:00Z"},
+			{"ns": 0, "title": "Go (game)", "pageid": 12454, "size": 100, "wordcount": 10,
+				"snippet": "<span class=\"searchmatch\">Go</span> is a board game", "timestamp": "2023-01-02T00:00:00Z"},
+			{"ns": 0, "title": "gopher", "pageid": 99, "size": 200, "wordcount": 20,
+				"snippet": "A burrowing rodent", "timestamp": "2023-01-03T00:00:00Z"}
+		]
+	}
+}`
+
+// roundTripFunc stubs the transport of HTTPClient
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// stubWikipedia answers the requests to Wikipedia with fn for the duration of the test.
+// The search cache and the circuit breaker start afresh so that each test reaches the stub.
+func stubWikipedia(t testing.TB, fn roundTripFunc) {
+	t.Helper()
+
+	transport, cache, breaker := HTTPClient.Transport, responseCache, wikipediaBreaker
+	t.Cleanup(func() {
+		HTTPClient.Transport, responseCache, wikipediaBreaker = transport, cache, breaker
+	})
+
+	HTTPClient.Transport = fn
+	responseCache = newSearchCache(config.searchCacheTTL(), time.Duration(config.CacheStaleTTL), config.CacheMaxEntries)
+	wikipediaBreaker = newCircuitBreaker(config.BreakerThreshold, time.Duration(config.BreakerCooldown))
+}
+
+// stubSearch answers every request to Wikipedia with body and counts them
+func stubSearch(t testing.TB, body string) *atomic.Int64 {
+	t.Helper()
+
+	calls := new(atomic.Int64)
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return jsonResponse(r, body), nil
+	})
+
+	return calls
+}
+
+// jsonResponse returns a 200 OK response to r with the JSON body
+func jsonResponse(r *http.Request, body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}
+}
+
+// withConfig changes the configuration for the duration of the test
+func withConfig(t testing.TB, change func(c *Config)) {
+	t.Helper()
+
+	saved := config
+	t.Cleanup(func() { config = saved })
+
+	change(&config)
+}
+
+// serve sends a GET request for target to h, with the given headers, and returns the response.
+// The request carries a logger of its own, as requestLogger does, which discards the logs.
+func serve(h http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r = r.WithContext(zerolog.New(io.Discard).WithContext(r.Context()))
+	for name, values := range header {
+		r.Header[name] = values
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+// pageOfSearch returns the search showing the given page of pageSize results out of totalHits
+func pageOfSearch(totalHits, page, pageSize int) *Search {
+	results := &WikipediaSearchResponse{}
+	results.Query.SearchInfo.TotalHits = totalHits
+
+	totalPages, _ := countPages(totalHits, pageSize, config.MaxResultOffset)
+
+	return &Search{
+		Lang:       "en",
+		Results:    results,
+		TotalPages: totalPages,
+		NextPage:   page + 1,
+		PageSize:   pageSize,
+	}
+}
+
+func TestSearchRange(t *testing.T) {
+	tests := []struct {
+		totalHits, page, pageSize int
+		first, last               int
+		summary                   string
+	}{
+		{0, 1, 20, 0, 0, ""},
+		{1, 1, 20, 1, 1, "Showing 1 of 1 result"},
+		{15, 1, 20, 1, 15, "Showing 1–15 of 15 results"},
+		{20, 1, 20, 1, 20, "Showing 1–20 of 20 results"},
+		{1234, 1, 20, 1, 20, "Showing 1–20 of 1,234 results"},
+		{1234, 2, 20, 21, 40, "Showing 21–40 of 1,234 results"},
+		{1234, 62, 20, 1221, 1234, "Showing 1,221–1,234 of 1,234 results"},
+		{41, 3, 20, 41, 41, "Showing 41 of 41 results"},
+		{40, 3, 20, 0, 0, ""},
+	}
+
+	for _, tt := range tests {
+		s := pageOfSearch(tt.totalHits, tt.page, tt.pageSize)
+
+		if got := s.FirstResult(); got != tt.first {
+			t.Errorf("page %d of %d hits: FirstResult() = %d, want %d", tt.page, tt.totalHits, got, tt.first)
+		}
+
+		if got := s.LastResult(); got != tt.last {
+			t.Errorf("page %d of %d hits: LastResult() = %d, want %d", tt.page, tt.totalHits, got, tt.last)
+		}
+
+		if got := s.RangeSummary(); got != tt.summary {
+			t.Errorf("page %d of %d hits: RangeSummary() = %q, want %q", tt.page, tt.totalHits, got, tt.summary)
+		}
+	}
+}
+
+func TestSearchRangePastResults(t *testing.T) {
+	s := pageOfSearch(1234, 3, 20)
+	s.PastResults = true
+
+	if got := s.RangeSummary(); got != "" {
+		t.Errorf("RangeSummary() = %q past the results, want none", got)
+	}
+}
+
+func TestWikipediaAccessToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         Secret
+		authorization string
+		host          string
+		origin        string
+	}{
+		{"anonymous", "", "", "en.wikipedia.org", "*"},
+		{"authenticated", "s3cret", "Bearer s3cret", "api.wikimedia.org", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) {
+				c.WikipediaAccessToken = tt.token
+				c.WikipediaAuthAPIURL = "https://api.wikimedia.org/core/v1/{project}/{lang}/api.php"
+			})
+
+			var sent *http.Request
+			stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+				sent = r
+				return jsonResponse(r, searchResponseJSON), nil
+			})
+
+			_, err := searchWikipedia(context.Background(), searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := sent.Header.Get("Authorization"); got != tt.authorization {
+				t.Errorf("Authorization = %q, want %q", got, tt.authorization)
+			}
+
+			if sent.URL.Host != tt.host {
+				t.Errorf("request sent to %s, want %s", sent.URL.Host, tt.host)
+			}
+
+			if got := sent.URL.Query().Get("origin"); got != tt.origin {
+				t.Errorf("origin = %q, want %q", got, tt.origin)
+			}
+		})
+	}
+}
+
+func TestAccessTokenIsNotLogged(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.WikipediaAccessToken = "s3cret"
+	})
+
+	var buf bytes.Buffer
+	l := zerolog.New(&buf)
+	l.Info().Object("config", config).Send()
+
+	if strings.Contains(buf.String(), "s3cret") {
+		t.Errorf("the access token is logged: %s", buf.String())
+	}
+}
+
+// categoryResponseJSON is a response of the Wikipedia API listing the members of Category:Physics
+const categoryResponseJSON = `{
+	"continue": {"cmcontinue": "page|ABC|123", "continue": "-||"},
+	"query": {
+		"pages": {"123": {"pageid": 123, "ns": 14, "title": "Category:Physics"}},
+		"categorymembers": [{"pageid": 1, "ns": 0, "title": "Physics"}, {"pageid": 2, "ns": 0, "title": "Gravity"}]
+	}
+}`
+
+func TestTemplateErrorIsServerError(t *testing.T) {
+	stubSearch(t, categoryResponseJSON)
+
+	saved := tpl
+	t.Cleanup(func() { tpl = saved })
+
+	// the template fails after writing part of the page
+	tpl = template.Must(template.New("category.html").Parse(`<h1>{{ .Name }}</h1>{{ .NoSuchField }}`))
+
+	w := serve(handlerWithError(categoryHandler), "/category?name=Physics", nil)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status %d, want 500", w.Code)
+	}
+
+	if strings.Contains(w.Body.String(), "<h1>") {
+		t.Errorf("the partly rendered page is served: %s", w.Body)
+	}
+}
+
+// failingWriter is a response writer whose client has gone away
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write: broken pipe")
+}
+
+func TestWriteErrorIsLoggedAsWarning(t *testing.T) {
+	var logs bytes.Buffer
+	l := zerolog.New(&logs)
+
+	r := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+	r = r.WithContext(l.WithContext(r.Context()))
+
+	w := failingWriter{httptest.NewRecorder()}
+
+	buf := getBuffer()
+	buf.WriteString("<html></html>")
+
+	writeResponse(w, r, buf)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d, want the 200 already sent", w.Code)
+	}
+
+	if !strings.Contains(logs.String(), `"level":"warn"`) || strings.Contains(logs.String(), `"level":"error"`) {
+		t.Errorf("the write error isn't logged as a warning: %s", logs.String())
+	}
+}
+
+func TestCountPages(t *testing.T) {
+	tests := []struct {
+		totalHits, pageSize, maxOffset int
+		pages                          int
+		capped                         bool
+	}{
+		{0, 20, 10000, 0, false},
+		{1, 20, 10000, 1, false},
+		{20, 20, 10000, 1, false},
+		{21, 20, 10000, 2, false},
+		{9999, 20, 10000, 500, false},
+		{10000, 20, 10000, 500, false},
+		{10001, 20, 10000, 500, true},
+		{2000000, 20, 10000, 500, true},
+		{10000, 30, 10000, 333, true},
+		{100, 20, 10, 1, true},
+	}
+
+	for _, tt := range tests {
+		pages, capped := countPages(tt.totalHits, tt.pageSize, tt.maxOffset)
+		if pages != tt.pages || capped != tt.capped {
+			t.Errorf("countPages(%d, %d, %d) = %d, %t, want %d, %t",
+				tt.totalHits, tt.pageSize, tt.maxOffset, pages, capped, tt.pages, tt.capped)
+		}
+	}
+}
+
+func TestIsLastPage(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.MaxResultOffset = 10000
+	})
+
+	tests := []struct {
+		totalHits, page int
+		last            bool
+	}{
+		{0, 1, true},
+		{15, 1, true},
+		{40, 1, false},
+		{40, 2, true},
+		{2000000, 499, false},
+		{2000000, 500, true},
+	}
+
+	for _, tt := range tests {
+		if got := pageOfSearch(tt.totalHits, tt.page, 20).IsLastPage(); got != tt.last {
+			t.Errorf("page %d of %d hits: IsLastPage() = %t, want %t", tt.page, tt.totalHits, got, tt.last)
+		}
+	}
+}
+
+func TestRequestLoggerCorrelationID(t *testing.T) {
+	saved := newCorrelationID
+	t.Cleanup(func() { newCorrelationID = saved })
+
+	newCorrelationID = func() string { return "test-correlation-id" }
+
+	var logs bytes.Buffer
+	var ctxID any
+
+	h := requestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctxID = r.Context().Value("correlation_id")
+
+		// the logger of the request, writing to logs
+		l := zerolog.Ctx(r.Context()).Output(&logs)
+		l.Info().Msg("handling the request")
+	}))
+
+	w := serve(h, "/search?q=go", nil)
+
+	if got := w.Header().Get("X-Correlation-ID"); got != "test-correlation-id" {
+		t.Errorf("X-Correlation-ID = %q, want test-correlation-id", got)
+	}
+
+	if ctxID != "test-correlation-id" {
+		t.Errorf("correlation ID in the request context = %v, want test-correlation-id", ctxID)
+	}
+
+	if !strings.Contains(logs.String(), `"correlation_id":"test-correlation-id"`) {
+		t.Errorf("the logs of the request don't carry the correlation ID: %s", logs.String())
+	}
+}
+
+func TestLoadTemplatesMissingFile(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html></html>"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = loadTemplates(dir)
+	if err == nil {
+		t.Fatal("templates loaded from a directory missing most of them")
+	}
+
+	missing := filepath.Join(dir, templateFiles[1])
+	if !strings.Contains(err.Error(), missing) || !strings.Contains(err.Error(), "TEMPLATE_DIR") {
+		t.Errorf("error %q doesn't name the missing file %s and TEMPLATE_DIR", err, missing)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("error %q doesn't wrap os.ErrNotExist", err)
+	}
+}
+
+func TestLoadTemplatesMissingDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "templates")
+
+	_, err := loadTemplates(dir)
+	if err == nil {
+		t.Fatal("templates loaded from a directory that doesn't exist")
+	}
+
+	if !strings.Contains(err.Error(), dir) || !strings.Contains(err.Error(), "TEMPLATE_DIR") {
+		t.Errorf("error %q doesn't name the directory %s and TEMPLATE_DIR", err, dir)
+	}
+}
+
+// redirectResponseJSON is a search response where the first result matched through a redirect
+const redirectResponseJSON = `{
+	"batchcomplete": "",
+	"query": {
+		"searchinfo": {"totalhits": 42},
+		"search": [
+			{"ns": 0, "title": "Go (programming language)", "pageid": 25039021, "size": 300, "wordcount": 30,
+				"snippet": "Go is a programming language", "timestamp": "2023-01-01T00:00:00Z", "redirecttitle": "Golang"},
+			{"ns": 0, "title": "Gopher", "pageid": 99, "size": 200, "wordcount": 20,
+				"snippet": "The mascot of golang", "timestamp": "2023-01-03T00:00:00Z"}
+		]
+	}
+}`
+
+func TestSearchResolveRedirects(t *testing.T) {
+	var sent *http.Request
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		sent = r
+		return jsonResponse(r, redirectResponseJSON), nil
+	})
+
+	w := serve(handlerWithError(searchHandler), "/search?q=golang&resolve_redirects=1", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	params := sent.URL.Query()
+	if params.Get("redirects") != "1" || !strings.Contains(params.Get("srprop"), "redirecttitle") {
+		t.Errorf("redirects aren't requested: %v", params)
+	}
+
+	body := w.Body.String()
+	if strings.Count(body, "(redirected from ") != 1 || !strings.Contains(body, "(redirected from Golang)") {
+		t.Errorf("the page doesn't note the redirect of the first result only")
+	}
+
+	if !strings.Contains(body, `name="resolve_redirects"`) || !strings.Contains(body, "resolve_redirects=1") {
+		t.Errorf("the option isn't kept in the form and the page links")
+	}
+}
+
+func TestSearchWithoutRedirects(t *testing.T) {
+	var sent *http.Request
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		sent = r
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	w := serve(handlerWithError(searchHandler), "/search?q=golang", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if params := sent.URL.Query(); params.Has("redirects") || params.Has("srprop") {
+		t.Errorf("redirects requested although the option is off: %v", params)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	mux := newMux()
+
+	tests := []struct {
+		target   string
+		status   int
+		contains string
+	}{
+		{"/", http.StatusOK, `name="q"`},
+		{"/search?q=go", http.StatusOK, "Go (programming language)"},
+		{"/version", http.StatusOK, "version"},
+		{"/no/such/page", http.StatusNotFound, "/no/such/page"},
+		{"/searchx", http.StatusNotFound, "/searchx"},
+	}
+
+	for _, tt := range tests {
+		var logs bytes.Buffer
+		l := zerolog.New(&logs)
+
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		r = r.WithContext(l.WithContext(r.Context()))
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("GET %s: status %d, want %d", tt.target, w.Code, tt.status)
+		}
+
+		if !strings.Contains(w.Body.String(), tt.contains) {
+			t.Errorf("GET %s: body doesn't contain %q", tt.target, tt.contains)
+		}
+
+		unrouted := strings.Contains(logs.String(), "no route for the requested path")
+		if unrouted != (tt.status == http.StatusNotFound) {
+			t.Errorf("GET %s: logged as unrouted: %t", tt.target, unrouted)
+		}
+
+		if unrouted && !strings.Contains(logs.String(), `"level":"info"`) {
+			t.Errorf("GET %s: the unknown path isn't logged at info level: %s", tt.target, logs.String())
+		}
+	}
+}
+
+// fullPageResponseJSON returns a search response with a full page of 20 results
+func fullPageResponseJSON() string {
+	results := make([]string, 20)
+	for i := range results {
+		results[i] = fmt.Sprintf(`{"ns": 0, "title": "Go %[1]d", "pageid": %[1]d, "size": 3000, "wordcount": 300,
+			"snippet": "<span class=\"searchmatch\">Go</span> is a statically typed, compiled high-level programming language designed at Google", "timestamp": "2023-01-01T00:00:00Z"}`, i+1)
+	}
+
+	return `{"batchcomplete": "", "continue": {"sroffset": 20, "continue": "-||"},
+		"query": {"searchinfo": {"totalhits": 1234}, "search": [` + strings.Join(results, ",") + `]}}`
+}
+
+func BenchmarkSearchHandler(b *testing.B) {
+	// every search reaches the stubbed Wikipedia API
+	withConfig(b, func(c *Config) {
+		c.Features.Cache = false
+	})
+	stubSearch(b, fullPageResponseJSON())
+
+	h := handlerWithError(searchHandler)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		w := serve(h, "/search?q=go", nil)
+		if w.Code != http.StatusOK {
+			b.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+		}
+	}
+}
+
+func TestStateJSON(t *testing.T) {
+	tests := []struct {
+		in   any
+		want template.JS
+	}{
+		{"</script><script>alert(1)</script>", `"\u003c/script\u003e\u003cscript\u003ealert(1)\u003c/script\u003e"`},
+		{"<!-- a & b -->", `"\u003c!-- a \u0026 b --\u003e"`},
+		{map[string]int{"page": 2}, `{"page":2}`},
+	}
+
+	for _, tt := range tests {
+		got, err := stateJSON(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != tt.want {
+			t.Errorf("stateJSON(%v) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := stateJSON(func() {}); err == nil {
+		t.Error("stateJSON() of a function succeeded")
+	}
+}
+
+func TestSearchStateIsEscaped(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	query := "</script><script>alert(1)</script>"
+	w := serve(handlerWithError(searchHandler), "/search?q="+url.QueryEscape(query), nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	body := w.Body.String()
+	if strings.Contains(body, "<script>alert(1)") {
+		t.Fatal("the query breaks out of the script element")
+	}
+
+	start := strings.Index(body, `<script type="application/json" id="search-state">`)
+	if start < 0 {
+		t.Fatal("no search state in the page")
+	}
+
+	state := body[start+len(`<script type="application/json" id="search-state">`):]
+	state = state[:strings.Index(state, "</script>")]
+
+	var decoded struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal([]byte(state), &decoded); err != nil {
+		t.Fatalf("the search state isn't valid JSON: %v", err)
+	}
+
+	if decoded.Query != query {
+		t.Errorf("query in the search state = %q, want %q", decoded.Query, query)
+	}
+}
+
+func TestResultsFragment(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	w := serve(handlerWithError(resultsFragmentHandler), "/search/results?q=go", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", got)
+	}
+
+	fragment := w.Body.String()
+
+	for _, tag := range []string{"<html", "<head", "<body", `id="search-state"`} {
+		if strings.Contains(fragment, tag) {
+			t.Errorf("the fragment contains %s from the full page", tag)
+		}
+	}
+
+	for _, title := range []string{"Go (programming language)", "Go (game)", "gopher"} {
+		if !strings.Contains(fragment, title) {
+			t.Errorf("the fragment misses the result %q", title)
+		}
+	}
+
+	// the full page embeds the same results
+	page := serve(handlerWithError(searchHandler), "/search?q=go", nil).Body.String()
+	if !strings.Contains(page, strings.TrimSpace(fragment)) {
+		t.Error("the full page doesn't embed the fragment")
+	}
+}
+
+func TestPreviousAndNextPages(t *testing.T) {
+	tests := []struct {
+		name                   string
+		totalHits, page        int
+		previous, next         bool
+		previousPage, nextPage int
+	}{
+		{"first page", 100, 1, false, true, 0, 2},
+		{"middle page", 100, 3, true, true, 2, 4},
+		{"last page", 100, 5, true, false, 4, 6},
+		{"single page", 15, 1, false, false, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := pageOfSearch(tt.totalHits, tt.page, 20)
+
+			if got := s.HasPreviousPage(); got != tt.previous {
+				t.Errorf("HasPreviousPage() = %t, want %t", got, tt.previous)
+			}
+
+			if got := s.HasNextPage(); got != tt.next {
+				t.Errorf("HasNextPage() = %t, want %t", got, tt.next)
+			}
+
+			if s.CurrentPage() != tt.page || s.PreviousPage() != tt.previousPage || s.NextPage != tt.nextPage {
+				t.Errorf("pages %d, %d, %d around the current one, want %d, %d, %d",
+					s.PreviousPage(), s.CurrentPage(), s.NextPage, tt.previousPage, tt.page, tt.nextPage)
+			}
+		})
+	}
+}
+
+func TestPaginationLinks(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	tests := []struct {
+		target         string
+		previous, next bool
+	}{
+		{"/search?q=go", false, true},
+		{"/search?q=go&page=2", true, true},
+		{"/search?q=go&page=500", true, false},
+	}
+
+	for _, tt := range tests {
+		body := serve(handlerWithError(searchHandler), tt.target, nil).Body.String()
+
+		if got := strings.Contains(body, "previous-page"); got != tt.previous {
+			t.Errorf("GET %s: previous link shown: %t, want %t", tt.target, got, tt.previous)
+		}
+
+		if got := strings.Contains(body, "next-page"); got != tt.next {
+			t.Errorf("GET %s: next link shown: %t, want %t", tt.target, got, tt.next)
+		}
+	}
+}
+
+// pastResultsResponseJSON is the response of the Wikipedia API past the results it serves,
+// which has no continuation and no results although totalhits promises more
+const pastResultsResponseJSON = `{
+	"batchcomplete": "",
+	"query": {"searchinfo": {"totalhits": 1234}, "search": []}
+}`
+
+func TestSearchPastResults(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		target      string
+		pastResults bool
+	}{
+		{"past the results", pastResultsResponseJSON, "/search?q=go&page=3&format=json", true},
+		{"within the results", searchResponseJSON, "/search?q=go&page=3&format=json", false},
+		{"no results at all", `{"query": {"searchinfo": {"totalhits": 0}, "search": []}}`, "/search?q=xyzzy&format=json", false},
+		{"past the results on the last page", pastResultsResponseJSON, "/search?q=go&page=62&format=json", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubSearch(t, tt.body)
+
+			w := serve(handlerWithError(searchHandler), tt.target, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+			}
+
+			var s Search
+			if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+				t.Fatal(err)
+			}
+
+			if s.PastResults != tt.pastResults {
+				t.Errorf("PastResults = %t, want %t", s.PastResults, tt.pastResults)
+			}
+
+			if tt.pastResults && (!s.IsLastPage() || s.RangeSummary() != "") {
+				t.Errorf("a page past the results isn't the last page without a range")
+			}
+		})
+	}
+}
+
+func TestSearchPastResultsPage(t *testing.T) {
+	stubSearch(t, pastResultsResponseJSON)
+
+	body := serve(handlerWithError(searchHandler), "/search?q=go&page=3", nil).Body.String()
+
+	if !strings.Contains(body, "Wikipedia doesn't serve more results for this search") {
+		t.Error("the page past the results doesn't explain why it's empty")
+	}
+
+	if !strings.Contains(body, "page=2") || strings.Contains(body, "next-page") {
+		t.Error("the page past the results doesn't link back to the previous page only")
+	}
+}
+
+func TestEmptyQuery(t *testing.T) {
+	calls := stubSearch(t, searchResponseJSON)
+
+	tests := []struct {
+		handler handlerWithError
+		target  string
+		want    int
+	}{
+		{searchHandler, "/search", http.StatusOK},
+		{searchHandler, "/search?q=", http.StatusOK},
+		{searchHandler, "/search?q=+++%09", http.StatusOK},
+		{searchHandler, "/search?q=&format=json", http.StatusBadRequest},
+		{searchHandler, "/search?q=+&format=text", http.StatusBadRequest},
+		{apiSearchHandler, "/api/search", http.StatusBadRequest},
+		{apiSearchHandler, "/api/search?q=%20", http.StatusBadRequest},
+		{resultsFragmentHandler, "/search/results?q=", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		var logs bytes.Buffer
+		l := zerolog.New(&logs)
+
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		r = r.WithContext(l.WithContext(r.Context()))
+
+		w := httptest.NewRecorder()
+		tt.handler.ServeHTTP(w, r)
+
+		if w.Code != tt.want {
+			t.Errorf("GET %s: status %d, want %d", tt.target, w.Code, tt.want)
+		}
+
+		if tt.want == http.StatusOK && !strings.Contains(w.Body.String(), `name="q"`) {
+			t.Errorf("GET %s: not the landing page", tt.target)
+		}
+
+		if !strings.Contains(logs.String(), "empty search query") || strings.Contains(logs.String(), "searching Wikipedia") {
+			t.Errorf("GET %s: the empty query is logged as a search: %s", tt.target, logs.String())
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/search", strings.NewReader(`{"q":"  "}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	handlerWithError(apiSearchHandler).ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST /api/search: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	if calls.Load() != 0 {
+		t.Errorf("%d calls to Wikipedia for empty queries, want none", calls.Load())
+	}
+}
+
+func TestSearchWikipediaEmptyQuery(t *testing.T) {
+	calls := stubSearch(t, searchResponseJSON)
+
+	search := map[string]func(context.Context, searchOptions) (*WikipediaSearchResponse, error){
+		"search":          searchWikipedia,
+		"combined search": searchWikipediaCombined,
+	}
+
+	for name, fn := range search {
+		for _, q := range []string{"", " ", "\t\n "} {
+			resp, err := fn(context.Background(), searchOptions{Query: q, Project: defaultProject, Lang: "en", Limit: 20})
+			if !errors.Is(err, errEmptyQuery) || resp != nil {
+				t.Errorf("%s of %q: %v, %v, want errEmptyQuery", name, q, resp, err)
+			}
+		}
+	}
+
+	if n := calls.Load(); n != 0 {
+		t.Errorf("%d calls to Wikipedia for an empty query, want 0", n)
+	}
+
+	// a handler that lets it through answers with a 400
+	h := handlerWithError(func(w http.ResponseWriter, r *http.Request) error {
+		_, err := searchWikipedia(r.Context(), searchOptions{Lang: "en"})
+		return err
+	})
+
+	if w := serve(h, "/search", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want 400", w.Code)
+	}
+}
+
+func TestOversizedResponse(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.WikipediaMaxResponseSize = len(searchResponseJSON)
+	})
+
+	oversized := strings.Replace(searchResponseJSON, "A burrowing rodent", strings.Repeat("A burrowing rodent ", 100), 1)
+
+	tests := []struct {
+		name     string
+		response func(r *http.Request) *http.Response
+		wantErr  bool
+	}{
+		{"at the limit", func(r *http.Request) *http.Response {
+			return jsonResponse(r, searchResponseJSON)
+		}, false},
+		{"over the limit", func(r *http.Request) *http.Response {
+			return jsonResponse(r, oversized)
+		}, true},
+		{"over the limit once decompressed", func(r *http.Request) *http.Response {
+			return gzipResponse(r, oversized)
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int64
+			stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+				calls.Add(1)
+				return tt.response(r), nil
+			})
+
+			_, err := searchWikipedia(context.Background(), searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20})
+			if tt.wantErr != errors.Is(err, errResponseTooLarge) {
+				t.Errorf("err = %v, want errResponseTooLarge: %t", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			// the same response would come back, so it's not retried
+			if calls.Load() != 1 {
+				t.Errorf("%d calls to Wikipedia, want 1", calls.Load())
+			}
+		})
+	}
+
+	stubSearch(t, oversized)
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go&format=json", nil)
+	if w.Code == http.StatusOK {
+		t.Error("an oversized response is served")
+	}
+}
+
+// gzipResponse returns a 200 OK response to r with the gzip-encoded JSON body
+func gzipResponse(r *http.Request, body string) *http.Response {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(body))
+	gz.Close()
+
+	resp := jsonResponse(r, "")
+	resp.Header.Set("Content-Encoding", "gzip")
+	resp.Body = io.NopCloser(&buf)
+
+	return resp
+}
+
+func TestGzipResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response func(r *http.Request) *http.Response
+		wantErr  bool
+	}{
+		{"gzip", func(r *http.Request) *http.Response {
+			return gzipResponse(r, searchResponseJSON)
+		}, false},
+		{"decompressed by the transport", func(r *http.Request) *http.Response {
+			resp := jsonResponse(r, searchResponseJSON)
+			resp.Header.Set("Content-Encoding", "gzip")
+			resp.Uncompressed = true
+
+			return resp
+		}, false},
+		{"invalid gzip", func(r *http.Request) *http.Response {
+			resp := jsonResponse(r, searchResponseJSON)
+			resp.Header.Set("Content-Encoding", "gzip")
+
+			return resp
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+				return tt.response(r), nil
+			})
+
+			resp, err := searchWikipedia(context.Background(), searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20})
+			if tt.wantErr {
+				if err == nil {
+					t.Error("no error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Query.SearchInfo.TotalHits != 1234 || len(resp.Query.Search) != 3 {
+				t.Errorf("got %d of %d results, want 3 of 1234", len(resp.Query.Search), resp.Query.SearchInfo.TotalHits)
+			}
+		})
+	}
+}
+
+func TestHeadSearch(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.JSONP = true
+	})
+
+	calls := stubSearch(t, searchResponseJSON)
+
+	tests := []struct {
+		target      string
+		status      int
+		contentType string
+	}{
+		{"/search?q=go", http.StatusOK, "text/html; charset=utf-8"},
+		{"/search", http.StatusOK, "text/html; charset=utf-8"},
+		{"/search?q=go&format=json", http.StatusOK, "application/json; charset=utf-8"},
+		{"/search?q=go&format=text", http.StatusOK, "text/plain; charset=utf-8"},
+		{"/api/search?q=go&callback=cb", http.StatusOK, "application/javascript; charset=utf-8"},
+		{"/search?q=&format=json", http.StatusBadRequest, "text/plain; charset=utf-8"},
+		{"/search?q=go&lang=xx", http.StatusBadRequest, "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodHead, tt.target, nil)
+		r = r.WithContext(zerolog.New(io.Discard).WithContext(r.Context()))
+
+		w := httptest.NewRecorder()
+		lrw := newLoggingResponseWriter(w)
+		handlerWithError(searchHandler).ServeHTTP(lrw, r)
+
+		if w.Code != tt.status {
+			t.Errorf("HEAD %s: status %d, want %d", tt.target, w.Code, tt.status)
+		}
+
+		if ct := w.Header().Get("Content-Type"); ct != tt.contentType {
+			t.Errorf("HEAD %s: Content-Type %q, want %q", tt.target, ct, tt.contentType)
+		}
+
+		if w.Body.Len() != 0 || lrw.size != 0 {
+			t.Errorf("HEAD %s: %d bytes of body, %d logged, want none", tt.target, w.Body.Len(), lrw.size)
+		}
+	}
+
+	if calls.Load() != 0 {
+		t.Errorf("%d calls to Wikipedia for HEAD requests, want none", calls.Load())
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// maintenanceRetryAfter is the Retry-After of the responses served in maintenance mode
+const maintenanceRetryAfter = 5 * time.Minute
+
+// maintenanceMode is on while the app is down for maintenance, see maintenance.
+// It starts as config.MaintenanceMode and is switched with /admin/maintenance.
+var maintenanceMode atomic.Bool
+
+// maintenanceExemptPaths are served in maintenance mode: the health check, the admin endpoints
+// that turn it off and the assets of the maintenance page
+var maintenanceExemptPaths = []string{"/healthz", "/admin/", "/assets/"}
+
+// maintenance middleware returns a handler that answers every request with 503 Service Unavailable
+// and the maintenance page while maintenanceMode is on, except for maintenanceExemptPaths
+func maintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !maintenanceMode.Load() || isMaintenanceExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(maintenanceRetryAfter.Seconds())))
+		w.Header().Set("Cache-Control", "no-store")
+
+		if negotiateFormat(r) != formatHTML {
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+			return
+		}
+
+		buf := getBuffer()
+
+		err := tpl.ExecuteTemplate(buf, "maintenance.html", nil)
+		if err != nil {
+			zerolog.Ctx(r.Context()).Error().Err(err).Msg("unable to render the maintenance page")
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		writeResponse(w, r, buf)
+	})
+}
+
+func isMaintenanceExempt(path string) bool {
+	for _, exempt := range maintenanceExemptPaths {
+		if path == exempt || strings.HasSuffix(exempt, "/") && strings.HasPrefix(path, exempt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type maintenanceResponse struct {
+	MaintenanceMode bool `json:"maintenance_mode"`
+}
+
+// maintenanceHandler reports whether the maintenance mode is on,
+// and switches it with a POST of enabled=true or enabled=false
+func maintenanceHandler(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			return &statusError{http.StatusBadRequest, errors.New("enabled must be true or false")}
+		}
+
+		if maintenanceMode.Swap(enabled) != enabled {
+			zerolog.Ctx(r.Context()).Warn().
+				Str("client_ip", maskIP(clientIP(r))).
+				Bool("maintenance_mode", enabled).
+				Msg("maintenance mode switched")
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		return &statusError{http.StatusMethodNotAllowed, errors.New("method not allowed")}
+	}
+
+	buf := getBuffer()
+
+	err := json.NewEncoder(buf).Encode(maintenanceResponse{MaintenanceMode: maintenanceMode.Load()})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
+
+// healthzHandler tells load balancers and monitors that the server is up,
+// it's served in maintenance mode too
+func healthzHandler(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	_, err := io.WriteString(w, "ok\n")
+
+	return err
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// withMaintenance switches the maintenance mode for the duration of the test
+func withMaintenance(t *testing.T, enabled bool) {
+	t.Helper()
+
+	previous := maintenanceMode.Swap(enabled)
+	t.Cleanup(func() { maintenanceMode.Store(previous) })
+}
+
+func TestMaintenancePage(t *testing.T) {
+	withMaintenance(t, true)
+	calls := stubSearch(t, searchResponseJSON)
+
+	h := maintenance(newMux())
+
+	tests := []struct {
+		target      string
+		header      http.Header
+		contentType string
+		body        string
+	}{
+		{"/", nil, "text/html", "down for maintenance"},
+		{"/search?q=go", nil, "text/html", "down for maintenance"},
+		{"/search?q=go&format=json", nil, "text/plain", "down for maintenance"},
+		{"/api/search?q=go", nil, "text/plain", "down for maintenance"},
+		{"/search?q=go", http.Header{"Accept": {"application/json"}}, "text/plain", "down for maintenance"},
+	}
+
+	for _, tt := range tests {
+		w := serve(h, tt.target, tt.header)
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("GET %s: status %d, want 503", tt.target, w.Code)
+		}
+
+		if got := w.Header().Get("Retry-After"); got != "300" {
+			t.Errorf("GET %s: Retry-After %q, want 300", tt.target, got)
+		}
+
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+			t.Errorf("GET %s: Content-Type %q, want %s", tt.target, got, tt.contentType)
+		}
+
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("GET %s: not the maintenance page: %s", tt.target, w.Body)
+		}
+	}
+
+	if n := calls.Load(); n != 0 {
+		t.Errorf("%d calls to Wikipedia in maintenance mode, want 0", n)
+	}
+}
+
+func TestMaintenanceExemptPaths(t *testing.T) {
+	withMaintenance(t, true)
+	withConfig(t, func(c *Config) { c.AdminToken = "secret" })
+
+	h := maintenance(newMux())
+
+	w := serve(h, "/healthz", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "ok\n" {
+		t.Errorf("GET /healthz: status %d, body %q, want 200 ok", w.Code, w.Body)
+	}
+
+	w = serve(h, "/assets/style.css", nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /assets/style.css: status %d, want 200", w.Code)
+	}
+
+	w = serve(h, "/admin/maintenance", http.Header{adminTokenHeader: {"secret"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"maintenance_mode":true`) {
+		t.Errorf("GET /admin/maintenance: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestMaintenanceOff(t *testing.T) {
+	withMaintenance(t, false)
+	stubSearch(t, searchResponseJSON)
+
+	w := serve(maintenance(newMux()), "/search?q=go", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Retry-After") != "" {
+		t.Errorf("status %d, Retry-After %q, want the search page", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestMaintenanceToggle(t *testing.T) {
+	withMaintenance(t, false)
+	withConfig(t, func(c *Config) { c.AdminToken = "secret" })
+	stubSearch(t, searchResponseJSON)
+
+	h := maintenance(newMux())
+
+	toggle := func(enabled string) *httptest.ResponseRecorder {
+		form := url.Values{"enabled": {enabled}}
+		r := httptest.NewRequest(http.MethodPost, "/admin/maintenance", strings.NewReader(form.Encode()))
+		r = r.WithContext(zerolog.New(io.Discard).WithContext(r.Context()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set(adminTokenHeader, "secret")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		return w
+	}
+
+	if w := toggle("true"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"maintenance_mode":true`) {
+		t.Fatalf("turning on: status %d: %s", w.Code, w.Body)
+	}
+
+	if w := serve(h, "/search?q=go", nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d once turned on, want 503", w.Code)
+	}
+
+	if w := toggle("maybe"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid toggle: status %d, want 400", w.Code)
+	}
+
+	if w := toggle("false"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"maintenance_mode":false`) {
+		t.Fatalf("turning off: status %d: %s", w.Code, w.Body)
+	}
+
+	if w := serve(h, "/search?q=go", nil); w.Code != http.StatusOK {
+		t.Errorf("status %d once turned off, want 200", w.Code)
+	}
+}
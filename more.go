@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog"
+)
+
+// moreResults is the JSON response of /search/more
+type moreResults struct {
+	Results    []SearchResult `json:"results"`
+	Offset     int            `json:"offset"`
+	NextOffset int            `json:"next_offset"`
+	HasMore    bool           `json:"has_more"`
+	TotalHits  int            `json:"total_hits"`
+}
+
+// moreHandler serves /search/more, which takes the parameters of /search along with the offset
+// of the first result, and returns the next config.PageSize results for infinite scrolling.
+// They're rendered with the "results" template, or as JSON with format=json, and the
+// X-Has-More and X-Next-Offset headers tell the client whether and where to continue.
+// The offset is bounded to config.MaxResultOffset, past which Wikipedia serves no results.
+func moreHandler(w http.ResponseWriter, r *http.Request) error {
+	values := r.URL.Query()
+
+	p, err := parseSearchParams(values, r.Header.Get("Accept-Language"), config)
+	if err != nil {
+		return err
+	}
+
+	if p.Query == "" || p.PageID > 0 {
+		return &statusError{http.StatusBadRequest, errors.New("more results require a search query")}
+	}
+
+	offset := 0
+	if v := values.Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return &statusError{
+				http.StatusBadRequest,
+				fmt.Errorf("invalid offset '%s'", v),
+			}
+		}
+	}
+
+	if offset > config.MaxResultOffset {
+		offset = config.MaxResultOffset
+	}
+
+	l := zerolog.Ctx(r.Context())
+	l.UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Str("search_query", p.Query).Int("offset", offset)
+	})
+
+	opts := p.options(config.PageSize)
+	opts.Offset = offset
+	if opts.Limit > config.MaxResultOffset-offset {
+		opts.Limit = config.MaxResultOffset - offset
+	}
+
+	searchResponse := &WikipediaSearchResponse{}
+
+	// at the bound there's nothing left to ask Wikipedia for
+	if opts.Limit > 0 {
+		var cacheStatus string
+
+		searchResponse, cacheStatus, err = cachedSearch(r.Context(), opts)
+		w.Header().Set("X-Cache", cacheStatus)
+		if errors.Is(err, errCircuitOpen) {
+			return &statusError{http.StatusServiceUnavailable, err}
+		}
+		if err != nil {
+			return err
+		}
+
+		if p.Geo {
+			searchResponse = searchResponse.clone()
+
+			err = addCoordinates(r.Context(), p.Project, p.Lang, searchResponse)
+			if err != nil {
+				l.Warn().Err(err).Msg("unable to fetch the coordinates of the results")
+			}
+		}
+	}
+
+	results := searchResponse.Query.Search
+	totalHits := searchResponse.Query.SearchInfo.TotalHits
+
+	end := totalHits
+	if end > config.MaxResultOffset {
+		end = config.MaxResultOffset
+	}
+
+	if results == nil {
+		results = []SearchResult{}
+	}
+
+	nextOffset := offset + len(results)
+	// Wikipedia leaves out the continuation on the last page
+	hasMore := len(results) > 0 && searchResponse.Continue.Sroffset > 0 && nextOffset < end
+
+	w.Header().Set("X-Has-More", strconv.FormatBool(hasMore))
+	w.Header().Set("X-Next-Offset", strconv.Itoa(nextOffset))
+
+	buf := getBuffer()
+
+	if negotiateFormat(r) == formatJSON {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		err = json.NewEncoder(buf).Encode(moreResults{
+			Results:    results,
+			Offset:     offset,
+			NextOffset: nextOffset,
+			HasMore:    hasMore,
+			TotalHits:  totalHits,
+		})
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		page := offset/config.PageSize + 1
+
+		err = tpl.ExecuteTemplate(buf, "results", &Search{
+			Query:         p.Query,
+			Lang:          p.Lang,
+			Project:       p.Project,
+			Results:       searchResponse,
+			NextPage:      page + 1,
+			PageSize:      config.PageSize,
+			Geo:           p.Geo,
+			Profile:       p.Profile,
+			Exclude:       p.Exclude,
+			Refine:        p.Refine,
+			SnippetLength: config.SnippetLength,
+
+			ResolveRedirects: p.ResolveRedirects,
+			Interwiki:        p.Interwiki,
+			Safe:             p.Safe,
+			Extracts:         p.Extracts,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
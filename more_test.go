@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMoreResults(t *testing.T) {
+	offsets := pagedSearch(t, 50, nil)
+
+	tests := []struct {
+		name       string
+		offset     string
+		first      int
+		count      int
+		nextOffset int
+		hasMore    bool
+	}{
+		{"first chunk", "", 1, 20, 20, true},
+		{"middle chunk", "20", 21, 20, 40, true},
+		{"end", "40", 41, 10, 50, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(handlerWithError(moreHandler), "/search/more?q=go&format=json&offset="+tt.offset, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+			}
+
+			var more moreResults
+			if err := json.NewDecoder(w.Body).Decode(&more); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(more.Results) != tt.count || more.Results[0].PageID != tt.first {
+				t.Errorf("got %d results from %d, want %d from %d", len(more.Results), more.Results[0].PageID, tt.count, tt.first)
+			}
+
+			if more.NextOffset != tt.nextOffset || more.HasMore != tt.hasMore {
+				t.Errorf("next offset %d, has more %t, want %d, %t", more.NextOffset, more.HasMore, tt.nextOffset, tt.hasMore)
+			}
+
+			if got := w.Header().Get("X-Has-More"); got != strconv.FormatBool(tt.hasMore) {
+				t.Errorf("X-Has-More = %s, want %t", got, tt.hasMore)
+			}
+		})
+	}
+
+	if len(*offsets) != len(tests) {
+		t.Errorf("searched Wikipedia at offsets %v, want one call per chunk", *offsets)
+	}
+}
+
+func TestMoreResultsFragment(t *testing.T) {
+	pagedSearch(t, 50, nil)
+
+	w := serve(handlerWithError(moreHandler), "/search/more?q=go&offset=20", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	body := w.Body.String()
+	if !strings.Contains(body, "Result 21") || strings.Contains(body, "Result 20<") || strings.Contains(body, "<html") {
+		t.Errorf("not the fragment of the results from offset 20: %s", body)
+	}
+
+	if w.Header().Get("X-Has-More") != "true" || w.Header().Get("X-Next-Offset") != "40" {
+		t.Errorf("X-Has-More = %s, X-Next-Offset = %s, want true, 40", w.Header().Get("X-Has-More"), w.Header().Get("X-Next-Offset"))
+	}
+}
+
+func TestMoreResultsBound(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.MaxResultOffset = 50
+	})
+
+	offsets := pagedSearch(t, 1000, nil)
+
+	for _, offset := range []string{"50", "60", "1000000"} {
+		w := serve(handlerWithError(moreHandler), "/search/more?q=go&format=json&offset="+offset, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("offset %s: status %d, want 200: %s", offset, w.Code, w.Body)
+		}
+
+		var more moreResults
+		if err := json.NewDecoder(w.Body).Decode(&more); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(more.Results) != 0 || more.HasMore || more.Offset != 50 {
+			t.Errorf("offset %s: %d results from %d, has more %t, want none past the bound", offset, len(more.Results), more.Offset, more.HasMore)
+		}
+	}
+
+	// the chunk before the bound is cut short
+	w := serve(handlerWithError(moreHandler), "/search/more?q=go&format=json&offset=40", nil)
+
+	var more moreResults
+	if err := json.NewDecoder(w.Body).Decode(&more); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(more.Results) != 10 || more.HasMore {
+		t.Errorf("offset 40: %d results, has more %t, want 10 and no more", len(more.Results), more.HasMore)
+	}
+
+	if len(*offsets) != 1 {
+		t.Errorf("searched Wikipedia at offsets %v, want only 40", *offsets)
+	}
+
+	for _, offset := range []string{"-1", "ten"} {
+		w := serve(handlerWithError(moreHandler), "/search/more?q=go&offset="+offset, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("offset %s: status %d, want 400", offset, w.Code)
+		}
+	}
+}
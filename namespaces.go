@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+type namespace struct {
+	ID   int
+	Name string
+}
+
+// breakdownNamespaces are the namespaces counted when searching across all namespaces,
+// see https://en.wikipedia.org/wiki/Wikipedia:Namespace
+var breakdownNamespaces = []namespace{
+	{0, "Article"},
+	{1, "Talk"},
+	{2, "User"},
+	{4, "Wikipedia"},
+	{6, "File"},
+	{10, "Template"},
+	{12, "Help"},
+	{14, "Category"},
+	{100, "Portal"},
+	{118, "Draft"},
+}
+
+// NamespaceHits is the number of matches of a search in one namespace
+type NamespaceHits struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Hits  int    `json:"hits"`
+	Error string `json:"error,omitempty"`
+}
+
+// countNamespaceHits counts the matches of searchQuery in each of the breakdownNamespaces concurrently.
+// A namespace that fails to be counted gets an Error instead of failing the whole breakdown,
+// the error of ctx is returned if some of the counts were cut short by it.
+func countNamespaceHits(ctx context.Context, project, lang, searchQuery string) ([]NamespaceHits, error) {
+	counts := make([]NamespaceHits, len(breakdownNamespaces))
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		unfinished error
+	)
+
+	for i, ns := range breakdownNamespaces {
+		wg.Add(1)
+
+		go func(i int, ns namespace) {
+			defer wg.Done()
+
+			counts[i] = NamespaceHits{ID: ns.ID, Name: ns.Name}
+
+			hits, err := countHits(ctx, project, lang, searchQuery, ns.ID)
+			if err != nil {
+				if cutShort(ctx, err) {
+					mu.Lock()
+					unfinished = ctx.Err()
+					mu.Unlock()
+				} else {
+					zerolog.Ctx(ctx).Warn().
+						Err(err).
+						Int("namespace", ns.ID).
+						Msg("unable to count namespace hits")
+				}
+
+				counts[i].Error = "unavailable"
+
+				return
+			}
+
+			counts[i].Hits = hits
+		}(i, ns)
+	}
+
+	wg.Wait()
+
+	return counts, unfinished
+}
+
+// countHits returns the total number of matches of searchQuery in a namespace
+// without fetching the results themselves
+func countHits(ctx context.Context, project, lang, searchQuery string, ns int) (int, error) {
+	params := url.Values{}
+	params.Set("action", "query")
+	params.Set("list", "search")
+	params.Set("srsearch", searchQuery)
+	params.Set("srnamespace", strconv.Itoa(ns))
+	params.Set("srlimit", "1")
+	params.Set("srinfo", "totalhits")
+	params.Set("srprop", "")
+
+	var resp WikipediaSearchResponse
+
+	err := wikipediaBreaker.Execute(func() error {
+		return callMediaWiki(ctx, project, lang, params, &resp)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.Query.SearchInfo.TotalHits, nil
+}
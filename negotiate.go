@@ -0,0 +1,80 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	formatHTML = "html"
+	formatJSON = "json"
+	formatText = "text" // only served by /search
+)
+
+// mediaTypeFormats maps the media types we know how to produce to a response format.
+// Wildcards resolve to HTML so that browsers and curl keep getting the page by default.
+var mediaTypeFormats = map[string]string{
+	"text/html":        formatHTML,
+	"text/*":           formatHTML,
+	"*/*":              formatHTML,
+	"application/json": formatJSON,
+	"application/*":    formatJSON,
+	"text/plain":       formatText,
+}
+
+// negotiateFormat returns the response format for the request.
+// The JSON API under /api/ always gets JSON. Otherwise, an explicit "format" query parameter
+// overrides the Accept header, which is parsed with its quality values. Defaults to HTML.
+func negotiateFormat(r *http.Request) string {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		return formatJSON
+	}
+
+	switch format := strings.ToLower(r.URL.Query().Get("format")); format {
+	case formatHTML, formatJSON, formatText:
+		return format
+	}
+
+	return parseAccept(r.Header.Get("Accept"))
+}
+
+// parseAccept picks the supported format with the highest quality value in an Accept header.
+// On equal quality, the more specific media type wins, then the one listed first.
+func parseAccept(accept string) string {
+	best := formatHTML
+	bestQ := -1.0
+	bestSpecificity := -1
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		format, ok := mediaTypeFormats[mediaType]
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		if q == 0 {
+			continue
+		}
+
+		specificity := 2 - strings.Count(mediaType, "*")
+		if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = format, q, specificity
+		}
+	}
+
+	return best
+}
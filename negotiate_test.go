@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		want   string
+	}{
+		{"no Accept header", "/search", "", formatHTML},
+		{"browser", "/search", "text/html,application/xhtml+xml,*/*;q=0.8", formatHTML},
+		{"JSON", "/search", "application/json", formatJSON},
+		{"JSON preferred by quality", "/search", "text/html;q=0.5, application/json", formatJSON},
+		{"HTML preferred by quality", "/search", "application/json;q=0.2, text/html", formatHTML},
+		{"specific type wins on equal quality", "/search", "*/*, application/json", formatJSON},
+		{"refused type", "/search", "application/json;q=0", formatHTML},
+		{"plain text", "/search", "text/plain", formatText},
+		{"malformed header", "/search", ";;;", formatHTML},
+		{"format overrides Accept", "/search?format=json", "text/html", formatJSON},
+		{"format is case insensitive", "/search?format=HTML", "application/json", formatHTML},
+		{"unknown format falls back to Accept", "/search?format=xml", "application/json", formatJSON},
+		{"API is always JSON", "/api/search?format=html", "text/html", formatJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			if got := negotiateFormat(r); got != tt.want {
+				t.Errorf("negotiateFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchHandlerFormats(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	tests := []struct {
+		target      string
+		accept      string
+		contentType string
+		contains    string
+	}{
+		{"/search?q=go", "text/html", "text/html", "<html"},
+		{"/search?q=go", "application/json", "application/json", `"total_pages":`},
+		{"/search?q=go&format=json", "text/html", "application/json", `"total_pages":`},
+		{"/search?q=go&format=html", "application/json", "text/html", "<html"},
+	}
+
+	for _, tt := range tests {
+		w := serve(handlerWithError(searchHandler), tt.target, http.Header{"Accept": {tt.accept}})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s with Accept %s: status %d, want 200: %s", tt.target, tt.accept, w.Code, w.Body)
+		}
+
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+			t.Errorf("GET %s with Accept %s: Content-Type %q, want %s", tt.target, tt.accept, got, tt.contentType)
+		}
+
+		if !strings.Contains(w.Body.String(), tt.contains) {
+			t.Errorf("GET %s with Accept %s: body doesn't contain %q", tt.target, tt.accept, tt.contains)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Method   string `xml:"method,attr"`
+	Template string `xml:"template,attr"`
+}
+
+type openSearchImage struct {
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+	Type   string `xml:"type,attr"`
+	URL    string `xml:",chardata"`
+}
+
+// openSearchDescription is the document that lets browsers add the app as a search engine,
+// see https://github.com/dewitt/opensearch
+type openSearchDescription struct {
+	XMLName        xml.Name        `xml:"http://a9.com/-/spec/opensearch/1.1/ OpenSearchDescription"`
+	ShortName      string          `xml:"ShortName"`
+	Description    string          `xml:"Description"`
+	InputEncoding  string          `xml:"InputEncoding"`
+	Image          openSearchImage `xml:"Image"`
+	URL            openSearchURL   `xml:"Url"`
+	SearchFormPage string          `xml:"http://www.mozilla.org/2006/browser/search/ SearchForm,omitempty"`
+}
+
+func openSearchHandler(w http.ResponseWriter, r *http.Request) error {
+	base := baseURL(r)
+
+	doc := openSearchDescription{
+		ShortName:     "Wikipedia Search",
+		Description:   "Search Wikipedia articles",
+		InputEncoding: "UTF-8",
+		Image: openSearchImage{
+			Width:  16,
+			Height: 16,
+			Type:   "image/x-icon",
+			URL:    "https://en.wikipedia.org/static/favicon/wikipedia.ico",
+		},
+		URL: openSearchURL{
+			Type:     "text/html",
+			Method:   "get",
+			Template: base + "/search?q={searchTerms}",
+		},
+		SearchFormPage: base + "/",
+	}
+
+	buf := getBuffer()
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(buf)
+	enc.Indent("", "  ")
+
+	err := enc.Encode(doc)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/opensearchdescription+xml; charset=utf-8")
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// orderDescSuffix and orderAscSuffix pick the direction of an order, which is ascending without a suffix
+const (
+	orderDescSuffix = "_desc"
+	orderAscSuffix  = "_asc"
+)
+
+// resultOrder is a value of the order parameter, offered in the search form
+type resultOrder struct {
+	Code string
+	Name string
+}
+
+// resultOrders are the orders offered in the search form. The empty order keeps the order
+// of the Wikipedia API, the others sort the results of the page once they're fetched.
+var resultOrders = []resultOrder{
+	{"", "Relevance"},
+	{"title", "Title (A to Z)"},
+	{"title_desc", "Title (Z to A)"},
+	{"wordcount_desc", "Longest first"},
+	{"wordcount", "Shortest first"},
+	{"size_desc", "Largest first"},
+	{"size", "Smallest first"},
+}
+
+// resultSortKeys compare two results by each field the results can be sorted by
+var resultSortKeys = map[string]func(a, b SearchResult) bool{
+	"title": func(a, b SearchResult) bool {
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	},
+	"wordcount": func(a, b SearchResult) bool {
+		return a.WordCount < b.WordCount
+	},
+	"size": func(a, b SearchResult) bool {
+		return a.Size < b.Size
+	},
+}
+
+// parseOrder splits the order parameter into its sort key and direction, e.g. "size_desc" into "size" and true.
+// ok is false when the key isn't one of resultSortKeys.
+func parseOrder(order string) (key string, desc bool, ok bool) {
+	key = order
+
+	if strings.HasSuffix(key, orderDescSuffix) {
+		key, desc = strings.TrimSuffix(key, orderDescSuffix), true
+	} else {
+		key = strings.TrimSuffix(key, orderAscSuffix)
+	}
+
+	_, ok = resultSortKeys[key]
+
+	return key, desc, ok
+}
+
+// sortResults sorts the results in the given order, which must have been validated by parseOrder.
+// The sort is stable so the results that compare equal keep the order of the Wikipedia API.
+// It only sorts the results of the current page, the pages themselves keep the relevance order.
+func sortResults(results []SearchResult, order string) {
+	key, desc, ok := parseOrder(order)
+	if !ok {
+		return
+	}
+
+	less := resultSortKeys[key]
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if desc {
+			return less(results[j], results[i])
+		}
+
+		return less(results[i], results[j])
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// resultTitles returns the titles of the results, in their order
+func resultTitles(results []SearchResult) string {
+	titles := make([]string, len(results))
+	for i, result := range results {
+		titles[i] = result.Title
+	}
+
+	return strings.Join(titles, ", ")
+}
+
+func TestParseOrder(t *testing.T) {
+	tests := []struct {
+		order string
+		key   string
+		desc  bool
+		ok    bool
+	}{
+		{"title", "title", false, true},
+		{"title_asc", "title", false, true},
+		{"title_desc", "title", true, true},
+		{"wordcount", "wordcount", false, true},
+		{"wordcount_desc", "wordcount", true, true},
+		{"size_asc", "size", false, true},
+		{"size_desc", "size", true, true},
+		{"timestamp", "timestamp", false, false},
+		{"size_up", "size_up", false, false},
+		{"_desc", "", true, false},
+	}
+
+	for _, tt := range tests {
+		key, desc, ok := parseOrder(tt.order)
+		if key != tt.key || desc != tt.desc || ok != tt.ok {
+			t.Errorf("parseOrder(%q) = %q, %t, %t, want %q, %t, %t", tt.order, key, desc, ok, tt.key, tt.desc, tt.ok)
+		}
+	}
+}
+
+func TestSortResults(t *testing.T) {
+	// in the order of the Wikipedia API, with ties on every key
+	results := []SearchResult{
+		{Title: "Go (programming language)", WordCount: 30, Size: 100},
+		{Title: "go", WordCount: 10, Size: 300},
+		{Title: "Gopher", WordCount: 20, Size: 200},
+		{Title: "Go", WordCount: 10, Size: 300},
+	}
+
+	tests := []struct {
+		order string
+		want  string
+	}{
+		// the title ignores the case, go and Go keep the API order
+		{"title", "go, Go, Go (programming language), Gopher"},
+		{"title_asc", "go, Go, Go (programming language), Gopher"},
+		{"title_desc", "Gopher, Go (programming language), go, Go"},
+		{"wordcount", "go, Go, Gopher, Go (programming language)"},
+		{"wordcount_desc", "Go (programming language), Gopher, go, Go"},
+		{"size", "Go (programming language), Gopher, go, Go"},
+		{"size_desc", "go, Go, Gopher, Go (programming language)"},
+		// left in the API order
+		{"", "Go (programming language), go, Gopher, Go"},
+		{"timestamp", "Go (programming language), go, Gopher, Go"},
+	}
+
+	for _, tt := range tests {
+		sorted := append([]SearchResult(nil), results...)
+		sortResults(sorted, tt.order)
+
+		if got := resultTitles(sorted); got != tt.want {
+			t.Errorf("order %q: %s, want %s", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestSearchOrder(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{"", "Go (programming language), Go (game), gopher"},
+		{"title", "Go (game), Go (programming language), gopher"},
+		{"title_desc", "gopher, Go (programming language), Go (game)"},
+		{"wordcount", "Go (game), gopher, Go (programming language)"},
+		{"wordcount_desc", "Go (programming language), gopher, Go (game)"},
+		{"size_asc", "Go (game), gopher, Go (programming language)"},
+		{"size_desc", "Go (programming language), gopher, Go (game)"},
+	}
+
+	for _, tt := range tests {
+		w := serve(handlerWithError(searchHandler), "/search?q=go&format=json&order="+tt.order, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("order %q: status %d, want 200: %s", tt.order, w.Code, w.Body)
+		}
+
+		var s Search
+		if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := resultTitles(s.Results.Query.Search); got != tt.want {
+			t.Errorf("order %q: %s, want %s", tt.order, got, tt.want)
+		}
+	}
+
+	// the cached response keeps the order of the Wikipedia API
+	w := serve(handlerWithError(searchHandler), "/search?q=go&format=json", nil)
+
+	var s Search
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := resultTitles(s.Results.Query.Search); got != tests[0].want {
+		t.Errorf("a sorted page changed the cached response: %s", got)
+	}
+}
+
+func TestInvalidOrder(t *testing.T) {
+	calls := stubSearch(t, searchResponseJSON)
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go&order=timestamp", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want 400", w.Code)
+	}
+
+	if calls.Load() != 0 {
+		t.Error("Wikipedia called for an invalid order")
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// pageIDPrefix turns a query into a lookup of the page with that ID, e.g. "pageid:12345"
+const pageIDPrefix = "pageid:"
+
+var errPageNotFound = errors.New("no page with this ID")
+
+// lookupPage fetches the page of the project with the given ID, with its URL and intro extract,
+// as a search response holding that single result
+func lookupPage(ctx context.Context, project, lang string, pageID int) (*WikipediaSearchResponse, error) {
+	params := url.Values{}
+	params.Set("action", "query")
+	params.Set("formatversion", "2")
+	params.Set("pageids", strconv.Itoa(pageID))
+	params.Set("prop", "info|extracts")
+	params.Set("inprop", "url")
+	params.Set("exintro", "1")
+	params.Set("explaintext", "1")
+	params.Set("exsentences", "2")
+
+	var pageResponse WikipediaCombinedResponse
+
+	err := wikipediaBreaker.Execute(func() error {
+		return callMediaWiki(ctx, project, lang, params, &pageResponse)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pages := pageResponse.Query.Pages
+	if len(pages) == 0 || pages[0].Missing || pages[0].Invalid {
+		return nil, errPageNotFound
+	}
+
+	searchResponse := pageResponse.toSearchResponse()
+	searchResponse.Query.SearchInfo.TotalHits = 1
+
+	config.resultPipeline.Process(searchOptions{Project: project, Lang: lang}, searchResponse.Query.Search)
+
+	return searchResponse, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// pageResponseJSON is the response of the Wikipedia API to the lookup of page 25039021
+const pageResponseJSON = `{
+	"batchcomplete": true,
+	"query": {
+		"pages": [
+			{"pageid": 25039021, "ns": 0, "title": "Go (programming language)", "length": 300,
+				"fullurl": "https://en.wikipedia.org/wiki/Go_(programming_language)",
+				"extract": "Go is a statically typed, compiled high-level programming language."}
+		]
+	}
+}`
+
+// missingPageResponseJSON is the response of the Wikipedia API to the lookup of a page that doesn't exist
+const missingPageResponseJSON = `{
+	"batchcomplete": true,
+	"query": {"pages": [{"pageid": 404, "missing": true}]}
+}`
+
+func TestSearchPageID(t *testing.T) {
+	for _, target := range []string{"/search?pageid=25039021", "/search?q=pageid:25039021"} {
+		t.Run(target, func(t *testing.T) {
+			var sent *http.Request
+			stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+				sent = r
+				return jsonResponse(r, pageResponseJSON), nil
+			})
+
+			w := serve(handlerWithError(searchHandler), target, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+			}
+
+			if got := sent.URL.Query().Get("pageids"); got != "25039021" {
+				t.Errorf("pageids = %q, want 25039021", got)
+			}
+
+			if sent.URL.Query().Has("srsearch") {
+				t.Error("the page is searched instead of looked up")
+			}
+
+			if !strings.Contains(w.Body.String(), "Go (programming language)") {
+				t.Error("the page isn't in the results")
+			}
+		})
+	}
+}
+
+func TestSearchInvalidPageID(t *testing.T) {
+	calls := stubSearch(t, pageResponseJSON)
+
+	for _, target := range []string{
+		"/search?pageid=abc",
+		"/search?pageid=0",
+		"/search?pageid=-1",
+		"/search?pageid=99999999999999999999",
+		"/search?q=pageid:twelve",
+	} {
+		if w := serve(handlerWithError(searchHandler), target, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want 400", target, w.Code)
+		}
+	}
+
+	if calls.Load() != 0 {
+		t.Errorf("%d calls to Wikipedia for invalid page IDs, want none", calls.Load())
+	}
+}
+
+func TestSearchMissingPageID(t *testing.T) {
+	stubSearch(t, missingPageResponseJSON)
+
+	if w := serve(handlerWithError(searchHandler), "/search?pageid=404", nil); w.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// pageviewsAPIURL is the Wikimedia REST endpoint of the daily views of an article,
+// see https://wikitech.wikimedia.org/wiki/Analytics/AQS/Pageviews
+const pageviewsAPIURL = "https://wikimedia.org/api/rest_v1/metrics/pageviews/per-article/%s/all-access/user/%s/daily/%s/%s"
+
+// pageviewsDays is the number of days the views of a result are counted over
+const pageviewsDays = 30
+
+// errNoPageviews is returned for the articles the pageviews API has no data for,
+// e.g. those created after the start of the period
+var errNoPageviews = errors.New("no pageviews data")
+
+type WikimediaPageviewsResponse struct {
+	Items []struct {
+		Views int `json:"views"`
+	} `json:"items"`
+}
+
+// pageviewsURL returns the URL of the daily views of title on the language edition of a project
+// over the pageviewsDays full days before now
+func pageviewsURL(project, lang, title string, now time.Time) string {
+	// the data of the current day is incomplete
+	end := now.UTC().AddDate(0, 0, -1)
+	start := end.AddDate(0, 0, -(pageviewsDays - 1))
+
+	return fmt.Sprintf(
+		pageviewsAPIURL,
+		lang+"."+project,
+		url.PathEscape(strings.ReplaceAll(title, " ", "_")),
+		start.Format("20060102"),
+		end.Format("20060102"),
+	)
+}
+
+// fetchPageviews returns the number of views of an article over the last pageviewsDays days.
+// This is the REST API of Wikimedia rather than the action API of callMediaWiki, it takes none of its parameters.
+func fetchPageviews(ctx context.Context, project, lang, title string) (int, error) {
+	ctx = startSpan(ctx, "pageviews")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageviewsURL(project, lang, title, time.Now()), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, errNoPageviews
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		respData, _ := httputil.DumpResponse(resp, true)
+
+		return 0, &apiStatusError{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			dump:       string(respData),
+		}
+	}
+
+	body, err := responseBody(resp)
+	if err != nil {
+		return 0, err
+	}
+
+	defer body.Close()
+
+	var pageviews WikimediaPageviewsResponse
+
+	err = json.NewDecoder(body).Decode(&pageviews)
+	if err != nil {
+		return 0, err
+	}
+
+	views := 0
+	for _, item := range pageviews.Items {
+		views += item.Views
+	}
+
+	zerolog.Ctx(ctx).Debug().
+		Str("title", title).
+		Int("views", views).
+		Msg("received Wikimedia pageviews response")
+
+	return views, nil
+}
+
+// addPageviews sets the views of the results over the last pageviewsDays days, one request per result.
+// The results without data or whose request failed are left without views. It returns the number of
+// failed requests, and the error of ctx if some were cut short by it, which aren't counted as failed.
+// The response must not be shared, see WikipediaSearchResponse.clone.
+func addPageviews(ctx context.Context, project, lang string, searchResponse *WikipediaSearchResponse) (failed int, err error) {
+	results := searchResponse.Query.Search
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		last error
+	)
+
+	for i := range results {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			views, fetchErr := fetchPageviews(ctx, project, lang, results[i].Title)
+			if errors.Is(fetchErr, errNoPageviews) {
+				return
+			}
+			if fetchErr != nil {
+				mu.Lock()
+				defer mu.Unlock()
+
+				// running out of time isn't a failure of the API, see enrichmentBudget
+				if cutShort(ctx, fetchErr) {
+					err = ctx.Err()
+				} else {
+					failed, last = failed+1, fetchErr
+				}
+
+				return
+			}
+
+			results[i].Views = views
+		}(i)
+	}
+
+	wg.Wait()
+
+	// logged once rather than for every result, they usually fail together
+	if failed > 0 {
+		zerolog.Ctx(ctx).Warn().
+			Err(last).
+			Int("failed", failed).
+			Msg("unable to fetch the pageviews of the results")
+	}
+
+	return failed, err
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// errEmptyQuery is reported for a search without a query, which isn't sent to Wikipedia.
+// The handlers answer it with a 400, searchWikipedia returns it too for the callers that skip the checks.
+var errEmptyQuery = errors.New("empty search query")
+
+// searchParams are the validated query parameters of a search
+type searchParams struct {
+	Query            string
+	Project          string
+	Lang             string
+	Page             int
+	AllNamespaces    bool
+	Geo              bool
+	ResolveRedirects bool
+	Interwiki        bool
+	Safe             bool
+	Extracts         bool
+	Profile          string
+	Exclude          []string
+
+	// Refine are the terms added to the query to search within its results, from the add parameters
+	Refine []string
+
+	// Order sorts the results of the page once they're fetched, see sortResults.
+	// Empty keeps the order of the Wikipedia API.
+	Order string
+
+	// PageID is set to look up a single page instead of searching,
+	// from the pageid parameter or a query like "pageid:12345"
+	PageID int
+}
+
+// scalarSearchParams are the query parameters of a search that take a single value
+var scalarSearchParams = []string{
+	"q", "exclude", "pageid", "page", "lang", "profile", "all_namespaces", "geo", "resolve_redirects", "theme", "format", "raw", "callback", "project", "offset", "interwiki", "safe", "extracts", "order",
+}
+
+// parseSearchParams extracts the search parameters from the query string, applying the defaults of cfg.
+// Without a lang parameter, the language is picked from the Accept-Language header acceptLanguage.
+// The page is clamped between 1 and the last page the Wikipedia API can serve, an unknown profile
+// falls back to defaultProfile. Invalid values that can't be corrected are reported as a 400 statusError,
+// and so are repeated parameters since silently picking one of the values would hide client bugs.
+func parseSearchParams(values url.Values, acceptLanguage string, cfg Config) (searchParams, error) {
+	for _, name := range scalarSearchParams {
+		if len(values[name]) > 1 {
+			return searchParams{}, &statusError{
+				http.StatusBadRequest,
+				fmt.Errorf("parameter '%s' given %d times, expected once", name, len(values[name])),
+			}
+		}
+	}
+
+	p := searchParams{
+		Query:            values.Get("q"),
+		Lang:             values.Get("lang"),
+		AllNamespaces:    values.Get("all_namespaces") == "1",
+		Geo:              cfg.Features.Geo && values.Get("geo") == "1",
+		ResolveRedirects: values.Get("resolve_redirects") == "1",
+		Interwiki:        cfg.Features.Interwiki && values.Get("interwiki") == "1",
+		Safe:             values.Get("safe") == "1",
+		Extracts:         cfg.Features.Extracts && values.Get("extracts") == "1",
+		Profile:          values.Get("profile"),
+		Project:          values.Get("project"),
+		Order:            values.Get("order"),
+		Page:             1,
+	}
+
+	if p.Project == "" {
+		p.Project = defaultProject
+	}
+
+	if !isProject(p.Project) {
+		return p, &statusError{
+			http.StatusBadRequest,
+			fmt.Errorf("unsupported project '%s'", p.Project),
+		}
+	}
+
+	if p.Lang == "" {
+		p.Lang = preferredLanguage(acceptLanguage, cfg.DefaultLang)
+	}
+
+	if !isSupportedLanguage(p.Lang) {
+		return p, &statusError{
+			http.StatusBadRequest,
+			fmt.Errorf("unsupported language '%s'", p.Lang),
+		}
+	}
+
+	p.Exclude = parseExclude(values.Get("exclude"))
+	if len(p.Exclude) > 0 && strings.TrimSpace(p.Query) == "" {
+		return p, &statusError{
+			http.StatusBadRequest,
+			errors.New("excluded terms need a query to exclude them from"),
+		}
+	}
+
+	p.Refine = parseRefinements(values["add"])
+	if len(p.Refine) > 0 && strings.TrimSpace(p.Query) == "" {
+		return p, &statusError{
+			http.StatusBadRequest,
+			errors.New("added terms need a query to refine"),
+		}
+	}
+
+	pageID := values.Get("pageid")
+	if pageID == "" && strings.HasPrefix(p.Query, pageIDPrefix) {
+		pageID = strings.TrimSpace(strings.TrimPrefix(p.Query, pageIDPrefix))
+	}
+
+	if pageID != "" {
+		id, err := strconv.Atoi(pageID)
+		if err != nil || id < 1 {
+			return p, &statusError{
+				http.StatusBadRequest,
+				fmt.Errorf("invalid page ID '%s'", pageID),
+			}
+		}
+
+		p.PageID = id
+	}
+
+	if p.Order != "" {
+		key, desc, ok := parseOrder(p.Order)
+		if !ok {
+			return p, &statusError{
+				http.StatusBadRequest,
+				fmt.Errorf("unsupported order '%s'", p.Order),
+			}
+		}
+
+		// the ascending orders are kept without their optional suffix, as offered in the form
+		p.Order = key
+		if desc {
+			p.Order += orderDescSuffix
+		}
+	}
+
+	if !isSearchProfile(p.Profile) {
+		p.Profile = defaultProfile
+	}
+
+	if v := values.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil {
+			return p, &statusError{
+				http.StatusBadRequest,
+				fmt.Errorf("invalid page '%s'", v),
+			}
+		}
+
+		p.Page = page
+	}
+
+	maxPages := cfg.MaxResultOffset / cfg.PageSize
+	if maxPages < 1 {
+		maxPages = 1
+	}
+
+	if p.Page < 1 {
+		p.Page = 1
+	} else if p.Page > maxPages {
+		p.Page = maxPages
+	}
+
+	return p, nil
+}
+
+// maxExcludedTerms keeps the search string within what the Wikipedia API accepts
+const maxExcludedTerms = 10
+
+// parseExclude splits the exclude parameter on commas and spaces.
+// Quotes and backslashes are dropped as each term gets quoted in the search string,
+// duplicates are ignored and only the first maxExcludedTerms are kept.
+func parseExclude(v string) []string {
+	v = strings.NewReplacer(`"`, " ", `\`, " ").Replace(v)
+
+	fields := strings.FieldsFunc(v, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	var terms []string
+	seen := make(map[string]bool)
+
+	for _, term := range fields {
+		if seen[term] {
+			continue
+		}
+
+		seen[term] = true
+		terms = append(terms, term)
+		if len(terms) == maxExcludedTerms {
+			break
+		}
+	}
+
+	return terms
+}
+
+// maxRefinements keeps the search string within what the Wikipedia API accepts, along with maxExcludedTerms
+const maxRefinements = 10
+
+// parseRefinements cleans up the terms of the add parameters, which may be phrases.
+// Quotes and backslashes are dropped as each term gets quoted in the search string,
+// blank terms and duplicates are ignored and only the first maxRefinements are kept.
+func parseRefinements(values []string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+
+	for _, v := range values {
+		v = strings.NewReplacer(`"`, " ", `\`, " ").Replace(v)
+
+		term := strings.Join(strings.Fields(v), " ")
+		if term == "" || seen[term] {
+			continue
+		}
+
+		seen[term] = true
+		terms = append(terms, term)
+		if len(terms) == maxRefinements {
+			break
+		}
+	}
+
+	return terms
+}
+
+// options returns the options of the Wikipedia search for the requested page of pageSize results
+func (p searchParams) options(pageSize int) searchOptions {
+	return searchOptions{
+		Query:   p.Query,
+		Project: p.Project,
+		Lang:    p.Lang,
+		Limit:   pageSize,
+		Offset:  (p.Page - 1) * pageSize,
+
+		ResolveRedirects: p.ResolveRedirects,
+		Profile:          p.Profile,
+		Exclude:          p.Exclude,
+		Refine:           p.Refine,
+		Interwiki:        p.Interwiki,
+		Safe:             p.Safe,
+		Extracts:         p.Extracts,
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func FuzzSearchParams(f *testing.F) {
+	seeds := []struct {
+		query, acceptLanguage string
+	}{
+		{"q=go", ""},
+		{"q=go&page=2&lang=fr&profile=classic", "de-DE,de;q=0.9"},
+		{"q=go&page=-1", ""},
+		{"q=go&page=99999999999999999999", ""},
+		{"q=go&page=abc", ""},
+		{"q=go&page=1&page=2", ""},
+		{"q=go&lang=xx", ""},
+		{"q=go&project=wiktionary&lang=en", ""},
+		{"q=go&project=nope", ""},
+		{"q=pageid:42", ""},
+		{"q=go&pageid=0", ""},
+		{"q=go&exclude=game,%22board%22+rodent&add=%22programming+language%22&add=%5C", ""},
+		{"exclude=game", ""},
+		{"add=language", ""},
+		{"q=go&order=size_desc", ""},
+		{"q=go&order=size_sideways", ""},
+		{"q=go&all_namespaces=1&geo=1&resolve_redirects=1&interwiki=1&safe=1&extracts=1", "*"},
+		{"q=%00%ff", ";q=abc,,"},
+	}
+	for _, seed := range seeds {
+		f.Add(seed.query, seed.acceptLanguage)
+	}
+
+	maxPages := config.MaxResultOffset / config.PageSize
+
+	f.Fuzz(func(t *testing.T, query, acceptLanguage string) {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			t.Skip()
+		}
+
+		p, err := parseSearchParams(values, acceptLanguage, config)
+		if err != nil {
+			var se *statusError
+			if !errors.As(err, &se) || se.code != http.StatusBadRequest {
+				t.Fatalf("parseSearchParams(%q) = %v, want a 400", query, err)
+			}
+
+			return
+		}
+
+		if p.Page < 1 || p.Page > maxPages {
+			t.Errorf("page %d out of [1, %d]", p.Page, maxPages)
+		}
+
+		if !isProject(p.Project) || !isSupportedLanguage(p.Lang) || !isSearchProfile(p.Profile) {
+			t.Errorf("invalid project %q, language %q or profile %q", p.Project, p.Lang, p.Profile)
+		}
+
+		if p.PageID < 0 {
+			t.Errorf("negative page ID %d", p.PageID)
+		}
+
+		if p.Order != "" {
+			if _, _, ok := parseOrder(p.Order); !ok {
+				t.Errorf("invalid order %q", p.Order)
+			}
+		}
+
+		if len(p.Exclude) > maxExcludedTerms || len(p.Refine) > maxRefinements {
+			t.Errorf("%d excluded terms and %d refinements, want at most %d and %d",
+				len(p.Exclude), len(p.Refine), maxExcludedTerms, maxRefinements)
+		}
+
+		for _, term := range append(p.Exclude, p.Refine...) {
+			if term == "" || strings.ContainsAny(term, `"\`) {
+				t.Errorf("term %q would break out of its quotes in the search string", term)
+			}
+		}
+	})
+}
+
+func TestParseSearchParamsDuplicates(t *testing.T) {
+	tests := []struct {
+		query string
+		valid bool
+	}{
+		{"q=a&q=b", false},
+		{"q=a&q=a", false},
+		{"q=go&page=1&page=2", false},
+		{"q=go&lang=en&lang=fr", false},
+		{"q=go&profile=classic&profile=classic", false},
+		{"q=go&theme=dark&theme=light", false},
+		{"q=go&order=size&order=title", false},
+		{"q=go&exclude=a&exclude=b", false},
+		{"q=go&add=a&add=b", true},
+		{"q=go&page=2&lang=fr", true},
+	}
+
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+
+		_, err := parseSearchParams(values, "", config)
+
+		var se *statusError
+		switch {
+		case tt.valid && err != nil:
+			t.Errorf("%s: unexpected error %v", tt.query, err)
+		case !tt.valid && (!errors.As(err, &se) || se.code != http.StatusBadRequest):
+			t.Errorf("%s: error %v, want a 400", tt.query, err)
+		}
+	}
+}
+
+func TestSearchHandlerDuplicateQuery(t *testing.T) {
+	calls := stubSearch(t, searchResponseJSON)
+
+	w := serve(handlerWithError(searchHandler), "/search?q=a&q=b", nil)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want 400", w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), "'q' given 2 times") {
+		t.Errorf("the error doesn't name the duplicated parameter: %s", w.Body)
+	}
+
+	if calls.Load() != 0 {
+		t.Errorf("%d calls to Wikipedia, want none", calls.Load())
+	}
+}
+
+func TestParseExclude(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"game", []string{"game"}},
+		{"game, board  rodent", []string{"game", "board", "rodent"}},
+		{"game,game", []string{"game"}},
+		{`"board game"`, []string{"board", "game"}},
+		{`game\`, []string{"game"}},
+		{`game\" OR "x`, []string{"game", "OR", "x"}},
+		{`\"`, nil},
+		{"a,b,c,d,e,f,g,h,i,j,k,l", []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}},
+	}
+
+	for _, tt := range tests {
+		got := parseExclude(tt.in)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("parseExclude(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearchTermsNegation(t *testing.T) {
+	tests := []struct {
+		exclude string
+		want    string
+	}{
+		{"game", `go -"game"`},
+		{"game,board", `go -"game" -"board"`},
+		{`game\`, `go -"game"`},
+		{`game" OR "x`, `go -"game" -"OR" -"x"`},
+	}
+
+	for _, tt := range tests {
+		values := url.Values{"q": {"go"}, "exclude": {tt.exclude}}
+
+		p, err := parseSearchParams(values, "", config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := p.options(20).searchTerms(); got != tt.want {
+			t.Errorf("exclude %q: srsearch = %q, want %q", tt.exclude, got, tt.want)
+		}
+	}
+}
+
+func TestParseRefinements(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want []string
+	}{
+		{nil, nil},
+		{[]string{"language"}, []string{"language"}},
+		{[]string{"  board   game "}, []string{"board game"}},
+		{[]string{"game", "", " ", "game"}, []string{"game"}},
+		{[]string{`game" OR "x`}, []string{"game OR x"}},
+		{[]string{`game\`}, []string{"game"}},
+		{[]string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}, []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}},
+	}
+
+	for _, tt := range tests {
+		got := parseRefinements(tt.in)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("parseRefinements(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearchRefinements(t *testing.T) {
+	var searched []string
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		searched = append(searched, r.URL.Query().Get("srsearch"))
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	// adding terms
+	w := serve(handlerWithError(searchHandler), "/search?q=go&add=language&add=board+game", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if want := `go "language" "board game"`; searched[0] != want {
+		t.Errorf("srsearch = %q, want %q", searched[0], want)
+	}
+
+	body := w.Body.String()
+	if strings.Count(body, `class="refine-chip"`) != 2 {
+		t.Error("the added terms aren't shown as removable chips")
+	}
+
+	if !strings.Contains(body, "add=language&amp;add=board&#43;game&amp;page=2") {
+		t.Error("the added terms aren't kept across pages")
+	}
+
+	// removing one
+	s := &Search{Query: "go", Lang: config.DefaultLang, Refine: []string{"language", "board game"}}
+
+	without := s.WithoutRefinementURL("language")
+	if strings.Contains(without, "add=language") || !strings.Contains(without, "add=board+game") {
+		t.Fatalf("WithoutRefinementURL(language) = %s", without)
+	}
+
+	w = serve(handlerWithError(searchHandler), without, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if want := `go "board game"`; searched[1] != want {
+		t.Errorf("srsearch after removing a term = %q, want %q", searched[1], want)
+	}
+
+	// an added term needs a query to refine
+	w = serve(handlerWithError(searchHandler), "/search?add=language", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("add without q: status %d, want 400", w.Code)
+	}
+
+	if len(searched) != 2 {
+		t.Errorf("%d searches, want 2", len(searched))
+	}
+}
+
+func TestSafeSearch(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.SafeSearchExcludedCategories = []string{"Violence", "Adult content"}
+	})
+
+	var sent []url.Values
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		sent = append(sent, r.URL.Query())
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go&safe=1", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if want := `go -incategory:"Violence" -incategory:"Adult content"`; sent[0].Get("srsearch") != want {
+		t.Errorf("srsearch = %q, want %q", sent[0].Get("srsearch"), want)
+	}
+
+	if sent[0].Get("srnamespace") != "0" {
+		t.Errorf("srnamespace = %q, want 0", sent[0].Get("srnamespace"))
+	}
+
+	if !strings.Contains(w.Body.String(), "page=2&amp;q=go&amp;safe=1") {
+		t.Error("the safe search isn't kept across pages")
+	}
+
+	// without safe=1
+	w = serve(handlerWithError(searchHandler), "/search?q=go", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if sent[1].Get("srsearch") != "go" || sent[1].Has("srnamespace") {
+		t.Errorf("srsearch = %q, srnamespace = %q without safe=1", sent[1].Get("srsearch"), sent[1].Get("srnamespace"))
+	}
+
+	// the combined search is restricted the same way
+	w = serve(handlerWithError(searchHandler), "/search?q=go&safe=1&extracts=1", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if sent[2].Get("gsrnamespace") != "0" || !strings.HasSuffix(sent[2].Get("gsrsearch"), `-incategory:"Adult content"`) {
+		t.Errorf("gsrsearch = %q, gsrnamespace = %q with safe=1", sent[2].Get("gsrsearch"), sent[2].Get("gsrnamespace"))
+	}
+}
+
+func TestInvalidSafeSearchCategories(t *testing.T) {
+	for _, category := range []string{"", " ", `Adult" OR "x`, `Adult\`} {
+		cfg := defaultConfig()
+		cfg.SafeSearchExcludedCategories = []string{category}
+
+		if err := cfg.validate(); err == nil {
+			t.Errorf("category %q passes validation", category)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// maxConcurrentPrefetches bounds the background searches in flight, prefetches and refreshes ahead
+const maxConcurrentPrefetches = 4
+
+// prefetchSlots holds a token per background search in flight.
+// When they're all taken, new ones are skipped rather than queued.
+var prefetchSlots = make(chan struct{}, maxConcurrentPrefetches)
+
+// prefetchSearch runs a search in the background so that its results are cached
+// by the time they're requested. It never blocks and failures are only logged.
+func prefetchSearch(l zerolog.Logger, opts searchOptions) {
+	select {
+	case prefetchSlots <- struct{}{}:
+	default:
+		l.Debug().Msg("too many prefetches in flight, skipping")
+		return
+	}
+
+	go func() {
+		defer func() { <-prefetchSlots }()
+
+		// the prefetch outlives the request that triggered it
+		ctx, cancel := context.WithTimeout(
+			l.WithContext(context.Background()),
+			time.Duration(config.RequestTimeout),
+		)
+		defer cancel()
+
+		_, cacheStatus, err := cachedSearch(ctx, opts)
+		if err != nil {
+			l.Debug().Err(err).Int("offset", opts.Offset).Msg("unable to prefetch the next page")
+			return
+		}
+
+		l.Debug().
+			Int("offset", opts.Offset).
+			Str("cache", cacheStatus).
+			Msg("next page prefetched")
+	}()
+}
+
+// refreshing holds the cache keys being refreshed ahead, so that the hits served
+// while an entry is refreshed don't start another refresh
+var refreshing sync.Map
+
+// refreshAhead refreshes the cache entry of a search in the background when a hit finds it within
+// config.CacheRefreshAhead of its expiry, so that the next hits get fresh results without waiting for Wikipedia.
+// It never blocks: the refresh takes one of the prefetchSlots, or is skipped, and it's coalesced
+// with the identical searches in flight. Failures are only logged, the entry is refreshed on a later hit.
+func refreshAhead(l zerolog.Logger, key string, opts searchOptions, combined bool) {
+	if _, inFlight := refreshing.LoadOrStore(key, struct{}{}); inFlight {
+		return
+	}
+
+	select {
+	case prefetchSlots <- struct{}{}:
+	default:
+		refreshing.Delete(key)
+		l.Debug().Msg("too many background searches in flight, skipping the refresh ahead")
+		return
+	}
+
+	l.Debug().Msg("cache entry close to expiry, refreshing it ahead")
+
+	go func() {
+		defer func() { <-prefetchSlots }()
+		defer refreshing.Delete(key)
+
+		start := time.Now()
+
+		// the search is detached from the request, within MaxRequestTimeout, see searchFlight
+		result := <-searchFlight(&l, key, opts, combined)
+		if result.Err != nil {
+			l.Debug().Err(result.Err).Msg("unable to refresh the cache entry ahead of expiry")
+			return
+		}
+
+		l.Debug().
+			Dur("duration", time.Since(start)).
+			Msg("cache entry refreshed ahead of expiry")
+	}()
+}
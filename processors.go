@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ResultProcessor transforms each result of a search before it's cached and served
+type ResultProcessor interface {
+	// Name identifies the processor in config.ResultProcessors
+	Name() string
+	// Process transforms result, one of the results of the search described by opts
+	Process(opts searchOptions, result *SearchResult)
+}
+
+// resultProcessors are the processors that can be listed in config.ResultProcessors, by name.
+// The enrichments that take a request to Wikimedia for all the results at once
+// (coordinates, views) aren't processors, they run within the time budget of runSearch.
+var resultProcessors = processorsByName(
+	urlProcessor{},
+	snippetProcessor{},
+	truncateProcessor{},
+	highlightProcessor{},
+)
+
+func processorsByName(processors ...ResultProcessor) map[string]ResultProcessor {
+	byName := make(map[string]ResultProcessor, len(processors))
+	for _, p := range processors {
+		byName[p.Name()] = p
+	}
+
+	return byName
+}
+
+// defaultResultProcessors is the default of config.ResultProcessors. The snippets are truncated by the template
+// so that the API keeps serving them whole, add "truncate" to serve them truncated.
+var defaultResultProcessors = []string{"url", "snippet", "highlight"}
+
+// resultPipeline runs its processors over every result, in order
+type resultPipeline []ResultProcessor
+
+// newResultPipeline returns the pipeline of the processors with the given names, in the order given.
+// The "url" processor is required: the results of list=search have no URL of their own, and only
+// the search knows the project and edition they come from.
+func newResultPipeline(names []string) (resultPipeline, error) {
+	pipeline := make(resultPipeline, 0, len(names))
+	seen := make(map[string]bool, len(names))
+
+	for _, name := range names {
+		p, ok := resultProcessors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown result processor '%s'", name)
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("result processor '%s' listed more than once", name)
+		}
+		seen[name] = true
+
+		pipeline = append(pipeline, p)
+	}
+
+	if !seen["url"] {
+		return nil, errors.New("result processor 'url' is required, it links the results to their pages")
+	}
+
+	return pipeline, nil
+}
+
+// Process runs the pipeline over the results of the search described by opts
+func (p resultPipeline) Process(opts searchOptions, results []SearchResult) {
+	for i := range results {
+		for _, processor := range p {
+			processor.Process(opts, &results[i])
+		}
+	}
+}
+
+// urlProcessor links the results that come without a URL, from list=search,
+// to the pages of the right project and edition
+type urlProcessor struct{}
+
+func (urlProcessor) Name() string { return "url" }
+
+func (urlProcessor) Process(opts searchOptions, result *SearchResult) {
+	if result.FullURL == "" {
+		result.FullURL = pageURL(opts.Project, opts.Lang, result.PageID)
+	}
+}
+
+// snippetProcessor fills CleanSnippet, see cleanSnippet
+type snippetProcessor struct{}
+
+func (snippetProcessor) Name() string { return "snippet" }
+
+func (snippetProcessor) Process(_ searchOptions, result *SearchResult) {
+	result.CleanSnippet = cleanSnippet(result.Snippet)
+}
+
+// truncateProcessor truncates CleanSnippet to config.SnippetLength characters, see truncate
+type truncateProcessor struct{}
+
+func (truncateProcessor) Name() string { return "truncate" }
+
+func (truncateProcessor) Process(_ searchOptions, result *SearchResult) {
+	result.CleanSnippet = truncate(result.CleanSnippet, config.SnippetLength)
+}
+
+// highlightProcessor fills TitleHTML with the title where the words of the query are highlighted, see highlight
+type highlightProcessor struct{}
+
+func (highlightProcessor) Name() string { return "highlight" }
+
+func (highlightProcessor) Process(opts searchOptions, result *SearchResult) {
+	result.TitleHTML = highlight(result.Title, opts.Query)
+}
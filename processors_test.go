@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestURLProcessor(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   searchOptions
+		result SearchResult
+		want   string
+	}{
+		{"list=search result", searchOptions{Project: "wiktionary", Lang: "fr"}, SearchResult{PageID: 42},
+			"https://fr.wiktionary.org?curid=42"},
+		{"combined result", searchOptions{Project: "wikipedia", Lang: "de"},
+			SearchResult{PageID: 42, FullURL: "https://de.wikipedia.org/wiki/Go"}, "https://de.wikipedia.org/wiki/Go"},
+	}
+
+	for _, tt := range tests {
+		urlProcessor{}.Process(tt.opts, &tt.result)
+
+		if got := tt.result.URL(); got != tt.want {
+			t.Errorf("%s: URL() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSnippetProcessor(t *testing.T) {
+	result := SearchResult{Snippet: "'''Go''' is a [[Programming language|language]]"}
+	snippetProcessor{}.Process(searchOptions{}, &result)
+
+	if result.CleanSnippet != "Go is a language" || result.Snippet == result.CleanSnippet {
+		t.Errorf("CleanSnippet = %q, Snippet = %q", result.CleanSnippet, result.Snippet)
+	}
+}
+
+func TestTruncateProcessor(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.SnippetLength = 10
+	})
+
+	result := SearchResult{CleanSnippet: "Go is a programming language"}
+	truncateProcessor{}.Process(searchOptions{}, &result)
+
+	if got := []rune(result.CleanSnippet); len(got) > 11 || !strings.HasPrefix(string(got), "Go is a") {
+		t.Errorf("CleanSnippet = %q, want it cut to 10 characters", result.CleanSnippet)
+	}
+}
+
+func TestHighlightProcessor(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.HighlightClass = wikipediaHighlightClass
+	})
+
+	result := SearchResult{Title: "Go (game)"}
+	highlightProcessor{}.Process(searchOptions{Query: "go"}, &result)
+
+	if want := `<span class="searchmatch">Go</span> (game)`; string(result.TitleHTML) != want {
+		t.Errorf("TitleHTML = %q, want %q", result.TitleHTML, want)
+	}
+}
+
+func TestNewResultPipeline(t *testing.T) {
+	tests := []struct {
+		names   []string
+		wantErr string
+	}{
+		{defaultResultProcessors, ""},
+		{[]string{"highlight", "url"}, ""},
+		{[]string{"url", "snippet", "truncate", "highlight"}, ""},
+		{[]string{"url", "bogus"}, "unknown result processor 'bogus'"},
+		{[]string{"url", "snippet", "snippet"}, "listed more than once"},
+		{[]string{"snippet", "highlight"}, "'url' is required"},
+		{nil, "'url' is required"},
+	}
+
+	for _, tt := range tests {
+		pipeline, err := newResultPipeline(tt.names)
+
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%q: %v", tt.names, err)
+				continue
+			}
+
+			for i, p := range pipeline {
+				if p.Name() != tt.names[i] {
+					t.Errorf("%q: processor %d is %s, want the order given", tt.names, i, p.Name())
+				}
+			}
+
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%q: err = %v, want %q", tt.names, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResultPipeline(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.SnippetLength = 12
+		c.HighlightClass = wikipediaHighlightClass
+	})
+
+	// the processors run in the order given: truncating before the snippet is cleaned up has no effect
+	for _, tt := range []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"url", "snippet", "truncate"}, "Go is a…"},
+		{[]string{"url", "truncate", "snippet"}, "Go is a language"},
+	} {
+		pipeline, err := newResultPipeline(tt.names)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		results := []SearchResult{{PageID: 1, Title: "Go", Snippet: "'''Go''' is a language"}}
+		pipeline.Process(searchOptions{Query: "go", Project: "wikibooks", Lang: "en"}, results)
+
+		if results[0].CleanSnippet != tt.want {
+			t.Errorf("%q: CleanSnippet = %q, want %q", tt.names, results[0].CleanSnippet, tt.want)
+		}
+
+		if results[0].URL() != "https://en.wikibooks.org?curid=1" || results[0].TitleHTML != "" {
+			t.Errorf("%q: URL() = %q, TitleHTML = %q", tt.names, results[0].URL(), results[0].TitleHTML)
+		}
+	}
+}
+
+func TestSearchResultURLsFollowTheSearch(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	resp, err := searchWikipedia(context.Background(), searchOptions{Query: "go", Project: "wikiquote", Lang: "fr", Limit: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range resp.Query.Search {
+		if !strings.HasPrefix(result.URL(), "https://fr.wikiquote.org?curid=") {
+			t.Errorf("URL() = %q, want a link to the French Wikiquote", result.URL())
+		}
+	}
+}
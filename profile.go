@@ -0,0 +1,28 @@
+package main
+
+// defaultProfile lets Wikipedia pick the best relevance profile for the query
+const defaultProfile = "engine_autoselect"
+
+// searchProfiles are the values of the srqiprofile parameter of the Wikipedia search API,
+// see https://www.mediawiki.org/wiki/API:Search
+var searchProfiles = []string{
+	"classic",
+	"classic_noboostlinks",
+	"empty",
+	"engine_autoselect",
+	"mlr-1024rs",
+	"popular_inclinks",
+	"popular_inclinks_pv",
+	"wsum_inclinks",
+	"wsum_inclinks_pv",
+}
+
+func isSearchProfile(profile string) bool {
+	for _, p := range searchProfiles {
+		if p == profile {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSearchProfileForwarded(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		profile string
+	}{
+		{"default", "/search?q=go", defaultProfile},
+		{"known", "/search?q=go&profile=popular_inclinks", "popular_inclinks"},
+		{"classic", "/search?q=go&profile=classic", "classic"},
+		{"unknown", "/search?q=go&profile=fastest", defaultProfile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var profiles []string
+
+			stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+				mu.Lock()
+				profiles = append(profiles, r.URL.Query().Get("srqiprofile"))
+				mu.Unlock()
+
+				return jsonResponse(r, searchResponseJSON), nil
+			})
+
+			w := serve(handlerWithError(searchHandler), tt.target, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+			}
+
+			if len(profiles) == 0 {
+				t.Fatal("no request sent to Wikipedia")
+			}
+
+			for _, got := range profiles {
+				if got != tt.profile {
+					t.Errorf("srqiprofile = %q, want %q", got, tt.profile)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchProfileKeptAcrossPages(t *testing.T) {
+	s := pageOfSearch(1234, 2, 20)
+	s.Profile = "popular_inclinks"
+
+	if got := s.PageURL(3); !strings.Contains(got, "profile=popular_inclinks") {
+		t.Errorf("PageURL(3) = %q, want the profile", got)
+	}
+
+	s.Profile = defaultProfile
+
+	if got := s.PageURL(3); strings.Contains(got, "profile=") {
+		t.Errorf("PageURL(3) = %q, want no default profile", got)
+	}
+}
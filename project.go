@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// defaultProject is the Wikimedia project searched when the project parameter isn't set
+const defaultProject = "wikipedia"
+
+type wikiProject struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// projects are the Wikimedia projects that can be searched with the project parameter.
+// They run MediaWiki with the same API as Wikipedia at https://{lang}.{code}.org.
+var projects = []wikiProject{
+	{"wikipedia", "Wikipedia"},
+	{"wiktionary", "Wiktionary"},
+	{"wikibooks", "Wikibooks"},
+	{"wikiquote", "Wikiquote"},
+	{"wikisource", "Wikisource"},
+	{"wikivoyage", "Wikivoyage"},
+}
+
+func isProject(code string) bool {
+	for _, project := range projects {
+		if project.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// siteURL replaces the {project} and {lang} placeholders of a URL template like wikipediaAPIURL
+func siteURL(template, project, lang string) string {
+	return strings.NewReplacer("{project}", project, "{lang}", lang).Replace(template)
+}
+
+// pageURL returns the link to a page of the given project and language edition by page ID
+func pageURL(project, lang string, pageID int) string {
+	return "https://" + lang + "." + project + ".org?curid=" + strconv.Itoa(pageID)
+}
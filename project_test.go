@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSearchSisterProject(t *testing.T) {
+	var hosts []string
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		hosts = append(hosts, r.URL.Host)
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go&project=wiktionary&lang=fr&format=json", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if len(hosts) != 1 || hosts[0] != "fr.wiktionary.org" {
+		t.Errorf("searched %v, want fr.wiktionary.org", hosts)
+	}
+
+	var s Search
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Project != "wiktionary" || s.IsWikipedia() {
+		t.Errorf("Project = %q, want wiktionary", s.Project)
+	}
+
+	if next := s.PageURL(2); !strings.Contains(next, "project=wiktionary") {
+		t.Errorf("the next page %s doesn't keep the project", next)
+	}
+
+	w = serve(handlerWithError(searchHandler), "/search?q=go&project=wikibooks", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if hosts[len(hosts)-1] != "en.wikibooks.org" {
+		t.Errorf("searched %s, want en.wikibooks.org", hosts[len(hosts)-1])
+	}
+
+	if !strings.Contains(w.Body.String(), "project=wikibooks&amp;") {
+		t.Error("the pagination links don't keep the project")
+	}
+}
+
+func TestSearchUnknownProject(t *testing.T) {
+	calls := stubSearch(t, searchResponseJSON)
+
+	for _, project := range []string{"wikidata.org/evil", "example", "WIKIPEDIA"} {
+		w := serve(handlerWithError(searchHandler), "/search?q=go&project="+project, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("project=%s: status %d, want 400", project, w.Code)
+		}
+	}
+
+	if calls.Load() != 0 {
+		t.Errorf("%d calls to Wikipedia for unknown projects, want none", calls.Load())
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/rs/zerolog"
+)
+
+// WikipediaRandomResponse is the response of the list=random API,
+// see https://www.mediawiki.org/wiki/API:Random
+type WikipediaRandomResponse struct {
+	Query struct {
+		Random []struct {
+			ID    int    `json:"id"`
+			Ns    int    `json:"ns"`
+			Title string `json:"title"`
+		} `json:"random"`
+	} `json:"query"`
+}
+
+// randomArticle returns the page ID of a random article of the lang edition
+func randomArticle(ctx context.Context, lang string) (int, error) {
+	params := url.Values{}
+	params.Set("action", "query")
+	params.Set("list", "random")
+	params.Set("rnnamespace", "0")
+	params.Set("rnlimit", "1")
+
+	var randomResponse WikipediaRandomResponse
+
+	err := wikipediaBreaker.Execute(func() error {
+		return callWikipedia(ctx, lang, params, &randomResponse)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(randomResponse.Query.Random) == 0 {
+		return 0, errors.New("no article in the random API response")
+	}
+
+	return randomResponse.Query.Random[0].ID, nil
+}
+
+// randomHandler redirects to a random Wikipedia article
+func randomHandler(w http.ResponseWriter, r *http.Request) error {
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		lang = config.DefaultLang
+	}
+
+	if !isSupportedLanguage(lang) {
+		return &statusError{
+			http.StatusBadRequest,
+			fmt.Errorf("unsupported language '%s'", lang),
+		}
+	}
+
+	pageID, err := randomArticle(r.Context(), lang)
+	if err != nil {
+		// the visitor only gets an invitation to retry, the cause is in the logs
+		l := zerolog.Ctx(r.Context())
+		l.Warn().Err(err).Msg("unable to fetch a random article")
+
+		return &statusError{
+			http.StatusServiceUnavailable,
+			errors.New("no random article is available right now, please try again in a moment"),
+		}
+	}
+
+	// every visit must pick a new article
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, articleURL(lang, pageID), http.StatusFound)
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// maxRateLimitedIPs is the number of clients tracked, past it the least recently seen one is dropped
+const maxRateLimitedIPs = 10000
+
+// rateLimitPruneInterval is how often the buckets of idle clients are dropped
+const rateLimitPruneInterval = time.Minute
+
+// ipRateLimiter gives each client IP a tokenBucket of burst requests, refilled by one every refill.
+// A full bucket is the same as no bucket, so the buckets of idle clients are dropped, see pruneEvery.
+type ipRateLimiter struct {
+	mu      sync.Mutex
+	burst   int
+	refill  time.Duration
+	buckets map[string]*list.Element
+	// recent orders the rateLimitEntry of each client IP from the most to the least recently seen
+	recent *list.List
+}
+
+type rateLimitEntry struct {
+	ip     string
+	bucket *tokenBucket
+}
+
+func newIPRateLimiter(burst int, refill time.Duration) *ipRateLimiter {
+	return &ipRateLimiter{
+		burst:   burst,
+		refill:  refill,
+		buckets: make(map[string]*list.Element),
+		recent:  list.New(),
+	}
+}
+
+var rateLimiter *ipRateLimiter
+
+// Allow takes a token from the bucket of ip if one is available
+func (l *ipRateLimiter) Allow(ip string) bool {
+	l.mu.Lock()
+
+	elem, ok := l.buckets[ip]
+	if ok {
+		l.recent.MoveToFront(elem)
+	} else {
+		if len(l.buckets) >= maxRateLimitedIPs {
+			l.remove(l.recent.Back())
+		}
+
+		elem = l.recent.PushFront(&rateLimitEntry{ip: ip, bucket: newTokenBucket(l.burst, l.refill)})
+		l.buckets[ip] = elem
+	}
+
+	bucket := elem.Value.(*rateLimitEntry).bucket
+
+	l.mu.Unlock()
+
+	return bucket.Allow()
+}
+
+// remove drops the bucket of a client. Must be called with l.mu held.
+func (l *ipRateLimiter) remove(elem *list.Element) {
+	l.recent.Remove(elem)
+	delete(l.buckets, elem.Value.(*rateLimitEntry).ip)
+}
+
+// prune drops the buckets that are full again
+func (l *ipRateLimiter) prune() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, elem := range l.buckets {
+		if elem.Value.(*rateLimitEntry).bucket.Tokens() >= float64(l.burst) {
+			l.remove(elem)
+		}
+	}
+}
+
+// pruneEvery drops the buckets of idle clients every interval until ctx is done
+func (l *ipRateLimiter) pruneEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.prune()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type rateLimitClient struct {
+	IP     string  `json:"ip"`
+	Tokens float64 `json:"tokens"` // requests allowed right away, a fraction is a token being earned back
+}
+
+// Clients returns the clients that used some of their allowance, the most throttled first
+func (l *ipRateLimiter) Clients() []rateLimitClient {
+	l.prune()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	clients := make([]rateLimitClient, 0, len(l.buckets))
+	for ip, elem := range l.buckets {
+		clients = append(clients, rateLimitClient{
+			IP:     ip,
+			Tokens: math.Floor(elem.Value.(*rateLimitEntry).bucket.Tokens()*100) / 100,
+		})
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].Tokens != clients[j].Tokens {
+			return clients[i].Tokens < clients[j].Tokens
+		}
+
+		return clients[i].IP < clients[j].IP
+	})
+
+	return clients
+}
+
+// maskIP hides the host part of an IP address for the logs: the last byte of an IPv4 address,
+// all but the /48 routing prefix of an IPv6 one
+func maskIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "invalid"
+	}
+
+	bits := 48
+	if addr.Unmap().Is4() {
+		addr, bits = addr.Unmap(), 24
+	}
+
+	prefix, _ := addr.Prefix(bits)
+
+	return prefix.String()
+}
+
+// rateLimit returns a middleware that rejects the requests of a client IP over its allowance
+// with a 429. A nil limiter lets everything through.
+func rateLimit(limiter *ipRateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+
+		retryAfter := strconv.Itoa(int(math.Ceil(limiter.refill.Seconds())))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.Allow(clientIP(r)) {
+				zerolog.Ctx(r.Context()).Debug().
+					Str("client_ip", maskIP(clientIP(r))).
+					Msg("rejecting request over the rate limit")
+
+				w.Header().Set("Retry-After", retryAfter)
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type rateLimitResponse struct {
+	Enabled bool              `json:"enabled"`
+	Burst   int               `json:"burst,omitempty"`
+	Refill  Duration          `json:"refill,omitempty"`
+	Clients []rateLimitClient `json:"clients"`
+}
+
+// rateLimitHandler reports the remaining allowance of the clients that used some of it,
+// to find out why a client is throttled. Unlike the logs, the addresses aren't masked.
+func rateLimitHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		return &statusError{http.StatusMethodNotAllowed, errors.New("method not allowed")}
+	}
+
+	resp := rateLimitResponse{Clients: []rateLimitClient{}}
+
+	if rateLimiter != nil {
+		resp = rateLimitResponse{
+			Enabled: true,
+			Burst:   rateLimiter.burst,
+			Refill:  Duration(rateLimiter.refill),
+			Clients: rateLimiter.Clients(),
+		}
+	}
+
+	buf := getBuffer()
+
+	err := json.NewEncoder(buf).Encode(resp)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
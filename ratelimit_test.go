@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterCap(t *testing.T) {
+	l := newIPRateLimiter(1, time.Hour)
+
+	l.Allow("203.0.113.1")
+	for i := 0; i < maxRateLimitedIPs; i++ {
+		l.Allow("10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256))
+	}
+
+	if len(l.buckets) != maxRateLimitedIPs || l.recent.Len() != maxRateLimitedIPs {
+		t.Fatalf("%d clients tracked, want at most %d", len(l.buckets), maxRateLimitedIPs)
+	}
+
+	// the least recently seen client was dropped, so it's allowed again
+	if _, ok := l.buckets["203.0.113.1"]; ok {
+		t.Error("the least recently seen client is still tracked")
+	}
+
+	// a client seen again is kept over the others
+	l.Allow("10.0.0.0")
+	l.Allow("203.0.113.1")
+
+	if _, ok := l.buckets["10.0.0.0"]; !ok {
+		t.Error("a recently seen client was dropped")
+	}
+
+	if _, ok := l.buckets["10.0.0.1"]; ok {
+		t.Error("the least recently seen client is still tracked")
+	}
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	l := newIPRateLimiter(2, time.Hour)
+
+	l.Allow("203.0.113.1")
+	l.Allow("203.0.113.2")
+	l.buckets["203.0.113.2"].Value.(*rateLimitEntry).bucket.tokens = 2
+
+	l.prune()
+
+	if _, ok := l.buckets["203.0.113.1"]; !ok {
+		t.Error("the bucket of a throttled client was dropped")
+	}
+
+	if _, ok := l.buckets["203.0.113.2"]; ok || l.recent.Len() != 1 {
+		t.Error("the full bucket of an idle client was kept")
+	}
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.AdminToken = "secret"
+	})
+
+	saved := rateLimiter
+	t.Cleanup(func() { rateLimiter = saved })
+
+	header := http.Header{adminTokenHeader: {"secret"}}
+
+	// disabled
+	rateLimiter = nil
+
+	w := serve(requireAdmin(rateLimitHandler), "/admin/ratelimit", header)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if got, want := w.Body.String(), "{\"enabled\":false,\"clients\":[]}\n"; got != want {
+		t.Errorf("disabled: body %s, want %s", got, want)
+	}
+
+	// enabled, the most throttled client first
+	rateLimiter = newIPRateLimiter(3, time.Hour)
+	for i := 0; i < 3; i++ {
+		rateLimiter.Allow("203.0.113.1")
+	}
+	rateLimiter.Allow("2001:db8::1")
+
+	w = serve(requireAdmin(rateLimitHandler), "/admin/ratelimit", header)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %s", ct)
+	}
+
+	var resp struct {
+		Enabled bool   `json:"enabled"`
+		Burst   int    `json:"burst"`
+		Refill  string `json:"refill"`
+		Clients []struct {
+			IP     string  `json:"ip"`
+			Tokens float64 `json:"tokens"`
+		} `json:"clients"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if !resp.Enabled || resp.Burst != 3 || resp.Refill != "1h0m0s" {
+		t.Errorf("enabled %t, burst %d, refill %s, want true, 3, 1h0m0s", resp.Enabled, resp.Burst, resp.Refill)
+	}
+
+	if len(resp.Clients) != 2 || resp.Clients[0].IP != "203.0.113.1" || resp.Clients[0].Tokens != 0 ||
+		resp.Clients[1].IP != "2001:db8::1" || resp.Clients[1].Tokens != 2 {
+		t.Errorf("clients = %+v", resp.Clients)
+	}
+
+	// without the token
+	w = serve(requireAdmin(rateLimitHandler), "/admin/ratelimit", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("without the token: status %d, want 401", w.Code)
+	}
+}
+
+func TestMaskIP(t *testing.T) {
+	tests := []struct {
+		ip, want string
+	}{
+		{"203.0.113.42", "203.0.113.0/24"},
+		{"::ffff:203.0.113.42", "203.0.113.0/24"},
+		{"2001:db8:1234:5678::1", "2001:db8:1234::/48"},
+		{"not an ip", "invalid"},
+	}
+
+	for _, tt := range tests {
+		if got := maskIP(tt.ip); got != tt.want {
+			t.Errorf("maskIP(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
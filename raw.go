@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// rawSearchHandler returns the response of the Wikipedia API for a search as received,
+// pretty-printed, to diagnose how it's mapped. It's only reachable with config.Debug set.
+func rawSearchHandler(w http.ResponseWriter, r *http.Request) error {
+	p, err := parseSearchParams(r.URL.Query(), r.Header.Get("Accept-Language"), config)
+	if err != nil {
+		return err
+	}
+
+	opts := p.options(config.PageSize)
+
+	params := opts.apiParams()
+	if opts.combined() {
+		params = opts.combinedAPIParams()
+	}
+
+	var raw json.RawMessage
+
+	err = wikipediaBreaker.Execute(func() error {
+		return callMediaWiki(r.Context(), opts.Project, opts.Lang, params, &raw)
+	})
+	if errors.Is(err, errCircuitOpen) {
+		return &statusError{http.StatusServiceUnavailable, err}
+	}
+	if err != nil {
+		return err
+	}
+
+	buf := getBuffer()
+
+	err = json.Indent(buf, raw, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
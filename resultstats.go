@@ -0,0 +1,57 @@
+package main
+
+// ResultStats are aggregates of the results on a page. The API doesn't return relevance scores,
+// the length of the articles gives a rough idea of the substance of the results instead.
+type ResultStats struct {
+	Count int `json:"count"`
+
+	MinWordCount int     `json:"min_word_count"`
+	MaxWordCount int     `json:"max_word_count"`
+	AvgWordCount float64 `json:"avg_word_count"`
+
+	// sizes are in bytes of wikitext
+	MinSize int     `json:"min_size"`
+	MaxSize int     `json:"max_size"`
+	AvgSize float64 `json:"avg_size"`
+}
+
+// newResultStats aggregates the word counts and sizes of results, nil when there are none.
+// Word counts are 0 with the combined search, which doesn't return them.
+func newResultStats(results []SearchResult) *ResultStats {
+	if len(results) == 0 {
+		return nil
+	}
+
+	stats := &ResultStats{
+		Count:        len(results),
+		MinWordCount: results[0].WordCount,
+		MaxWordCount: results[0].WordCount,
+		MinSize:      results[0].Size,
+		MaxSize:      results[0].Size,
+	}
+
+	var totalWords, totalSize int
+
+	for _, result := range results {
+		totalWords += result.WordCount
+		totalSize += result.Size
+
+		if result.WordCount < stats.MinWordCount {
+			stats.MinWordCount = result.WordCount
+		}
+		if result.WordCount > stats.MaxWordCount {
+			stats.MaxWordCount = result.WordCount
+		}
+		if result.Size < stats.MinSize {
+			stats.MinSize = result.Size
+		}
+		if result.Size > stats.MaxSize {
+			stats.MaxSize = result.Size
+		}
+	}
+
+	stats.AvgWordCount = float64(totalWords) / float64(len(results))
+	stats.AvgSize = float64(totalSize) / float64(len(results))
+
+	return stats
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestNewResultStats(t *testing.T) {
+	if stats := newResultStats(nil); stats != nil {
+		t.Errorf("newResultStats(nil) = %+v, want nil", stats)
+	}
+
+	single := newResultStats([]SearchResult{{WordCount: 10, Size: 100}})
+	if *single != (ResultStats{1, 10, 10, 10, 100, 100, 100}) {
+		t.Errorf("stats of a single result = %+v", *single)
+	}
+
+	stats := newResultStats([]SearchResult{
+		{WordCount: 30, Size: 300},
+		{WordCount: 10, Size: 100},
+		{WordCount: 20, Size: 250},
+		{WordCount: 0, Size: 50},
+	})
+
+	want := ResultStats{
+		Count:        4,
+		MinWordCount: 0,
+		MaxWordCount: 30,
+		AvgWordCount: 15,
+		MinSize:      50,
+		MaxSize:      300,
+		AvgSize:      175,
+	}
+
+	if *stats != want {
+		t.Errorf("newResultStats() = %+v, want %+v", *stats, want)
+	}
+}
+
+func TestResultStatsInJSON(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go&format=json", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	var search struct {
+		Stats *ResultStats `json:"result_stats"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&search); err != nil {
+		t.Fatal(err)
+	}
+
+	want := ResultStats{3, 10, 30, 20, 100, 300, 200}
+	if search.Stats == nil || *search.Stats != want {
+		t.Errorf("stats = %+v, want %+v", search.Stats, want)
+	}
+}
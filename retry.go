@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// retryBaseDelay is the wait before the first retry, doubled on each following one
+const retryBaseDelay = 200 * time.Millisecond
+
+// maxRetryAfter caps the wait asked by the Retry-After header of a response
+const maxRetryAfter = 10 * time.Second
+
+// errResponseTooLarge is returned when a response of the Wikipedia API exceeds config.WikipediaMaxResponseSize
+var errResponseTooLarge = errors.New("Wikipedia API response too large")
+
+// apiStatusError is a non 200 OK response from the Wikipedia API
+type apiStatusError struct {
+	StatusCode int
+	Header     http.Header
+	dump       string
+}
+
+func (e *apiStatusError) Error() string {
+	return fmt.Sprintf("non 200 OK response from Wikipedia API: %s", e.dump)
+}
+
+// maxLagError is the response of the API when the replication lag of the Wikipedia databases
+// exceeds the maxlag parameter, see https://www.mediawiki.org/wiki/Manual:Maxlag_parameter
+type maxLagError struct {
+	Header http.Header
+}
+
+func (e *maxLagError) Error() string {
+	return fmt.Sprintf("Wikipedia API throttled by maxlag, database lag is %ss", e.Header.Get("X-Database-Lag"))
+}
+
+// retryAfter returns the wait asked by a throttled response in its Retry-After header, see parseRetryAfter
+func retryAfter(err error) (time.Duration, bool) {
+	var header http.Header
+
+	var le *maxLagError
+	var se *apiStatusError
+
+	switch {
+	case errors.As(err, &le):
+		header = le.Header
+	case errors.As(err, &se):
+		header = se.Header
+	default:
+		return 0, false
+	}
+
+	return parseRetryAfter(header.Get("Retry-After"), time.Now())
+}
+
+// parseRetryAfter returns the wait asked by a Retry-After header value at now, capped at maxRetryAfter.
+// The value is either a number of seconds or an HTTP date, a date in the past asks for no wait.
+// It reports false for a missing or invalid value, for which the backoff schedule applies.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	var d time.Duration
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		d = time.Duration(seconds) * time.Second
+	} else {
+		date, err := http.ParseTime(value)
+		if err != nil {
+			return 0, false
+		}
+
+		d = date.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+	}
+
+	if d > maxRetryAfter {
+		d = maxRetryAfter
+	}
+
+	return d, true
+}
+
+// isRetryable reports whether a failed call to Wikipedia is worth another try:
+// transport errors and the responses telling us to come back later.
+// A cancelled or expired context is never retried.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var le *maxLagError
+	if errors.As(err, &le) {
+		return true
+	}
+
+	// the same response would come back
+	if errors.Is(err, errResponseTooLarge) {
+		return false
+	}
+
+	var se *apiStatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= http.StatusInternalServerError
+	}
+
+	return true
+}
+
+// retryDelay returns the wait before the given retry (starting at 0),
+// an exponential backoff with jitter so that concurrent retries spread out
+func retryDelay(retry int) time.Duration {
+	d := retryBaseDelay << retry
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// tokenBucket is the retry budget shared by all the requests.
+// Each retry takes a token and tokens come back at a fixed rate up to max,
+// so that during an outage retries are abandoned instead of piling up on Wikipedia.
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	max    float64
+	refill time.Duration // time to earn one token back
+	last   time.Time
+}
+
+func newTokenBucket(max int, refill time.Duration) *tokenBucket {
+	return &tokenBucket{
+		tokens: float64(max),
+		max:    float64(max),
+		refill: refill,
+		last:   time.Now(),
+	}
+}
+
+var retryBudget *tokenBucket
+
+// Allow takes a token if one is available
+func (b *tokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.earn()
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
+
+// Tokens returns the number of tokens available
+func (b *tokenBucket) Tokens() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.earn()
+
+	return b.tokens
+}
+
+// earn adds the tokens earned since the last call, up to max. Must be called with b.mu held.
+func (b *tokenBucket) earn() {
+	now := time.Now()
+
+	b.tokens += float64(now.Sub(b.last)) / float64(b.refill)
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+
+	b.last = now
+}
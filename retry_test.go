@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// maxLagResponse is the 503 the Wikipedia API answers with when its databases lag behind
+func maxLagResponse(r *http.Request) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Header: http.Header{
+			"Content-Type":        {"application/json; charset=utf-8"},
+			"Mediawiki-Api-Error": {"maxlag"},
+			"Retry-After":         {"0"},
+			"X-Database-Lag":      {"7"},
+		},
+		Body:    io.NopCloser(strings.NewReader(`{"error": {"code": "maxlag", "info": "Waiting for a database server: 7 seconds lagged."}}`)),
+		Request: r,
+	}
+}
+
+func TestMaxLagIsRetried(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.WikipediaMaxLag = 5
+		c.WikipediaMaxRetries = 2
+	})
+
+	var maxlags []string
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		maxlags = append(maxlags, r.URL.Query().Get("maxlag"))
+		if len(maxlags) == 1 {
+			return maxLagResponse(r), nil
+		}
+
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	var logs bytes.Buffer
+	ctx := zerolog.New(&logs).WithContext(context.Background())
+
+	resp, err := searchWikipedia(ctx, searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Query.Search) != 3 {
+		t.Errorf("%d results after the retry, want 3", len(resp.Query.Search))
+	}
+
+	if len(maxlags) != 2 || maxlags[0] != "5" || maxlags[1] != "5" {
+		t.Errorf("maxlag parameters %q, want 2 requests with maxlag=5", maxlags)
+	}
+
+	if !strings.Contains(logs.String(), "throttled by maxlag") || !strings.Contains(logs.String(), `"database_lag":"7"`) {
+		t.Errorf("the throttling isn't logged: %s", logs.String())
+	}
+}
+
+func TestMaxLagGivesUp(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.WikipediaMaxLag = 5
+		c.WikipediaMaxRetries = 1
+	})
+
+	calls := 0
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		calls++
+		return maxLagResponse(r), nil
+	})
+
+	_, err := searchWikipedia(context.Background(), searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20})
+
+	var le *maxLagError
+	if !errors.As(err, &le) {
+		t.Fatalf("error %v, want a maxLagError", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("%d requests, want 2", calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"integer", "3", 3 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"integer over the cap", "3600", maxRetryAfter, true},
+		{"negative integer", "-1", 0, false},
+		{"HTTP-date", now.Add(5 * time.Second).Format(http.TimeFormat), 5 * time.Second, true},
+		{"HTTP-date over the cap", now.Add(time.Hour).Format(http.TimeFormat), maxRetryAfter, true},
+		{"past date", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"missing", "", 0, false},
+		{"garbage", "soon", 0, false},
+		{"fractional seconds", "1.5", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseRetryAfter(%q) = %v, %t, want %v, %t", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+		ok   bool
+	}{
+		{"maxlag", &maxLagError{Header: http.Header{"Retry-After": {"2"}}}, 2 * time.Second, true},
+		{"status", fmt.Errorf("searching: %w", &apiStatusError{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"4"}}}), 4 * time.Second, true},
+		{"garbage header", &apiStatusError{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"later"}}}, 0, false},
+		{"no header", &apiStatusError{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}, 0, false},
+		{"other error", errors.New("connection reset"), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.err)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("retryAfter(%v) = %v, %t, want %v, %t", tt.err, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// schemaDriftLogs samples the schema drift warnings, a change of the API affects every response
+var schemaDriftLogs = newErrorSampler(errorLogInterval)
+
+// searchResponseViolations returns the invariants of a list=search response that resp breaks,
+// along with a result that breaks them. A field renamed or moved by Wikipedia is decoded
+// as its zero value without an error, these checks are the only sign of it.
+func searchResponseViolations(resp *WikipediaSearchResponse) (violations []string, sample *SearchResult) {
+	results := resp.Query.Search
+
+	if len(results) > 0 && resp.Query.SearchInfo.TotalHits == 0 {
+		violations = append(violations, "results without totalhits")
+		sample = &results[0]
+	}
+
+	var titles, pageIDs bool
+
+	for i := range results {
+		if results[i].Title == "" && !titles {
+			titles = true
+			violations = append(violations, "result without a title")
+			sample = &results[i]
+		}
+
+		if results[i].PageID == 0 && !pageIDs {
+			pageIDs = true
+			violations = append(violations, "result without a page ID")
+			sample = &results[i]
+		}
+	}
+
+	return violations, sample
+}
+
+// checkSearchResponse logs a warning when resp breaks the invariants of searchResponseViolations,
+// at most once per errorLogInterval for the same violations
+func checkSearchResponse(l *zerolog.Logger, resp *WikipediaSearchResponse) {
+	violations, sample := searchResponseViolations(resp)
+	if len(violations) == 0 {
+		return
+	}
+
+	ok, suppressed := schemaDriftLogs.allow(l, strings.Join(violations, ", "))
+	if !ok {
+		return
+	}
+
+	e := l.Warn().
+		Strs("violations", violations).
+		Interface("sample", sample)
+	if suppressed > 0 {
+		e = e.Int("suppressed_count", suppressed).
+			Dur("suppressed_window_ms", schemaDriftLogs.interval)
+	}
+
+	e.Msg("unexpected Wikipedia search response, the API may have changed")
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// driftedResponseJSON is a response to a search whose fields were renamed: it parses,
+// but the results come out without totalhits, titles and page IDs
+const driftedResponseJSON = `{
+	"batchcomplete": "",
+	"query": {
+		"info": {"total": 2},
+		"search": [
+			{"ns": 0, "name": "Go (programming language)", "page_id": 25039021, "snippet": "Go is a programming language"},
+			{"ns": 0, "name": "Go (game)", "page_id": 12454, "snippet": "Go is a board game"}
+		]
+	}
+}`
+
+func TestSearchResponseViolations(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"valid", searchResponseJSON, nil},
+		{"no results", `{"query": {"searchinfo": {"totalhits": 0}, "search": []}}`, nil},
+		{"drifted", driftedResponseJSON, []string{"results without totalhits", "result without a title", "result without a page ID"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubSearch(t, tt.body)
+
+			resp, err := searchWikipedia(context.Background(), searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			violations, sample := searchResponseViolations(resp)
+			if strings.Join(violations, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("violations = %q, want %q", violations, tt.want)
+			}
+
+			if (sample != nil) != (len(tt.want) > 0) {
+				t.Errorf("sample = %v with violations %q", sample, violations)
+			}
+		})
+	}
+}
+
+func TestSchemaDriftIsLogged(t *testing.T) {
+	saved := schemaDriftLogs
+	schemaDriftLogs = newErrorSampler(time.Minute)
+	t.Cleanup(func() { schemaDriftLogs = saved })
+
+	stubSearch(t, driftedResponseJSON)
+
+	var logs bytes.Buffer
+	l := zerolog.New(&logs)
+	ctx := l.WithContext(context.Background())
+
+	for i := 0; i < 3; i++ {
+		_, err := searchWikipedia(ctx, searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// sampled: only the first one is logged within the interval
+	if n := strings.Count(logs.String(), "the API may have changed"); n != 1 {
+		t.Errorf("%d schema drift warnings, want 1: %s", n, logs.String())
+	}
+
+	if !strings.Contains(logs.String(), `"level":"warn"`) || !strings.Contains(logs.String(), `"sample":{"ns":0`) {
+		t.Errorf("the warning has no sample: %s", logs.String())
+	}
+
+	// a valid response isn't reported
+	logs.Reset()
+	stubSearch(t, searchResponseJSON)
+
+	if _, err := searchWikipedia(ctx, searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(logs.String(), "the API may have changed") {
+		t.Errorf("a valid response is reported: %s", logs.String())
+	}
+}
+
+func TestCombinedSchemaDriftIsLogged(t *testing.T) {
+	saved := schemaDriftLogs
+	schemaDriftLogs = newErrorSampler(time.Minute)
+	t.Cleanup(func() { schemaDriftLogs = saved })
+
+	// a generator=search response whose fields were renamed
+	stubSearch(t, `{
+		"query": {
+			"info": {"total": 1},
+			"pages": [{"page_id": 12454, "ns": 0, "name": "Go (game)", "index": 1}]
+		}
+	}`)
+
+	var logs bytes.Buffer
+	l := zerolog.New(&logs)
+	ctx := l.WithContext(context.Background())
+
+	_, err := searchWikipediaCombined(ctx, searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(logs.String(), "the API may have changed") {
+		t.Errorf("the drifted combined response isn't reported: %s", logs.String())
+	}
+}
+
+func TestDriftedResponseIsServed(t *testing.T) {
+	stubSearch(t, driftedResponseJSON)
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go", nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d, want 200: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// sampleSearch returns a representative Search value for the template self-check
+func sampleSearch(totalHits int) *Search {
+	results := &WikipediaSearchResponse{}
+	results.Query.SearchInfo.TotalHits = totalHits
+
+	if totalHits > 0 {
+		results.Query.Search = append(results.Query.Search, SearchResult{
+			Title:         "Go (programming language)",
+			PageID:        25039021,
+			Size:          1000,
+			WordCount:     100,
+			Snippet:       `<span class="searchmatch">Go</span> is a programming language`,
+			CleanSnippet:  `<span class="searchmatch">Go</span> is a programming language`,
+			Timestamp:     time.Now(),
+			RedirectTitle: "Golang",
+			FullURL:       "https://en.wikipedia.org/wiki/Go_(programming_language)",
+			Extract:       "Go is a statically typed, compiled high-level programming language.",
+			Coordinates:   &Coordinates{Lat: 37.42, Lon: -122.08},
+			Views:         12345,
+			Thumbnail: &Thumbnail{
+				Source: "https://upload.wikimedia.org/wikipedia/commons/thumb/0/05/Go_Logo_Blue.svg/80px-Go_Logo_Blue.svg.png",
+				Width:  80,
+				Height: 30,
+			},
+		})
+
+		results.Query.InterwikiSearch = map[string][]InterwikiResult{
+			"wikt": {{Title: "go", URL: "https://en.wiktionary.org/wiki/go"}},
+		}
+	}
+
+	totalPages, _ := countPages(totalHits, config.PageSize, config.MaxResultOffset)
+
+	return &Search{
+		Query:         "go",
+		Lang:          config.DefaultLang,
+		Results:       results,
+		TotalPages:    totalPages,
+		NextPage:      2,
+		PageSize:      config.PageSize,
+		Geo:           true,
+		AllNamespaces: true,
+		NamespaceHits: []NamespaceHits{
+			{ID: 0, Name: "Article", Hits: totalHits},
+			{ID: 1, Name: "Talk", Error: "unavailable"},
+		},
+		SnippetLength: config.SnippetLength,
+		Warnings:      []string{"The map links are unavailable."},
+	}
+}
+
+// checkTemplates executes the templates against sample data.
+// Parsing only validates the syntax, while a field renamed in Search or a bad function call
+// is only caught on execution: this surfaces them at startup instead of on the first request.
+func checkTemplates() error {
+	category := &Category{
+		Name:    "Physics",
+		Results: &WikipediaCategoryResponse{},
+	}
+
+	pastResults := sampleSearch(1234)
+	pastResults.PastResults = true
+	pastResults.Results.Query.Search = nil
+
+	checks := []struct {
+		name     string
+		template string
+		data     any
+	}{
+		{"landing page", "index.html", &Search{Lang: config.DefaultLang, Project: defaultProject, Theme: themeSystem}},
+		{"search results", "index.html", sampleSearch(1234)},
+		{"no search results", "index.html", sampleSearch(0)},
+		{"page past the results", "index.html", pastResults},
+		{"results fragment", "results", sampleSearch(1234)},
+		{"category", "category.html", category},
+		{"not found page", "notfound.html", "/missing"},
+		{"bookmarks", "bookmarks.html", []Bookmark{{PageID: 25039021, Title: "Go (programming language)", Lang: "en"}}},
+		{"maintenance page", "maintenance.html", nil},
+		{"comparison", "compare.html", &Comparison{
+			Query: "go",
+			Languages: []LanguageHits{
+				{Lang: "en", Name: "English", Hits: 1234},
+				{Lang: "de", Name: "Deutsch", Error: "unavailable"},
+			},
+			Failed: []string{"de"},
+		}},
+	}
+
+	for _, check := range checks {
+		err := tpl.ExecuteTemplate(io.Discard, check.template, check.data)
+		if err != nil {
+			return fmt.Errorf("template self-check failed on the %s: %w", check.name, err)
+		}
+	}
+
+	return checkQueryEscaping()
+}
+
+// maliciousQuery tries to break out of the attributes and elements the query is echoed in
+const maliciousQuery = `"><script>alert(1)</script><a x='`
+
+// checkQueryEscaping renders the pages that echo user input with maliciousQuery,
+// failing if it comes out as markup, e.g. after an edit of a template or of one of its helpers
+func checkQueryEscaping() error {
+	search := sampleSearch(1234)
+	search.Query = maliciousQuery
+	search.Exclude = []string{maliciousQuery}
+	search.Refine = []string{maliciousQuery}
+
+	pages := []struct {
+		name   string
+		render func(w io.Writer) error
+	}{
+		{"search results", func(w io.Writer) error {
+			return tpl.ExecuteTemplate(w, "index.html", search)
+		}},
+		{"fallback page", func(w io.Writer) error {
+			return fallbackTemplate.Execute(w, search)
+		}},
+		{"category", func(w io.Writer) error {
+			return tpl.ExecuteTemplate(w, "category.html", &Category{Name: maliciousQuery, Results: &WikipediaCategoryResponse{}})
+		}},
+		{"comparison", func(w io.Writer) error {
+			return tpl.ExecuteTemplate(w, "compare.html", &Comparison{
+				Query:     maliciousQuery,
+				Languages: []LanguageHits{{Lang: "en", Name: "English", Hits: 1234}},
+			})
+		}},
+	}
+
+	for _, page := range pages {
+		var buf bytes.Buffer
+
+		err := page.render(&buf)
+		if err != nil {
+			return fmt.Errorf("template self-check failed on the %s with a malicious query: %w", page.name, err)
+		}
+
+		if strings.Contains(buf.String(), "<script>alert(1)") {
+			return fmt.Errorf("template self-check failed: the query isn't escaped on the %s", page.name)
+		}
+	}
+
+	return nil
+}
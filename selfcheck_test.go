@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withTemplates parses the templates from a copy of the template files where edit has been applied
+// to index.html, and uses them for the duration of the test
+func withTemplates(t *testing.T, edit func(index string) string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for _, name := range templateFiles {
+		data, err := fs.ReadFile(embedded, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if name == "index.html" {
+			data = []byte(edit(string(data)))
+		}
+
+		err = os.WriteFile(filepath.Join(dir, name), data, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parsed, err := loadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := tpl
+	t.Cleanup(func() { tpl = saved })
+
+	tpl = parsed
+}
+
+func TestCheckTemplates(t *testing.T) {
+	if err := checkTemplates(); err != nil {
+		t.Errorf("the templates fail their self-check: %v", err)
+	}
+}
+
+func TestCheckTemplatesBadField(t *testing.T) {
+	withTemplates(t, func(index string) string {
+		return strings.Replace(index, "{{ .TotalPages }}", "{{ .TotlaPages }}", 1)
+	})
+
+	err := checkTemplates()
+	if err == nil || !strings.Contains(err.Error(), "TotlaPages") {
+		t.Errorf("checkTemplates() = %v, want an error naming the misspelled field", err)
+	}
+}
+
+func TestMissingMapKeyIsError(t *testing.T) {
+	tmpl, err := loadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err = tmpl.New("missing_key").Parse(`{{ .totl }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tmpl.Execute(io.Discard, map[string]int{"total": 1})
+	if err == nil || !strings.Contains(err.Error(), "totl") {
+		t.Errorf("executing a missing map key = %v, want an error naming it", err)
+	}
+}
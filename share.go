@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxShareTokenLen is well above what a real search encodes to, longer tokens are rejected outright
+const maxShareTokenLen = 2048
+
+var errInvalidShareToken = errors.New("invalid share token")
+
+// shareState is the search state encoded in a share token, every parameter that affects the results.
+// Field names are kept short as they end up in the URL, and the defaults are left out
+// so that the tokens of plain searches stay short and unchanged.
+type shareState struct {
+	Query string `json:"q"`
+	Lang  string `json:"l,omitempty"`
+	Page  int    `json:"p,omitempty"`
+
+	// Project is left out for Wikipedia, which keeps the tokens of the other searches unchanged
+	Project string `json:"pr,omitempty"`
+
+	PageID           int      `json:"id,omitempty"`
+	Profile          string   `json:"pf,omitempty"`
+	Order            string   `json:"o,omitempty"`
+	Exclude          []string `json:"ex,omitempty"`
+	Refine           []string `json:"r,omitempty"`
+	AllNamespaces    bool     `json:"ns,omitempty"`
+	Geo              bool     `json:"g,omitempty"`
+	ResolveRedirects bool     `json:"rr,omitempty"`
+	Interwiki        bool     `json:"iw,omitempty"`
+	Safe             bool     `json:"s,omitempty"`
+	Extracts         bool     `json:"x,omitempty"`
+}
+
+// encodeShareToken encodes the state into an opaque base64url token
+func encodeShareToken(state shareState) string {
+	// marshaling a struct of strings and ints can't fail
+	data, _ := json.Marshal(state)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeShareToken decodes and validates a token produced by encodeShareToken
+func decodeShareToken(token string) (shareState, error) {
+	var state shareState
+
+	if token == "" || len(token) > maxShareTokenLen {
+		return state, errInvalidShareToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return state, errInvalidShareToken
+	}
+
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(&state)
+	if err != nil {
+		return state, errInvalidShareToken
+	}
+
+	if (strings.TrimSpace(state.Query) == "" && state.PageID <= 0) || state.Page < 0 || (state.Lang != "" && !isSupportedLanguage(state.Lang)) {
+		return state, errInvalidShareToken
+	}
+
+	if state.Project != "" && !isProject(state.Project) {
+		return state, errInvalidShareToken
+	}
+
+	if state.Page == 0 {
+		state.Page = 1
+	}
+
+	return state, nil
+}
+
+// values returns the /search query parameters matching the state.
+// They're those of the page links of the search, along with the language
+// which mustn't depend on the Accept-Language header of whoever follows the link.
+func (s shareState) values() url.Values {
+	search := &Search{
+		Query:            s.Query,
+		Lang:             s.Lang,
+		Project:          s.Project,
+		Profile:          s.Profile,
+		Order:            s.Order,
+		Exclude:          s.Exclude,
+		Refine:           s.Refine,
+		AllNamespaces:    s.AllNamespaces,
+		Geo:              s.Geo,
+		ResolveRedirects: s.ResolveRedirects,
+		Interwiki:        s.Interwiki,
+		Safe:             s.Safe,
+		Extracts:         s.Extracts,
+	}
+
+	v := search.pageValues(s.Page)
+	if s.Lang != "" {
+		v.Set("lang", s.Lang)
+	}
+
+	if s.PageID > 0 {
+		v.Set("pageid", strconv.Itoa(s.PageID))
+	}
+
+	return v
+}
+
+// ShareToken returns the token of the /s/ deep link to the current page of results
+func (s *Search) ShareToken() string {
+	state := shareState{
+		Query:            s.Query,
+		Lang:             s.Lang,
+		Page:             s.CurrentPage(),
+		PageID:           s.PageID,
+		Order:            s.Order,
+		Exclude:          s.Exclude,
+		Refine:           s.Refine,
+		AllNamespaces:    s.AllNamespaces,
+		Geo:              s.Geo,
+		ResolveRedirects: s.ResolveRedirects,
+		Interwiki:        s.Interwiki,
+		Safe:             s.Safe,
+		Extracts:         s.Extracts,
+	}
+
+	if !s.IsWikipedia() {
+		state.Project = s.Project
+	}
+
+	if s.Profile != defaultProfile {
+		state.Profile = s.Profile
+	}
+
+	return encodeShareToken(state)
+}
+
+// shareHandler runs the search encoded in a /s/<token> deep link
+func shareHandler(w http.ResponseWriter, r *http.Request) error {
+	state, err := decodeShareToken(strings.TrimPrefix(r.URL.Path, "/s/"))
+	if err != nil {
+		return &statusError{http.StatusBadRequest, err}
+	}
+
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = state.values().Encode()
+
+	return searchHandler(w, r)
+}
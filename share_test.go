@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestShareTokenRoundTrip(t *testing.T) {
+	// without a cache, the shared link searches Wikipedia again
+	withConfig(t, func(c *Config) {
+		c.Features.Cache = false
+	})
+
+	var mu sync.Mutex
+	var upstream []string
+
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		upstream = append(upstream, r.URL.String())
+		mu.Unlock()
+
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	targets := []string{
+		"/search?q=go&format=json",
+		"/search?q=go&page=3&lang=fr&format=json",
+		"/search?q=proverb&project=wikiquote&lang=de&format=json",
+		"/search?q=go&exclude=film,album&add=language&add=google&order=wordcount_desc&profile=classic&format=json",
+		"/search?q=go&safe=1&resolve_redirects=1&interwiki=1&extracts=1&geo=1&all_namespaces=1&format=json",
+	}
+
+	for _, target := range targets {
+		mu.Lock()
+		upstream = nil
+		mu.Unlock()
+
+		w := serve(handlerWithError(searchHandler), target, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d: %s", target, w.Code, w.Body)
+		}
+
+		var search Search
+		if err := json.Unmarshal(w.Body.Bytes(), &search); err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+
+		mu.Lock()
+		original := upstream
+		upstream = nil
+		mu.Unlock()
+
+		shared := serve(handlerWithError(shareHandler), "/s/"+search.ShareToken()+"?format=json", nil)
+		if shared.Code != http.StatusOK {
+			t.Fatalf("shared link of %s: status %d: %s", target, shared.Code, shared.Body)
+		}
+
+		mu.Lock()
+		replayed := upstream
+		mu.Unlock()
+
+		// the extras are fetched concurrently
+		sort.Strings(original)
+		sort.Strings(replayed)
+
+		if !reflect.DeepEqual(replayed, original) {
+			t.Errorf("shared link of %s searches\n%v\nwant\n%v", target, replayed, original)
+		}
+	}
+}
+
+func TestDecodeShareToken(t *testing.T) {
+	state := shareState{
+		Query:   "go",
+		Lang:    "fr",
+		Page:    2,
+		Exclude: []string{"film"},
+		Refine:  []string{"google"},
+		Order:   "title",
+		Safe:    true,
+	}
+
+	got, err := decodeShareToken(encodeShareToken(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, state) {
+		t.Errorf("decodeShareToken() = %+v, want %+v", got, state)
+	}
+}
+
+func TestShareStateValuesLookUpPageID(t *testing.T) {
+	v := shareState{Lang: "en", PageID: 25039021, Page: 1}.values()
+
+	if got := v.Get("pageid"); got != "25039021" {
+		t.Errorf("pageid = %q, want 25039021", got)
+	}
+}
+
+func TestDecodeShareTokenRejectsMalformedTokens(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tokens := map[string]string{
+		"empty":            "",
+		"not base64":       "!!!",
+		"not JSON":         encode("go"),
+		"unknown field":    encode(`{"q":"go","x2":1}`),
+		"no query":         encode(`{"p":2}`),
+		"negative page":    encode(`{"q":"go","p":-1}`),
+		"unknown language": encode(`{"q":"go","l":"zz"}`),
+		"unknown project":  encode(`{"q":"go","pr":"wikinews2"}`),
+		"too long":         strings.Repeat("a", maxShareTokenLen+1),
+	}
+
+	for name, token := range tokens {
+		if _, err := decodeShareToken(token); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+
+		w := serve(handlerWithError(shareHandler), "/s/"+token, nil)
+		if w.Code != http.StatusBadRequest && token != "" {
+			t.Errorf("%s: status %d, want 400", name, w.Code)
+		}
+	}
+}
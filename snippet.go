@@ -0,0 +1,48 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// wikipediaHighlightClass is the class of the elements wrapping the matches in Wikipedia's snippets
+const wikipediaHighlightClass = "searchmatch"
+
+var (
+	// wikiTemplatePattern matches a template call like {{cite web|...}}, nested ones are undone from the inside out
+	wikiTemplatePattern = regexp.MustCompile(`\{\{[^{}<]*\}\}`)
+	// wikiLinkPattern matches an internal link like [[Target]] or [[Target|label]], capturing the displayed text,
+	// which may contain highlights. The closing brackets are missing when the snippet ends within the link.
+	wikiLinkPattern = regexp.MustCompile(`\[\[(?:[^\[\]|<]*\|)?([^\[\]]*)(?:\]\]|$)`)
+	// wikiHeadingPattern matches the equal signs around a section heading like == History ==
+	wikiHeadingPattern = regexp.MustCompile(`={2,}`)
+	whitespacePattern  = regexp.MustCompile(`\s+`)
+
+	// wikiMarkupRemnants are the pieces of markup left over after the patterns above, e.g. from a link cut by the snippet
+	wikiMarkupRemnants = strings.NewReplacer("[[", "", "]]", "", "{{", "", "}}", "", "'''", "", "''", "")
+)
+
+// cleanSnippet tidies the HTML snippet of a search result for reading: the wikitext that leaks into
+// snippets (templates, links, bold and italic quotes, headings) is removed or replaced by its text,
+// and runs of whitespace, including line breaks, are collapsed into a single space.
+// The highlights are kept, with their class renamed to config.HighlightClass.
+func cleanSnippet(s string) string {
+	for {
+		cleaned := wikiTemplatePattern.ReplaceAllString(s, "")
+		if cleaned == s {
+			break
+		}
+		s = cleaned
+	}
+
+	s = wikiLinkPattern.ReplaceAllString(s, "$1")
+	s = wikiMarkupRemnants.Replace(s)
+	s = wikiHeadingPattern.ReplaceAllString(s, " ")
+	s = whitespacePattern.ReplaceAllString(s, " ")
+
+	if config.HighlightClass != wikipediaHighlightClass {
+		s = strings.ReplaceAll(s, `class="`+wikipediaHighlightClass+`"`, `class="`+config.HighlightClass+`"`)
+	}
+
+	return strings.TrimSpace(s)
+}
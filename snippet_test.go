@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCleanSnippet(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.HighlightClass = wikipediaHighlightClass
+	})
+
+	tests := []struct {
+		name, in, want string
+	}{
+		{"clean", "Go is a programming language", "Go is a programming language"},
+		{"whitespace", "  Go  is\n\ta  language  ", "Go is a language"},
+		{"template", "Go{{cite web|url=https://go.dev}} is a language", "Go is a language"},
+		{"nested templates", "Go {{efn|a {{nowrap|b}} c}}is fun", "Go is fun"},
+		{"link", "designed at [[Google]]", "designed at Google"},
+		{"piped link", "a [[Programming language|language]] by Google", "a language by Google"},
+		{"link cut by the snippet", "designed at [[Google LLC|Goo", "designed at Goo"},
+		{"highlight in a link", `[[Go (language)|<span class="searchmatch">Go</span>]] is`,
+			`<span class="searchmatch">Go</span> is`},
+		{"bold and italic", "'''Go''' is ''fast''", "Go is fast"},
+		{"heading", "== History == Go was designed", "History Go was designed"},
+		{"remnants", "Go]] is {{ a language", "Go is a language"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanSnippet(tt.in); got != tt.want {
+				t.Errorf("cleanSnippet(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippetRawAndClean(t *testing.T) {
+	stubSearch(t, `{"query": {"searchinfo": {"totalhits": 1}, "search": [
+		{"ns": 0, "title": "Go", "pageid": 1, "snippet": "'''Go''' is  a [[Programming language|language]]{{cn}}"}
+	]}}`)
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go&format=json", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	var search struct {
+		Results struct {
+			Query struct {
+				Search []struct {
+					Snippet      string `json:"snippet"`
+					CleanSnippet string `json:"clean_snippet"`
+				} `json:"search"`
+			} `json:"query"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&search); err != nil {
+		t.Fatal(err)
+	}
+
+	result := search.Results.Query.Search[0]
+
+	if result.Snippet != "'''Go''' is  a [[Programming language|language]]{{cn}}" {
+		t.Errorf("snippet = %q, want the raw snippet", result.Snippet)
+	}
+
+	if result.CleanSnippet != "Go is a language" {
+		t.Errorf("clean_snippet = %q, want the cleaned snippet", result.CleanSnippet)
+	}
+}
+
+func TestHighlightClass(t *testing.T) {
+	const snippet = `<span class="searchmatch">Go</span> is a <span class="searchmatch">language</span>`
+
+	tests := []struct {
+		class, want string
+	}{
+		{wikipediaHighlightClass, snippet},
+		{"hl", `<span class="hl">Go</span> is a <span class="hl">language</span>`},
+	}
+
+	for _, tt := range tests {
+		withConfig(t, func(c *Config) {
+			c.HighlightClass = tt.class
+		})
+
+		if got := cleanSnippet(snippet); got != tt.want {
+			t.Errorf("HighlightClass=%s: cleanSnippet() = %q, want %q", tt.class, got, tt.want)
+		}
+	}
+
+	stubSearch(t, searchResponseJSON)
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if body := w.Body.String(); strings.Contains(body, `class="searchmatch"`) || !strings.Contains(body, `class="hl"`) {
+		t.Error("the page keeps Wikipedia's highlight class")
+	}
+}
+
+func TestInvalidHighlightClass(t *testing.T) {
+	for _, class := range []string{"", "hl\" onmouseover=\"alert(1)", "two classes"} {
+		cfg := defaultConfig()
+		cfg.HighlightClass = class
+
+		if err := cfg.validate(); err == nil {
+			t.Errorf("HighlightClass=%q passes validation", class)
+		}
+	}
+}
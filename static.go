@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// serveAssets returns a file server for the assets in fsys that only serves the files with one of
+// the extensions (e.g. ".css"), so that a file dropped by mistake in the directory isn't exposed.
+// Dotfiles and directory listings are never served, and neither is anything outside of fsys.
+func serveAssets(fsys fs.FS, extensions []string) http.Handler {
+	allowed := make(map[string]bool, len(extensions))
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+
+		allowed[ext] = true
+	}
+
+	fileServer := http.FileServer(http.FS(fsys))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the path is cleaned by the mux and fs.FS rejects "..", this also catches "." segments
+		for _, segment := range strings.Split(r.URL.Path, "/") {
+			if strings.HasPrefix(segment, ".") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		// directories have no extension, which also prevents their listing
+		if !allowed[strings.ToLower(path.Ext(r.URL.Path))] {
+			http.NotFound(w, r)
+			return
+		}
+
+		fileServer.ServeHTTP(w, r)
+	})
+}
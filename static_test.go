@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestServeAssets(t *testing.T) {
+	fsys := fstest.MapFS{
+		"style.css":        {Data: []byte("body {}")},
+		"app.js":           {Data: []byte("void 0")},
+		"img/logo.PNG":     {Data: []byte("\x89PNG")},
+		"notes.txt":        {Data: []byte("todo")},
+		"config.yaml":      {Data: []byte("admin_token: s3cret")},
+		".env":             {Data: []byte("SECRET=1")},
+		".hidden/evil.css": {Data: []byte("body {}")},
+		"fonts.css/a.css":  {Data: []byte("body {}")},
+	}
+
+	h := http.StripPrefix("/assets/", serveAssets(fsys, []string{".css", "js", " .PNG "}))
+
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"/assets/style.css", http.StatusOK},
+		{"/assets/app.js", http.StatusOK},
+		{"/assets/img/logo.PNG", http.StatusOK},
+		{"/assets/notes.txt", http.StatusNotFound},
+		{"/assets/config.yaml", http.StatusNotFound},
+		{"/assets/missing.css", http.StatusNotFound},
+		{"/assets/.env", http.StatusNotFound},
+		{"/assets/.hidden/evil.css", http.StatusNotFound},
+		{"/assets/img/", http.StatusNotFound},
+		{"/assets/fonts.css/", http.StatusNotFound},
+		{"/assets/../main.go", http.StatusNotFound},
+		{"/assets/../assets/style.css", http.StatusNotFound},
+		{"/assets/%2e%2e/%2e%2e/etc/passwd.css", http.StatusNotFound},
+		{"/assets/img/..%2f..%2fstyle.css", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+		if w.Code != tt.want {
+			t.Errorf("GET %s: status %d, want %d", tt.target, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// appStats holds the counters exposed on /stats
+type appStats struct {
+	wikipediaRequests      atomic.Int64
+	wikipediaBytesReceived atomic.Int64
+}
+
+var stats appStats
+
+type statsResponse struct {
+	WikipediaRequests      int64  `json:"wikipedia_requests"`
+	WikipediaBytesReceived int64  `json:"wikipedia_bytes_received"`
+	CircuitBreakerState    string `json:"circuit_breaker_state"`
+}
+
+func statsHandler(w http.ResponseWriter, r *http.Request) error {
+	resp := statsResponse{
+		WikipediaRequests:      stats.wikipediaRequests.Load(),
+		WikipediaBytesReceived: stats.wikipediaBytesReceived.Load(),
+		CircuitBreakerState:    wikipediaBreaker.State().String(),
+	}
+
+	buf := getBuffer()
+
+	err := json.NewEncoder(buf).Encode(resp)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// stripTags removes the HTML elements from s and decodes its entities,
+// turning a search snippet into plain text
+func stripTags(s string) string {
+	var b strings.Builder
+
+	for {
+		start := strings.IndexByte(s, '<')
+		if start < 0 {
+			b.WriteString(s)
+			break
+		}
+
+		b.WriteString(s[:start])
+
+		end := strings.IndexByte(s[start:], '>')
+		if end < 0 {
+			break
+		}
+
+		s = s[start+end+1:]
+	}
+
+	return html.UnescapeString(b.String())
+}
+
+// plainSnippet returns the snippet of r as plain text. CleanSnippet is only filled
+// by searchWikipedia, the results of the other searches fall back to Snippet.
+func (r SearchResult) plainSnippet() string {
+	snippet := r.CleanSnippet
+	if snippet == "" {
+		snippet = r.Snippet
+	}
+
+	return strings.TrimSpace(stripTags(snippet))
+}
+
+// writeText renders the results of a search as plain text for command line clients,
+// one numbered entry per result with its title, URL and snippet aligned under each other
+func writeText(w io.Writer, s *Search) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+
+	if summary := s.RangeSummary(); summary != "" {
+		fmt.Fprintf(tw, "%s\n\n", summary)
+	} else {
+		fmt.Fprintf(tw, "No results found for your query: %s\n", s.Query)
+	}
+
+	first := s.FirstResult()
+
+	for i, result := range s.Results.Query.Search {
+		fmt.Fprintf(tw, "%d.\t%s\n", first+i, result.Title)
+		fmt.Fprintf(tw, "\t%s\n", result.URL())
+
+		if snippet := result.plainSnippet(); snippet != "" {
+			fmt.Fprintf(tw, "\t%s\n", snippet)
+		}
+
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSearchTextFormat(t *testing.T) {
+	stubSearch(t, searchResponseJSON)
+
+	w := serve(handlerWithError(searchHandler), "/search?q=go&format=text", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", got)
+	}
+
+	want := "Showing 1–20 of 1,234 results\n" +
+		"\n" +
+		"1. Go (programming language)\n" +
+		"   https://en.wikipedia.org?curid=25039021\n" +
+		"   Go is a programming language\n" +
+		"\n" +
+		"2. Go (game)\n" +
+		"   https://en.wikipedia.org?curid=12454\n" +
+		"   Go is a board game\n" +
+		"\n" +
+		"3. gopher\n" +
+		"   https://en.wikipedia.org?curid=99\n" +
+		"   A burrowing rodent\n" +
+		"\n"
+
+	if got := w.Body.String(); got != want {
+		t.Errorf("plain text results:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTextNoResults(t *testing.T) {
+	var b strings.Builder
+
+	s := pageOfSearch(0, 1, 20)
+	s.Query = "xyzzy"
+
+	if err := writeText(&b, s); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := b.String(); got != "No results found for your query: xyzzy\n" {
+		t.Errorf("writeText() = %q", got)
+	}
+}
+
+func TestWriteTextRawSnippet(t *testing.T) {
+	var b strings.Builder
+
+	s := pageOfSearch(1, 1, 20)
+	s.Results.Query.Search = []SearchResult{{
+		Title:   "Go (game)",
+		PageID:  12454,
+		Snippet: `<span class="searchmatch">Go</span> is a board game`,
+	}}
+
+	if err := writeText(&b, s); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(b.String(), "   Go is a board game\n") {
+		t.Errorf("writeText() = %q, want the snippet without its tags", b.String())
+	}
+}
+
+func TestStripTags(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{`<span class="searchmatch">Go</span> is &quot;fun&quot; &amp; fast`, `Go is "fun" & fast`},
+		{"a <b>bold</b> move", "a bold move"},
+		{"broken <span", "broken "},
+		{"1 &lt; 2", "1 < 2"},
+	}
+
+	for _, tt := range tests {
+		if got := stripTags(tt.in); got != tt.want {
+			t.Errorf("stripTags(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	themeCookie = "theme"
+
+	// themeSystem follows the color scheme of the operating system
+	themeSystem = "system"
+)
+
+// themes are the accepted values of the theme parameter and cookie
+var themes = []string{themeSystem, "light", "dark"}
+
+func isTheme(theme string) bool {
+	for _, t := range themes {
+		if t == theme {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestTheme returns the theme to render the page with.
+// A valid theme query parameter is remembered in a cookie for the next pages,
+// otherwise the cookie is used, and themeSystem when neither is set.
+func requestTheme(w http.ResponseWriter, r *http.Request) string {
+	if theme := r.URL.Query().Get("theme"); theme != "" {
+		if isTheme(theme) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     themeCookie,
+				Value:    theme,
+				Path:     localURL("/"),
+				MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+
+			return theme
+		}
+
+		zerolog.Ctx(r.Context()).Debug().
+			Str("theme", theme).
+			Msg("ignoring unknown theme")
+	}
+
+	if cookie, err := r.Cookie(themeCookie); err == nil && isTheme(cookie.Value) {
+		return cookie.Value
+	}
+
+	return themeSystem
+}
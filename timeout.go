@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// requestTimeout returns a middleware that bounds the time spent handling a request.
+// Callers may ask for a shorter or longer deadline with the X-Request-Timeout header
+// (e.g. "X-Request-Timeout: 5s"), capped at max. Invalid values are ignored and def is used.
+func requestTimeout(def, max time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := def
+
+			if v := r.Header.Get("X-Request-Timeout"); v != "" {
+				d, err := time.ParseDuration(v)
+				if err != nil || d <= 0 {
+					zerolog.Ctx(r.Context()).Debug().
+						Str("x_request_timeout", v).
+						Msg("ignoring invalid X-Request-Timeout header")
+				} else {
+					timeout = d
+				}
+			}
+
+			if timeout > max {
+				timeout = max
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	const def, max = 30 * time.Second, time.Minute
+
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{"no header", "", def},
+		{"shorter", "5s", 5 * time.Second},
+		{"longer", "45s", 45 * time.Second},
+		{"excessive", "10m", max},
+		{"invalid", "soon", def},
+		{"negative", "-5s", def},
+		{"zero", "0s", def},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got time.Duration
+
+			h := requestTimeout(def, max)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				deadline, ok := r.Context().Deadline()
+				if !ok {
+					t.Fatal("no deadline set on the request context")
+				}
+
+				got = time.Until(deadline)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+			if tt.header != "" {
+				r.Header.Set("X-Request-Timeout", tt.header)
+			}
+
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			// the deadline is measured a moment after it's set
+			if got > tt.want || got < tt.want-time.Second {
+				t.Errorf("deadline in %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiredRequestServesCacheHit(t *testing.T) {
+	calls := stubSearch(t, searchResponseJSON)
+
+	h := requestTimeout(30*time.Second, time.Minute)(handlerWithError(searchHandler))
+
+	if w := serve(h, "/search?q=go", nil); w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	w := serve(h, "/search?q=go", http.Header{"X-Request-Timeout": {"1ns"}})
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d for a cache hit past the deadline, want 200: %s", w.Code, w.Body)
+	}
+
+	if got := w.Header().Get("X-Cache"); got != cacheHit {
+		t.Errorf("X-Cache = %q, want %s", got, cacheHit)
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("%d calls to Wikipedia, want 1", calls.Load())
+	}
+}
+
+// slowWikipedia starts a server that answers after delay, or when the client goes away,
+// and sends the requests to Wikipedia to it for the duration of the test
+func slowWikipedia(t *testing.T, delay time.Duration) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(searchResponseJSON))
+	}))
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		r.URL.Scheme, r.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(r)
+	})
+}
+
+// withWikipediaTimeout sets the timeout of the requests to Wikipedia for the duration of the test
+func withWikipediaTimeout(t *testing.T, timeout time.Duration) {
+	saved := HTTPClient.Timeout
+	t.Cleanup(func() { HTTPClient.Timeout = saved })
+
+	HTTPClient.Timeout = timeout
+}
+
+func TestWikipediaTimeout(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.WikipediaMaxRetries = 0
+	})
+	withWikipediaTimeout(t, 50*time.Millisecond)
+	slowWikipedia(t, 5*time.Second)
+
+	start := time.Now()
+
+	_, err := searchWikipedia(context.Background(), searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20})
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("error %v, want a timeout", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the request gave up after %v, want about 50ms", elapsed)
+	}
+}
+
+func TestRequestDeadlineBeforeWikipediaTimeout(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.WikipediaMaxRetries = 0
+	})
+	withWikipediaTimeout(t, 5*time.Second)
+	slowWikipedia(t, 5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	_, err := searchWikipedia(ctx, searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v, want the request deadline", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the request gave up after %v, want about 50ms", elapsed)
+	}
+}
+
+func TestSlowWikipediaWithinTimeout(t *testing.T) {
+	withWikipediaTimeout(t, 5*time.Second)
+	slowWikipedia(t, 20*time.Millisecond)
+
+	resp, err := searchWikipedia(context.Background(), searchOptions{Query: "go", Project: defaultProject, Lang: "en", Limit: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Query.Search) != 3 {
+		t.Errorf("%d results, want 3", len(resp.Query.Search))
+	}
+}
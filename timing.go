@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// serverTiming collects the metrics of the Server-Timing response header,
+// which shows how long each phase of a request took in the browser devtools.
+// See https://www.w3.org/TR/server-timing/
+type serverTiming struct {
+	start   time.Time
+	metrics []string
+}
+
+func newServerTiming() *serverTiming {
+	return &serverTiming{start: time.Now()}
+}
+
+// Add records the time elapsed since start as the metric name
+func (t *serverTiming) Add(name, desc string, start time.Time) {
+	metric := name
+	if desc != "" {
+		metric += fmt.Sprintf("; desc=%q", desc)
+	}
+
+	t.metrics = append(t.metrics, metric+"; dur="+milliseconds(time.Since(start)))
+}
+
+// Header returns the value of the Server-Timing header, ending with the total time so far
+func (t *serverTiming) Header() string {
+	return strings.Join(append(t.metrics, "total; dur="+milliseconds(time.Since(t.start))), ", ")
+}
+
+func milliseconds(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	"github.com/rs/xid"
+	"github.com/rs/zerolog"
+)
+
+// newSpanID generates the ID of a span, see startSpan.
+// It's a variable so that tests can swap it for a deterministic generator.
+var newSpanID = func() string {
+	return xid.New().String()
+}
+
+// startSpan returns a copy of ctx whose logger adds a new span_id, and the name of the span,
+// to the fields of the request logger. It's called for each upstream call, so that its logs
+// (retries, response, failures) can be told apart from those of the other calls of the request,
+// which share the correlation_id, e.g. in a fan-out.
+func startSpan(ctx context.Context, name string) context.Context {
+	l := zerolog.Ctx(ctx).With().
+		Str("span_id", newSpanID()).
+		Str("span", name).
+		Logger()
+
+	return l.WithContext(ctx)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSubCallLogsCarryBothIDs(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.Pageviews = true
+		c.WikipediaMaxRetries = 1
+	})
+
+	var spans atomic.Int64
+	previous := newSpanID
+	newSpanID = func() string { return "span-" + strconv.FormatInt(spans.Add(1), 10) }
+	t.Cleanup(func() { newSpanID = previous })
+
+	var searches atomic.Int64
+	stubWikipedia(t, func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == "wikimedia.org" {
+			return jsonResponse(r, `{"items": [{"views": 10}]}`), nil
+		}
+
+		// the first try fails, its retry belongs to the same span
+		if searches.Add(1) == 1 {
+			return &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Header:     http.Header{"Retry-After": {"0"}},
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    r,
+			}, nil
+		}
+
+		return jsonResponse(r, searchResponseJSON), nil
+	})
+
+	var logs bytes.Buffer
+	l := zerolog.New(&logs).With().Str("correlation_id", "request-1").Logger()
+
+	r := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+	r = r.WithContext(l.WithContext(r.Context()))
+
+	w := httptest.NewRecorder()
+	handlerWithError(searchHandler).ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	// the spans of the messages logged by the sub-calls
+	spansOf := make(map[string][]string)
+
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+
+		if event["correlation_id"] != "request-1" {
+			t.Errorf("log without the correlation ID: %s", line)
+		}
+
+		message, _ := event["message"].(string)
+		spanID, _ := event["span_id"].(string)
+
+		switch message {
+		case "retrying the Wikipedia request", "received Wikipedia API response", "received Wikimedia pageviews response":
+			if spanID == "" || event["span"] == nil {
+				t.Errorf("sub-call log without a span: %s", line)
+			}
+			spansOf[message] = append(spansOf[message], spanID)
+		default:
+			if spanID != "" {
+				t.Errorf("log of the request with the span of a sub-call: %s", line)
+			}
+		}
+	}
+
+	retries, responses, pageviews := spansOf["retrying the Wikipedia request"], spansOf["received Wikipedia API response"], spansOf["received Wikimedia pageviews response"]
+	if len(retries) != 1 || len(responses) != 1 || retries[0] != responses[0] {
+		t.Fatalf("the retry (%q) isn't in the span of its call (%q)", retries, responses)
+	}
+
+	// one span per call, the pageviews of each result included
+	seen := map[string]bool{responses[0]: true}
+	for _, spanID := range pageviews {
+		if seen[spanID] {
+			t.Errorf("span %s shared by two calls", spanID)
+		}
+		seen[spanID] = true
+	}
+
+	if len(pageviews) != 3 {
+		t.Errorf("%d pageviews logs, want one per result", len(pageviews))
+	}
+}
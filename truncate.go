@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxEntityLen is the longest HTML entity (e.g. "&thetasym;") that truncate treats as a single character
+const maxEntityLen = 10
+
+// truncate shortens the HTML snippet s to at most n visible characters, cutting on a word boundary
+// and appending an ellipsis. Characters are runes, tags are not counted and entities count as one.
+// The cut never happens inside an element such as a <span class="searchmatch"> highlight,
+// so the result stays well-formed. A non-positive n leaves s untouched.
+func truncate(s string, n int) string {
+	if n <= 0 {
+		return s
+	}
+
+	var (
+		visible  int
+		depth    int // number of open elements
+		boundary = -1
+		safe     int // last offset outside of any element, used when there's no word boundary
+	)
+
+	for i := 0; i < len(s); {
+		if s[i] == '<' {
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				end = len(s) - i - 1
+			}
+
+			tag := s[i : i+end+1]
+			switch {
+			case strings.HasPrefix(tag, "</"):
+				if depth > 0 {
+					depth--
+				}
+			case !strings.HasSuffix(tag, "/>"):
+				depth++
+			}
+
+			i += end + 1
+			if depth == 0 {
+				safe = i
+			}
+
+			continue
+		}
+
+		if visible == n {
+			cut := boundary
+			if cut < 0 {
+				cut = safe
+			}
+
+			return strings.TrimRightFunc(s[:cut], unicode.IsSpace) + "…"
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == '&' {
+			if semi := strings.IndexByte(s[i:], ';'); semi > 0 && semi < maxEntityLen {
+				size = semi + 1
+			}
+		}
+
+		if depth == 0 && unicode.IsSpace(r) {
+			boundary = i
+		}
+
+		visible++
+		i += size
+
+		if depth == 0 {
+			safe = i
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{"short enough", "Go is a language", 20, "Go is a language"},
+		{"exact length", "Go is a language", 16, "Go is a language"},
+		{"word boundary", "Go is a programming language", 12, "Go is a…"},
+		{"no limit", "Go is a language", 0, "Go is a language"},
+		{"negative limit", "Go is a language", -1, "Go is a language"},
+		{"multibyte runes", "Ça va très bien merci", 12, "Ça va très…"},
+		{"entity counts as one", "Tom &amp; Jerry are friends", 12, "Tom &amp; Jerry…"},
+		{"tags aren't counted", `<span class="searchmatch">Go</span> is a programming language`, 12, `<span class="searchmatch">Go</span> is a…`},
+		{
+			"no cut inside a highlight",
+			`A <span class="searchmatch">programming language</span> designed at Google`,
+			10,
+			`A…`,
+		},
+		{"single long word", "Supercalifragilisticexpialidocious", 5, "Super…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncate(tt.s, tt.n); got != tt.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateKeepsHTMLWellFormed(t *testing.T) {
+	s := `<span class="searchmatch">Go</span> is <span class="searchmatch">fast</span> and simple`
+
+	for n := 1; n < 30; n++ {
+		got := truncate(s, n)
+		if strings.Count(got, "<span") != strings.Count(got, "</span>") {
+			t.Errorf("truncate(s, %d) = %q leaves an element open", n, got)
+		}
+	}
+}
+
+func TestTruncateTemplateFunction(t *testing.T) {
+	templates, err := loadTemplates(config.TemplateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := templates.New("truncate_test").Parse(`{{ truncate . 12 }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+
+	err = tmpl.Execute(&b, "Go is a programming language")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := b.String(); got != "Go is a…" {
+		t.Errorf("template output %q, want %q", got, "Go is a…")
+	}
+}
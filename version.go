@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%FT%TZ)"
+//
+// commit and buildTime default to the revision and time of the last commit, which Go stamps
+// into binaries built from a checkout
+var (
+	version   = "dev"
+	commit    string
+	buildTime string
+)
+
+type versionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// buildVersion returns the version information of the running binary
+func buildVersion() versionResponse {
+	resp := versionResponse{
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && resp.Commit == "":
+				resp.Commit = setting.Value
+			case setting.Key == "vcs.time" && resp.BuildTime == "":
+				resp.BuildTime = setting.Value
+			}
+		}
+	}
+
+	return resp
+}
+
+func versionHandler(w http.ResponseWriter, r *http.Request) error {
+	buf := getBuffer()
+
+	err := json.NewEncoder(buf).Encode(buildVersion())
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	writeResponse(w, r, buf)
+
+	return nil
+}
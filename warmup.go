@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/freshman-tech/news-demo/logger"
+)
+
+// warmCache runs the given searches so that their first page is cached before anyone asks for it.
+// The searches run one at a time to go easy on the Wikipedia API, failures are logged and skipped.
+// Only the default language is warmed up: a visitor whose Accept-Language picks
+// another one misses the cache on their first search like any other query.
+func warmCache(queries []string) {
+	if len(queries) == 0 {
+		return
+	}
+
+	l := logger.Get().With().Str("component", "warmup").Logger()
+
+	l.Info().Int("queries", len(queries)).Msg("warming up the search cache")
+
+	var warmed int
+
+	for _, query := range queries {
+		ctx, cancel := context.WithTimeout(
+			l.WithContext(context.Background()),
+			time.Duration(config.RequestTimeout),
+		)
+
+		// the options of the first page of a search with the default settings,
+		// so that the cache key matches the one of a regular request
+		_, _, err := cachedSearch(ctx, searchOptions{
+			Query:   query,
+			Project: defaultProject,
+			Lang:    config.DefaultLang,
+			Limit:   config.PageSize,
+			Profile: defaultProfile,
+		})
+
+		cancel()
+
+		if err != nil {
+			l.Warn().Err(err).Str("search_query", query).Msg("unable to warm up the cache")
+			continue
+		}
+
+		warmed++
+
+		l.Debug().Str("search_query", query).Msg("cache warmed up")
+	}
+
+	l.Info().
+		Int("warmed", warmed).
+		Int("failed", len(queries)-warmed).
+		Msg("search cache warm-up done")
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestWarmCacheDefaultLanguageOnly(t *testing.T) {
+	withConfig(t, func(c *Config) { c.DefaultLang = "en" })
+
+	calls := stubSearch(t, searchResponseJSON)
+
+	warmCache([]string{"go"})
+
+	if calls.Load() != 1 {
+		t.Fatalf("%d calls to Wikipedia while warming up, want 1", calls.Load())
+	}
+
+	h := handlerWithError(searchHandler)
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/search?q=go", cacheHit},
+		{"/search?q=go&lang=fr", cacheMiss},
+	}
+
+	for _, tt := range tests {
+		w := serve(h, tt.target, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, want 200: %s", tt.target, w.Code, w.Body)
+		}
+
+		if got := w.Header().Get("X-Cache"); got != tt.want {
+			t.Errorf("%s: X-Cache = %q, want %s", tt.target, got, tt.want)
+		}
+	}
+}